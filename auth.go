@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+type clientKey struct{}
+
+// clientFromContext returns the name of the authenticated API client, or ""
+// when auth is disabled.
+func clientFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(clientKey{}).(string)
+	return name
+}
+
+// authenticate requires a configured API client key, sent either as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>". When no clients are
+// configured every request is let through.
+func (a *app) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clients := a.config().Auth.Clients
+		if len(clients) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key := r.Header.Get("X-API-Key")
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			key = bearer
+		}
+
+		name := ""
+		for _, c := range clients {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(c.Key)) == 1 {
+				name = c.Name
+			}
+		}
+		if key == "" || name == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="playcamp-example"`)
+			writeError(w, r, http.StatusUnauthorized, "missing or invalid API key")
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientKey{}, name)))
+	})
+}
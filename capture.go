@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// /hooks/{bucket} records any request into a named bucket, like a local
+// request bin. Unlike the webhook receiver it keeps the method, headers,
+// query and raw body, which helps when a proxy rewrites or drops headers
+// such as X-Webhook-Signature on the way in.
+
+const (
+	// maxCaptureBuckets is how many buckets are kept; capturing into a new
+	// bucket beyond it drops the least recently used one.
+	maxCaptureBuckets = 20
+	// maxCaptureBody is the largest request body that is captured.
+	maxCaptureBody = 1 << 20
+)
+
+var captureBucketName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// capturedHeaders lists request headers whose values are masked.
+var capturedHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"X-Api-Key":     true,
+}
+
+// capturedRequest is one request recorded by /hooks/{bucket}.
+type capturedRequest struct {
+	ID         string              `json:"id"`
+	Bucket     string              `json:"bucket"`
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	Query      map[string][]string `json:"query,omitempty"`
+	Headers    map[string][]string `json:"headers"`
+	RemoteAddr string              `json:"remoteAddr"`
+	ReceivedAt string              `json:"receivedAt"`
+	// ReadMs is how long reading the body took.
+	ReadMs float64 `json:"readMs"`
+	Size   int     `json:"size"`
+	// Body holds the body if it is valid UTF-8, and BodyBase64 otherwise.
+	Body       string `json:"body,omitempty"`
+	BodyBase64 string `json:"bodyBase64,omitempty"`
+	// Signature is the result of verifying X-Webhook-Signature, if sent.
+	Signature *captureSignature `json:"signature,omitempty"`
+}
+
+type captureSignature struct {
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// captureBucketInfo summarizes a bucket.
+type captureBucketInfo struct {
+	Name           string `json:"name"`
+	Count          int    `json:"count"`
+	LastCapturedAt string `json:"lastCapturedAt"`
+}
+
+type captureBucket struct {
+	requests []capturedRequest // newest first
+	lastUsed time.Time
+}
+
+// captureStore holds the buckets in memory.
+type captureStore struct {
+	mu      sync.Mutex
+	maxSize int
+	counter int
+	buckets map[string]*captureBucket
+}
+
+func newCaptureStore(maxSize int) *captureStore {
+	return &captureStore{maxSize: maxSize, buckets: map[string]*captureBucket{}}
+}
+
+func (s *captureStore) add(req capturedRequest) capturedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.buckets[req.Bucket]
+	if b == nil {
+		if len(s.buckets) >= maxCaptureBuckets {
+			s.evictOldest()
+		}
+		b = &captureBucket{}
+		s.buckets[req.Bucket] = b
+	}
+	s.counter++
+	req.ID = fmt.Sprintf("req_%d", s.counter)
+	b.lastUsed = time.Now()
+	b.requests = append([]capturedRequest{req}, b.requests...)
+	if len(b.requests) > s.maxSize {
+		b.requests = b.requests[:s.maxSize]
+	}
+	return req
+}
+
+func (s *captureStore) evictOldest() {
+	var oldest string
+	for name, b := range s.buckets {
+		if oldest == "" || b.lastUsed.Before(s.buckets[oldest].lastUsed) {
+			oldest = name
+		}
+	}
+	delete(s.buckets, oldest)
+}
+
+// list returns the requests in bucket, newest first, and whether it exists.
+func (s *captureStore) list(bucket string) ([]capturedRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return nil, false
+	}
+	result := make([]capturedRequest, len(b.requests))
+	copy(result, b.requests)
+	return result, true
+}
+
+func (s *captureStore) summaries() []captureBucketInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]captureBucketInfo, 0, len(s.buckets))
+	for name, b := range s.buckets {
+		info := captureBucketInfo{Name: name, Count: len(b.requests)}
+		if len(b.requests) > 0 {
+			info.LastCapturedAt = b.requests[0].ReceivedAt
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+func (s *captureStore) clear(bucket string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.buckets[bucket]
+	delete(s.buckets, bucket)
+	return ok
+}
+
+// resize changes how many requests each bucket keeps.
+func (s *captureStore) resize(maxSize int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxSize = maxSize
+	for _, b := range s.buckets {
+		if len(b.requests) > maxSize {
+			b.requests = b.requests[:maxSize]
+		}
+	}
+}
+
+// --- Capture Endpoints ---
+
+// handleCapture handles ANY /hooks/{bucket}
+func (a *app) handleCapture(w http.ResponseWriter, r *http.Request) {
+	bucket := chi.URLParam(r, "bucket")
+	if !captureBucketName.MatchString(bucket) {
+		writeError(w, r, http.StatusBadRequest, "bucket names are 1-64 letters, digits, '-' or '_'")
+		return
+	}
+
+	start := time.Now()
+	r.Body = http.MaxBytesReader(w, r.Body, maxCaptureBody)
+	body, err := readRawBody(r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("body exceeds %d bytes", maxCaptureBody))
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, "failed to read body")
+		return
+	}
+
+	req := capturedRequest{
+		Bucket:     bucket,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.Query(),
+		Headers:    map[string][]string{},
+		RemoteAddr: r.RemoteAddr,
+		ReceivedAt: start.UTC().Format(time.RFC3339Nano),
+		ReadMs:     float64(time.Since(start).Microseconds()) / 1000,
+		Size:       len(body),
+	}
+	if len(req.Query) == 0 {
+		req.Query = nil
+	}
+	for name, values := range r.Header {
+		if capturedHeaders[name] {
+			masked := make([]string, len(values))
+			for i, v := range values {
+				masked[i] = maskSecret(v)
+			}
+			values = masked
+		}
+		req.Headers[name] = values
+	}
+	if r.Host != "" {
+		req.Headers["Host"] = []string{r.Host}
+	}
+	if utf8.Valid(body) {
+		req.Body = string(body)
+	} else {
+		req.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+	}
+	if signature, ok := r.Header["X-Webhook-Signature"]; ok {
+		result := a.verifyWebhook(body, signature[0])
+		req.Signature = &captureSignature{Valid: result.Valid, Error: result.Error}
+	}
+
+	req = a.captures.add(req)
+	a.webhookLog.DebugContext(r.Context(), "captured request", "bucket", bucket, "id", req.ID, "method", r.Method)
+	writeJSON(w, http.StatusOK, map[string]string{"captured": req.ID})
+}
+
+// handleListCaptureBuckets handles GET /api/hooks
+func (a *app) handleListCaptureBuckets(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.captures.summaries())
+}
+
+// handleGetCaptures handles GET /api/hooks/{bucket}
+func (a *app) handleGetCaptures(w http.ResponseWriter, r *http.Request) {
+	requests, ok := a.captures.list(chi.URLParam(r, "bucket"))
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "bucket not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, requests)
+}
+
+// handleClearCaptures handles DELETE /api/hooks/{bucket}
+func (a *app) handleClearCaptures(w http.ResponseWriter, r *http.Request) {
+	if !a.captures.clear(chi.URLParam(r, "bucket")) {
+		writeError(w, r, http.StatusNotFound, "bucket not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"cleared": true})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/playcamp/playcamp-go-sdk/webhookutil"
+)
+
+func TestCapture(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	payload := `{"events":[]}`
+
+	send := func(method, target, signature string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(payload))
+		req.Header.Set("Authorization", "Bearer secret-token-value")
+		if signature != "" {
+			req.Header.Set("X-Webhook-Signature", signature)
+		}
+		rec := httptest.NewRecorder()
+		a.router.ServeHTTP(rec, req)
+		return rec
+	}
+	send(http.MethodPost, "/hooks/proxy?via=edge", webhookutil.ConstructSignature([]byte(payload), testWebhookSecret, nil))
+	send(http.MethodPut, "/hooks/proxy", "t=1,v1=rewritten")
+	send(http.MethodGet, "/hooks/other", "")
+
+	rec := serve(a, http.MethodGet, "/api/hooks/proxy", "")
+	var captures []capturedRequest
+	decodeData(t, rec, &captures)
+	if len(captures) != 2 {
+		t.Fatalf("captures = %+v", captures)
+	}
+	bad, good := captures[0], captures[1]
+	if good.Method != http.MethodPost || good.Query["via"][0] != "edge" || good.Body != payload {
+		t.Errorf("capture = %+v", good)
+	}
+	if good.Signature == nil || !good.Signature.Valid {
+		t.Errorf("valid signature = %+v", good.Signature)
+	}
+	if bad.Signature == nil || bad.Signature.Valid || bad.Signature.Error == "" {
+		t.Errorf("rewritten signature = %+v", bad.Signature)
+	}
+	if auth := good.Headers["Authorization"][0]; strings.Contains(auth, "secret-token") {
+		t.Errorf("Authorization not masked: %q", auth)
+	}
+
+	var buckets []captureBucketInfo
+	decodeData(t, serve(a, http.MethodGet, "/api/hooks", ""), &buckets)
+	if got := fmt.Sprintf("%s %d %s %d", buckets[0].Name, buckets[0].Count, buckets[1].Name, buckets[1].Count); got != "other 1 proxy 2" {
+		t.Errorf("buckets = %s", got)
+	}
+
+	if rec := send(http.MethodPost, "/hooks/bad.name", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid bucket: status = %d", rec.Code)
+	}
+	if rec := serve(a, http.MethodDelete, "/api/hooks/proxy", ""); rec.Code != http.StatusOK {
+		t.Errorf("clear: status = %d", rec.Code)
+	}
+	if rec := serve(a, http.MethodGet, "/api/hooks/proxy", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("after clear: status = %d", rec.Code)
+	}
+}
+
+func TestCaptureStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	s := newCaptureStore(5)
+	for i := 0; i < maxCaptureBuckets; i++ {
+		s.add(capturedRequest{Bucket: fmt.Sprintf("b%d", i)})
+	}
+	s.add(capturedRequest{Bucket: "b0"})
+	s.add(capturedRequest{Bucket: "new"})
+	if _, ok := s.list("b1"); ok {
+		t.Error("least recently used bucket b1 was kept")
+	}
+	if _, ok := s.list("b0"); !ok {
+		t.Error("recently used bucket b0 was evicted")
+	}
+}
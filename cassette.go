@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// A cassette is a recording of PlayCamp API traffic. The recorder captures
+// what the SDK sends and receives with sensitive fields redacted; the
+// replayer serves the recording back so the server, tests or CI can run
+// against real payloads without network access.
+
+// cassette is the on-disk form of a recording.
+type cassette struct {
+	RecordedAt   string        `json:"recordedAt"`
+	Interactions []interaction `json:"interactions"`
+}
+
+// interaction is one request and the response PlayCamp sent.
+type interaction struct {
+	Request  cassetteRequest  `json:"request"`
+	Response cassetteResponse `json:"response"`
+}
+
+type cassetteRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// Query is the encoded query string, keys sorted.
+	Query string          `json:"query,omitempty"`
+	Body  json.RawMessage `json:"body,omitempty"`
+}
+
+type cassetteResponse struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+	// Text holds a body that is not JSON, such as a proxy error page.
+	Text string `json:"text,omitempty"`
+}
+
+func newCassetteResponse(status int, body []byte) cassetteResponse {
+	if len(bytes.TrimSpace(body)) > 0 && !json.Valid(body) {
+		return cassetteResponse{Status: status, Text: string(body)}
+	}
+	return cassetteResponse{Status: status, Body: normalizeBody(body)}
+}
+
+// key identifies requests that should get the same recorded response. The
+// body is compacted because cassettes are saved indented.
+func (r cassetteRequest) key() string {
+	var body bytes.Buffer
+	if err := json.Compact(&body, r.Body); err != nil {
+		body.Write(r.Body)
+	}
+	return r.Method + " " + r.Path + "?" + r.Query + " " + body.String()
+}
+
+// normalizeBody returns body redacted and re-encoded with sorted keys, so
+// recorded and live requests compare equal regardless of field order.
+// Empty bodies normalize to nil.
+func normalizeBody(body []byte) json.RawMessage {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	return redactJSON(body)
+}
+
+func newCassetteRequest(r *http.Request, body []byte) cassetteRequest {
+	return cassetteRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query().Encode(),
+		Body:   normalizeBody(body),
+	}
+}
+
+func loadCassette(path string) (*cassette, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c cassette
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// save writes c to path atomically.
+func (c *cassette) save(path string) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// --- Recording ---
+
+// cassetteRecorder is an http.RoundTripper that appends every exchange to a
+// cassette file. The file is rewritten after each exchange, so a crash loses
+// nothing. Authorization headers are never stored.
+type cassetteRecorder struct {
+	next http.RoundTripper
+	path string
+	log  *slog.Logger
+
+	mu       sync.Mutex
+	cassette cassette
+}
+
+func newCassetteRecorder(next http.RoundTripper, path string, log *slog.Logger) *cassetteRecorder {
+	return &cassetteRecorder{
+		next:     next,
+		path:     path,
+		log:      log,
+		cassette: cassette{RecordedAt: time.Now().UTC().Format(time.RFC3339)},
+	}
+}
+
+func (c *cassetteRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			reqBody, _ = io.ReadAll(body)
+			body.Close()
+		}
+	}
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	rec := interaction{
+		Request:  newCassetteRequest(req, reqBody),
+		Response: newCassetteResponse(resp.StatusCode, respBody),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cassette.Interactions = append(c.cassette.Interactions, rec)
+	if err := c.cassette.save(c.path); err != nil {
+		c.log.Warn("failed to save cassette", "path", c.path, "error", err.Error())
+	}
+	return resp, nil
+}
+
+// --- Replay ---
+
+// cassetteReplayer serves a cassette as a fake PlayCamp API. Requests match
+// on method, path, query and normalized body. Repeated identical requests get
+// the recorded responses in order, and the last one again once they run out.
+type cassetteReplayer struct {
+	log *slog.Logger
+
+	mu     sync.Mutex
+	queues map[string][]cassetteResponse
+	misses []string
+}
+
+func newCassetteReplayer(c *cassette, log *slog.Logger) *cassetteReplayer {
+	r := &cassetteReplayer{log: log, queues: map[string][]cassetteResponse{}}
+	for _, it := range c.Interactions {
+		k := it.Request.key()
+		r.queues[k] = append(r.queues[k], it.Response)
+	}
+	return r
+}
+
+func (p *cassetteReplayer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req := newCassetteRequest(r, body)
+	k := req.key()
+
+	p.mu.Lock()
+	queue := p.queues[k]
+	var resp cassetteResponse
+	ok := len(queue) > 0
+	if ok {
+		resp = queue[0]
+		if len(queue) > 1 {
+			p.queues[k] = queue[1:]
+		}
+	} else {
+		p.misses = append(p.misses, k)
+	}
+	p.mu.Unlock()
+
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		p.log.Warn("no recorded interaction", "method", req.Method, "path", req.Path, "query", req.Query)
+		w.WriteHeader(http.StatusNotImplemented)
+		json.NewEncoder(w).Encode(map[string]string{
+			"code":    "CASSETTE_MISS",
+			"message": "no recorded interaction for " + req.Method + " " + req.Path,
+		})
+		return
+	}
+	if resp.Text != "" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(resp.Status)
+		io.WriteString(w, resp.Text)
+		return
+	}
+	var out bytes.Buffer
+	if err := json.Compact(&out, resp.Body); err != nil {
+		out.Write(resp.Body)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	w.Write(out.Bytes())
+}
+
+// unmatched returns the requests that had no recorded interaction.
+func (p *cassetteReplayer) unmatched() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.misses...)
+}
+
+// runReplayCommand implements `go run . replay -cassette FILE [-addr ADDR]`:
+// it serves the cassette until interrupted. Point SDK_API_URL at it.
+func runReplayCommand(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	path := fs.String("cassette", "testdata/cassettes/sandbox.json", "cassette file to serve")
+	addr := fs.String("addr", "127.0.0.1:4601", "listen address")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	log := newLogRegistry(os.Stdout).logger(logApp)
+	c, err := loadCassette(*path)
+	if err != nil {
+		log.Error("failed to load cassette", "error", err.Error())
+		return 1
+	}
+	log.Info("replaying cassette", "file", *path, "interactions", len(c.Interactions), "url", "http://"+*addr)
+	err = http.ListenAndServe(*addr, newCassetteReplayer(c, log))
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error("replayer stopped", "error", err.Error())
+		return 1
+	}
+	return 0
+}
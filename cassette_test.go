@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Refresh the cassette against the sandbox with:
+//
+//	SERVER_API_KEY=... go test -run TestCassettes -record .
+var recordCassettes = flag.Bool("record", false, "re-record testdata/cassettes against the PlayCamp sandbox")
+
+const sandboxCassette = "testdata/cassettes/sandbox.json"
+
+// cassetteSteps is the scenario recorded in sandboxCassette. Request bodies
+// are fixed so replays match the recording.
+var cassetteSteps = []struct {
+	method string
+	target string
+	body   string
+}{
+	{http.MethodGet, "/api/campaigns?limit=5", ""},
+	{http.MethodGet, "/api/creators/search?keyword=neo&isTest=true", ""},
+	{http.MethodPost, "/api/coupons/validate", `{"couponCode":"WELCOME","userId":"cassette-user","isTest":true}`},
+	{http.MethodPost, "/api/payments", `{"userId":"cassette-user","transactionId":"cassette-tx-1","productId":"gems_100","amount":1200,"currency":"KRW","platform":"Android","purchasedAt":"2026-01-01T00:00:00Z","receipt":"receipt-data","isTest":true}`},
+	{http.MethodGet, "/api/payments/user/cassette-user?isTest=true", ""},
+}
+
+// TestCassettes replays the sandbox recording through the real handlers and
+// SDK. With -record it runs the same steps against the sandbox instead and
+// rewrites the cassette.
+func TestCassettes(t *testing.T) {
+	if *recordCassettes {
+		recordSandboxCassette(t)
+		return
+	}
+
+	c, err := loadCassette(sandboxCassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayer := newCassetteReplayer(c, newLogRegistry(io.Discard).logger(logSDK))
+	srv := httptest.NewServer(replayer)
+	defer srv.Close()
+
+	a := newTestApp(t, &fakePlayCamp{Server: srv}, nil)
+	for _, step := range cassetteSteps {
+		rec := serve(a, step.method, step.target, step.body)
+		if rec.Code >= 500 {
+			t.Errorf("%s %s: status = %d; body %s", step.method, step.target, rec.Code, rec.Body)
+		}
+	}
+	for _, miss := range replayer.unmatched() {
+		t.Errorf("no recorded interaction for %s", miss)
+	}
+}
+
+func recordSandboxCassette(t *testing.T) {
+	key := os.Getenv("SERVER_API_KEY")
+	if key == "" {
+		t.Skip("SERVER_API_KEY is required to record cassettes")
+	}
+	tmp := filepath.Join(t.TempDir(), "sandbox.json")
+
+	cfg := defaultConfig()
+	cfg.APIKey = key
+	cfg.SDK.Environment = "sandbox"
+	cfg.SDK.RecordCassette = tmp
+	cfg.RateLimit.Enabled = false
+	a, err := newApp(cfg, "", newLogRegistry(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range cassetteSteps {
+		rec := serve(a, step.method, step.target, step.body)
+		t.Logf("%s %s: %d", step.method, step.target, rec.Code)
+	}
+
+	raw, err := os.ReadFile(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), key) {
+		t.Fatal("recording contains the API key")
+	}
+	if err := os.WriteFile(sandboxCassette, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCassetteRecorderRedacts(t *testing.T) {
+	const payment = `{"userId":"u1","transactionId":"tx-1","productId":"p","amount":1,"currency":"KRW","platform":"Android","purchasedAt":"2026-01-01T00:00:00Z","receipt":"secret-receipt"}`
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodPost, "/v1/server/payments", http.StatusCreated, `{"data":{"transactionId":"tx-1"}}`)
+	fake.on(http.MethodPost, "/v1/server/sponsors", http.StatusBadGateway, `<html>bad gateway</html>`)
+	path := filepath.Join(t.TempDir(), "c.json")
+	a := newTestApp(t, fake, map[string]string{"SDK_RECORD_CASSETTE": path})
+
+	serve(a, http.MethodPost, "/api/payments", payment)
+	serve(a, http.MethodPost, "/api/sponsors", `{"userId":"u1","creatorKey":"neo"}`)
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, leak := range []string{"secret-receipt", testAPIKey} {
+		if strings.Contains(string(raw), leak) {
+			t.Errorf("cassette contains %q", leak)
+		}
+	}
+	c, err := loadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Interactions) != 2 {
+		t.Fatalf("interactions = %d", len(c.Interactions))
+	}
+	if got := c.Interactions[1].Response; got.Text != "<html>bad gateway</html>" {
+		t.Errorf("non-JSON response = %+v", got)
+	}
+
+	// A replay matches although the receipt differs, since both are redacted.
+	replayer := newCassetteReplayer(c, newLogRegistry(io.Discard).logger(logSDK))
+	srv := httptest.NewServer(replayer)
+	defer srv.Close()
+	b := newTestApp(t, &fakePlayCamp{Server: srv}, nil)
+	rec := serve(b, http.MethodPost, "/api/payments", strings.Replace(payment, "secret-receipt", "other-receipt", 1))
+	if rec.Code != http.StatusCreated {
+		t.Errorf("replay status = %d; body %s", rec.Code, rec.Body)
+	}
+	if misses := replayer.unmatched(); len(misses) != 0 {
+		t.Errorf("unmatched = %v", misses)
+	}
+}
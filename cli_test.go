@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// runTestCLI runs the CLI against fake and returns the exit code and output.
+func runTestCLI(t *testing.T, fake *fakePlayCamp, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("SERVER_API_KEY", testAPIKey)
+	t.Setenv("SDK_API_URL", fake.URL)
+	var stdout, stderr bytes.Buffer
+	code := runCLI(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestCLICampaignsList(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodGet, "/v1/server/campaigns", http.StatusOK,
+		`{"data":[{"campaignId":"c1","campaignName":{"ko":"봄","en":"Spring"},"status":"ACTIVE","startDate":null}],"pagination":{"page":2,"limit":5,"total":6,"totalPages":2}}`)
+
+	code, out, errOut := runTestCLI(t, fake, "--test", "campaigns", "list", "--page", "2", "--limit", "5")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, errOut)
+	}
+	got := fake.received()[0]
+	if got.Query.Get("page") != "2" || got.Query.Get("limit") != "5" || got.Query.Get("isTest") != "true" {
+		t.Errorf("upstream query = %v", got.Query)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "CAMPAIGNID") || !strings.Contains(lines[1], "Spring") {
+		t.Errorf("table =\n%s", out)
+	}
+}
+
+func TestCLIOutputJSON(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodGet, "/v1/server/webhooks", http.StatusOK, `{"data":[{"id":3,"eventType":"payment.created","url":"https://example.com/hook"}]}`)
+
+	code, out, errOut := runTestCLI(t, fake, "webhooks", "list", "--output", "json")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, errOut)
+	}
+	var hooks []map[string]any
+	if err := json.Unmarshal([]byte(out), &hooks); err != nil || len(hooks) != 1 || hooks[0]["id"] != 3.0 {
+		t.Errorf("output = %s (%v)", out, err)
+	}
+}
+
+func TestCLIPaymentsCreate(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodPost, "/v1/server/payments", http.StatusCreated, `{"data":{"transactionId":"tx-1","status":"COMPLETED"}}`)
+
+	code, _, errOut := runTestCLI(t, fake, "payments", "create", "--test",
+		"--user", "u1", "--tx", "tx-1", "--product", "gems", "--amount", "1200",
+		"--currency", "krw", "--platform", "Android", "--purchased-at", "2026-01-01T00:00:00Z")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, errOut)
+	}
+	body := jsonBody(t, fake.received()[0])
+	if body["currency"] != "KRW" || body["amount"] != 1200.0 || body["isTest"] != true {
+		t.Errorf("upstream body = %v", body)
+	}
+}
+
+func TestCLIPaymentsCreateReceipt(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodPost, "/v1/server/payments", http.StatusCreated, `{"data":{"transactionId":"tx-1","status":"COMPLETED"}}`)
+	t.Setenv("RECEIPT_VERIFIERS", "Web=signed")
+	t.Setenv("RECEIPT_SIGNING_SECRETS", testReceiptSecret)
+	good := testSignedReceipt(testReceiptSecret, signedReceipt{TransactionID: "tx-1", UserID: "u1", ProductID: "gems", Amount: "4.99", Currency: "USD"})
+
+	tests := []struct {
+		name     string
+		receipt  string
+		wantCode int
+		wantErr  string
+	}{
+		{"forged", good + "x", 1, "receipt rejected"},
+		{"missing", "", 1, "a receipt is required"},
+		{"valid", good, 0, ""},
+	}
+	for _, tc := range tests {
+		code, _, errOut := runTestCLI(t, fake, "payments", "create",
+			"--user", "u1", "--tx", "tx-1", "--product", "gems", "--amount", "4.99",
+			"--currency", "USD", "--platform", "Web", "--receipt", tc.receipt)
+		if code != tc.wantCode || !strings.Contains(errOut, tc.wantErr) {
+			t.Errorf("%s: exit %d: %s", tc.name, code, errOut)
+		}
+	}
+	if n := len(fake.received()); n != 1 {
+		t.Errorf("PlayCamp calls = %d, want 1", n)
+	}
+}
+
+func TestCLIValidation(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	code, _, errOut := runTestCLI(t, fake, "payments", "create", "--user", "u1", "--amount", "-1")
+	if code != 2 {
+		t.Fatalf("exit %d, want 2", code)
+	}
+	for _, want := range []string{"--tx: is required", "--amount: must be greater than zero"} {
+		if !strings.Contains(errOut, want) {
+			t.Errorf("stderr missing %q:\n%s", want, errOut)
+		}
+	}
+	if n := len(fake.received()); n != 0 {
+		t.Errorf("PlayCamp calls = %d", n)
+	}
+}
+
+func TestCLISponsorsSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  string
+		upstream string
+	}{
+		{"create", `{"data":[]}`, "POST /v1/server/sponsors"},
+		{"change", `{"data":[{"userId":"u1","campaignId":"c1","creatorKey":"neo","isActive":true}]}`, "PUT /v1/server/sponsors/user/u1"},
+		{"unchanged", `{"data":[{"userId":"u1","campaignId":"c1","creatorKey":"trinity","isActive":true}]}`, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newFakePlayCamp(t)
+			fake.on(http.MethodGet, "/v1/server/sponsors/user/u1", http.StatusOK, tc.current)
+			fake.on(http.MethodPost, "/v1/server/sponsors", http.StatusCreated, `{"data":{"userId":"u1","creatorKey":"trinity"}}`)
+			fake.on(http.MethodPut, "/v1/server/sponsors/user/u1", http.StatusOK, `{"data":{"userId":"u1","creatorKey":"trinity"}}`)
+
+			code, _, errOut := runTestCLI(t, fake, "sponsors", "set", "--user", "u1", "--creator", "trinity")
+			if code != 0 {
+				t.Fatalf("exit %d: %s", code, errOut)
+			}
+			reqs := fake.received()
+			var got string
+			if len(reqs) > 1 {
+				got = reqs[1].Method + " " + reqs[1].Path
+			}
+			if got != tc.upstream {
+				t.Errorf("write = %q, want %q", got, tc.upstream)
+			}
+		})
+	}
+}
+
+func TestCLIErrors(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodPost, "/v1/server/payments/tx-9/refund", http.StatusNotFound, `{"code":"NOT_FOUND","message":"payment not found"}`)
+
+	code, _, errOut := runTestCLI(t, fake, "payments", "refund", "tx-9")
+	if code != 1 || !strings.Contains(errOut, "404 (NOT_FOUND): payment not found") {
+		t.Errorf("exit %d: %s", code, errOut)
+	}
+
+	code, _, errOut = runTestCLI(t, fake, "payments", "void", "tx-9")
+	if code != 2 || !strings.Contains(errOut, "unknown command") {
+		t.Errorf("exit %d: %s", code, errOut)
+	}
+
+	code, _, errOut = runTestCLI(t, fake, "--env", "staging", "webhooks", "list")
+	if code != 2 || !strings.Contains(errOut, "--env must be") {
+		t.Errorf("exit %d: %s", code, errOut)
+	}
+}
@@ -0,0 +1,917 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is read when CONFIG_FILE is unset and the file exists.
+const defaultConfigFile = "config.yaml"
+
+// config is the typed server configuration. It is loaded from a YAML or TOML
+// file and then overridden by environment variables.
+//
+// Fields marked "structural" are fixed at startup; a SIGHUP reload logs a
+// warning if they change and keeps the running value.
+type config struct {
+	Port   string `yaml:"port" json:"port,omitempty"`     // structural
+	APIKey string `yaml:"apiKey" json:"apiKey,omitempty"` // structural
+
+	SDK     sdkConfig     `yaml:"sdk" json:"sdk"`
+	Log     logConfig     `yaml:"log" json:"log"`
+	Webhook webhookConfig `yaml:"webhook" json:"webhook"`
+	Server  serverConfig  `yaml:"server" json:"server"`
+	TLS     tlsConfig     `yaml:"tls" json:"tls"`
+	CORS    corsConfig    `yaml:"cors" json:"cors"`
+	Auth    authConfig    `yaml:"auth" json:"auth"`
+
+	RateLimit   rateLimitConfig   `yaml:"rateLimit" json:"rateLimit"`
+	CouponGuard couponGuardConfig `yaml:"couponGuard" json:"couponGuard"`
+	Validation  validationConfig  `yaml:"validation" json:"validation"`
+
+	SponsorState sponsorStateConfig `yaml:"sponsorState" json:"sponsorState"`
+	Revenue      revenueConfig      `yaml:"revenue" json:"revenue"`
+	Currency     currencyConfig     `yaml:"currency" json:"currency"`
+	Receipts     receiptsConfig     `yaml:"receipts" json:"receipts"`
+}
+
+type sdkConfig struct {
+	Environment string `yaml:"environment" json:"environment,omitempty"` // structural
+	APIURL      string `yaml:"apiUrl" json:"apiUrl,omitempty"`           // structural
+	Debug       bool   `yaml:"debug" json:"debug,omitempty"`
+	// RecordCassette, when set, records all SDK traffic to this file.
+	RecordCassette string `yaml:"recordCassette" json:"recordCassette,omitempty"` // structural
+}
+
+type logConfig struct {
+	// Level is a spec such as "info,webhook=debug,sdk=warn".
+	Level string `yaml:"level" json:"level,omitempty"`
+}
+
+type webhookConfig struct {
+	// Secret is the primary signature secret.
+	Secret string `yaml:"secret" json:"secret,omitempty"`
+	// Secrets are additional accepted secrets. PlayCamp issues one secret per
+	// subscription, and old secrets stay valid here during rotation.
+	Secrets []string `yaml:"secrets" json:"secrets,omitempty"`
+	// StoreSize is how many received webhooks are kept in memory.
+	StoreSize int `yaml:"storeSize" json:"storeSize,omitempty"`
+	// SimulateLive lets /api/webhooks/simulate and scenario runs send events
+	// with isTest false, which post to the live revenue ledger and sponsor
+	// state. Off by default.
+	SimulateLive bool `yaml:"simulateLive" json:"simulateLive,omitempty"`
+
+	// PublicBaseURL is where PlayCamp can reach this server. When set, the
+	// receiver is subscribed to Events on startup.
+	PublicBaseURL string `yaml:"publicBaseUrl" json:"publicBaseUrl,omitempty"` // structural
+	// Events are the event types subscribed on startup; empty means all.
+	Events []string `yaml:"events" json:"events,omitempty"` // structural
+	// RemoveOnShutdown deletes the startup subscriptions on shutdown.
+	RemoveOnShutdown bool `yaml:"removeOnShutdown" json:"removeOnShutdown,omitempty"`
+}
+
+// serverConfig holds HTTP server timeouts. All fields are structural.
+type serverConfig struct {
+	ReadHeaderTimeout  time.Duration `yaml:"readHeaderTimeout"`
+	ReadTimeout        time.Duration `yaml:"readTimeout"`
+	WriteTimeout       time.Duration `yaml:"writeTimeout"`
+	IdleTimeout        time.Duration `yaml:"idleTimeout"`
+	ShutdownDrainDelay time.Duration `yaml:"shutdownDrainDelay"`
+	ShutdownTimeout    time.Duration `yaml:"shutdownTimeout"`
+}
+
+// MarshalJSON renders durations as strings such as "30s" when the config is logged.
+func (s serverConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"readHeaderTimeout":  s.ReadHeaderTimeout.String(),
+		"readTimeout":        s.ReadTimeout.String(),
+		"writeTimeout":       s.WriteTimeout.String(),
+		"idleTimeout":        s.IdleTimeout.String(),
+		"shutdownDrainDelay": s.ShutdownDrainDelay.String(),
+		"shutdownTimeout":    s.ShutdownTimeout.String(),
+	})
+}
+
+// tlsConfig enables HTTPS when CertFile and KeyFile are set. ClientCAFile
+// additionally requires client certificates on /api. File paths are
+// structural; the files themselves are re-read when they change.
+type tlsConfig struct {
+	CertFile       string        `yaml:"certFile"`
+	KeyFile        string        `yaml:"keyFile"`
+	ClientCAFile   string        `yaml:"clientCAFile"`
+	ReloadInterval time.Duration `yaml:"reloadInterval"`
+}
+
+// enabled reports whether the server should listen with TLS.
+func (t tlsConfig) enabled() bool {
+	return t.CertFile != ""
+}
+
+// MarshalJSON renders the reload interval as a string when the config is logged.
+func (t tlsConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"certFile":       t.CertFile,
+		"keyFile":        t.KeyFile,
+		"clientCAFile":   t.ClientCAFile,
+		"reloadInterval": t.ReloadInterval.String(),
+	})
+}
+
+type corsConfig struct {
+	AllowedOrigins []string `yaml:"allowedOrigins" json:"allowedOrigins,omitempty"`
+	AllowedMethods []string `yaml:"allowedMethods" json:"allowedMethods,omitempty"`
+	AllowedHeaders []string `yaml:"allowedHeaders" json:"allowedHeaders,omitempty"`
+}
+
+// authConfig lists the API clients allowed to call /api and /webview. When
+// no clients are configured those routes are open.
+type authConfig struct {
+	Clients []apiClient `yaml:"clients" json:"clients,omitempty"`
+}
+
+type apiClient struct {
+	Name string `yaml:"name" json:"name,omitempty"`
+	Key  string `yaml:"key" json:"key,omitempty"`
+}
+
+// Rate limit keys.
+const (
+	rateLimitByClient = "client"
+	rateLimitByUser   = "user"
+	rateLimitByIP     = "ip"
+)
+
+// rateLimitConfig limits /api and /webview requests. Default applies to every
+// route; an entry in Routes adds a second bucket on top of it, so a per-user
+// route limit cannot be dodged by changing the userId.
+type rateLimitConfig struct {
+	Enabled  bool             `yaml:"enabled" json:"enabled"`
+	Default  rateLimitRule    `yaml:"default" json:"default"`
+	Routes   []rateLimitRoute `yaml:"routes" json:"routes,omitempty"`
+	Upstream upstreamBudget   `yaml:"upstream" json:"upstream"`
+}
+
+// rateLimitRule is a token bucket: Rate requests per second on average, with
+// bursts of up to Burst. Key is what requests are counted against.
+type rateLimitRule struct {
+	Rate  float64 `yaml:"rate" json:"rate"`
+	Burst int     `yaml:"burst" json:"burst"`
+	Key   string  `yaml:"key" json:"key"`
+}
+
+// rateLimitRoute overrides the default for one route, written as
+// "METHOD /pattern" using the router's pattern, e.g.
+// "GET /api/payments/{transactionId}".
+type rateLimitRoute struct {
+	Route         string `yaml:"route" json:"route"`
+	rateLimitRule `yaml:",inline"`
+}
+
+// upstreamBudget caps PlayCamp calls across all clients. Reserve tokens are
+// only available to payment and refund routes. A zero Rate disables it.
+type upstreamBudget struct {
+	Rate    float64 `yaml:"rate" json:"rate"`
+	Burst   int     `yaml:"burst" json:"burst"`
+	Reserve int     `yaml:"reserve" json:"reserve"`
+}
+
+// couponGuardConfig controls lockouts after failed coupon attempts. After
+// MaxFailures failures within Window, a user is locked out for Lockout; each
+// further lockout doubles, up to MaxLockout. An IP is locked out the same way
+// after IPMaxFailures failures, or only reported when it is zero. Strikes are
+// forgotten after ResetAfter without failures.
+type couponGuardConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	MaxFailures   int           `yaml:"maxFailures"`
+	IPMaxFailures int           `yaml:"ipMaxFailures"`
+	Window        time.Duration `yaml:"window"`
+	Lockout       time.Duration `yaml:"lockout"`
+	MaxLockout    time.Duration `yaml:"maxLockout"`
+	ResetAfter    time.Duration `yaml:"resetAfter"`
+}
+
+// MarshalJSON renders durations as strings when the config is logged.
+func (c couponGuardConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"enabled":       c.Enabled,
+		"maxFailures":   c.MaxFailures,
+		"ipMaxFailures": c.IPMaxFailures,
+		"window":        c.Window.String(),
+		"lockout":       c.Lockout.String(),
+		"maxLockout":    c.MaxLockout.String(),
+		"resetAfter":    c.ResetAfter.String(),
+	})
+}
+
+// sponsorStateConfig controls the local sponsor state view. It tracks up to
+// MaxUsers users, dropping the least recently used beyond that and any user
+// not looked up or sent an event for ExpireAfter. Every ReconcileInterval, up
+// to ReconcileBatch users, least recently checked first, are checked against
+// PlayCamp at ReconcileRate calls per second; a zero interval disables the
+// background pass.
+type sponsorStateConfig struct {
+	MaxUsers          int           `yaml:"maxUsers"`
+	ExpireAfter       time.Duration `yaml:"expireAfter"`
+	ReconcileInterval time.Duration `yaml:"reconcileInterval"`
+	ReconcileBatch    int           `yaml:"reconcileBatch"`
+	ReconcileRate     float64       `yaml:"reconcileRate"`
+}
+
+// MarshalJSON renders durations as strings when the config is logged.
+func (c sponsorStateConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{
+		"maxUsers":          c.MaxUsers,
+		"expireAfter":       c.ExpireAfter.String(),
+		"reconcileInterval": c.ReconcileInterval.String(),
+		"reconcileBatch":    c.ReconcileBatch,
+		"reconcileRate":     c.ReconcileRate,
+	})
+}
+
+// revenueConfig controls the creator revenue ledger. StateFile, when set,
+// keeps the last closed day across restarts; the ledger itself stays in
+// memory.
+type revenueConfig struct {
+	StateFile string `yaml:"stateFile" json:"stateFile,omitempty"` // structural
+}
+
+// currencyConfig controls currency conversion in reports. RatesFile is a
+// dated exchange-rate file, re-read on reload; ReportingCurrency is what
+// reports convert to when a request does not name one.
+type currencyConfig struct {
+	ReportingCurrency string `yaml:"reportingCurrency" json:"reportingCurrency,omitempty"`
+	RatesFile         string `yaml:"ratesFile" json:"ratesFile,omitempty"`
+}
+
+// receiptsConfig turns on in-app purchase receipt verification. Verifiers
+// maps a payment platform to the verifier its receipts are checked with;
+// payments on other platforms are reported unchecked. Payments that fail
+// are kept in a quarantine of up to QuarantineSize entries.
+type receiptsConfig struct {
+	Verifiers      map[string]string   `yaml:"verifiers" json:"verifiers,omitempty"`
+	QuarantineSize int                 `yaml:"quarantineSize" json:"quarantineSize"`
+	Apple          appleReceiptConfig  `yaml:"apple" json:"apple"`
+	Google         googleReceiptConfig `yaml:"google" json:"google"`
+	Signed         signedReceiptConfig `yaml:"signed" json:"signed"`
+}
+
+// appleReceiptConfig checks App Store JWS transactions. RootCAFile holds
+// the Apple root certificate (PEM) the x5c chain must lead to.
+type appleReceiptConfig struct {
+	BundleID   string `yaml:"bundleId" json:"bundleId,omitempty"`
+	RootCAFile string `yaml:"rootCAFile" json:"rootCAFile,omitempty"`
+}
+
+// googleReceiptConfig looks up Google Play purchase tokens with the
+// Android Publisher API, authorized by a service account key file.
+type googleReceiptConfig struct {
+	PackageName        string `yaml:"packageName" json:"packageName,omitempty"`
+	ServiceAccountFile string `yaml:"serviceAccountFile" json:"serviceAccountFile,omitempty"`
+	// APIURL overrides https://androidpublisher.googleapis.com.
+	APIURL string `yaml:"apiUrl" json:"apiUrl,omitempty"`
+}
+
+// signedReceiptConfig checks receipts signed by our own payment backend
+// with any of Secrets.
+type signedReceiptConfig struct {
+	Secrets []string `yaml:"secrets" json:"secrets,omitempty"`
+}
+
+type validationConfig struct {
+	// Strict rejects request bodies with fields the endpoint doesn't know.
+	Strict bool `yaml:"strict" json:"strict"`
+}
+
+// routeRule returns the extra rule configured for route, if any.
+func (c rateLimitConfig) routeRule(route string) (rateLimitRule, bool) {
+	for _, rt := range c.Routes {
+		if rt.Route == route {
+			return rt.rateLimitRule, true
+		}
+	}
+	return rateLimitRule{}, false
+}
+
+// defaultConfig returns the settings used when neither the file nor the
+// environment provides a value.
+func defaultConfig() *config {
+	return &config{
+		Port: "4000",
+		Log:  logConfig{Level: "info"},
+		Webhook: webhookConfig{
+			StoreSize: 50,
+		},
+		Server: serverConfig{
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      2 * time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		TLS: tlsConfig{
+			ReloadInterval: 30 * time.Second,
+		},
+		CORS: corsConfig{
+			AllowedOrigins: []string{"*"},
+			AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-Id"},
+		},
+		RateLimit: rateLimitConfig{
+			Enabled: true,
+			Default: rateLimitRule{Rate: 20, Burst: 40, Key: rateLimitByClient},
+			Routes: []rateLimitRoute{
+				{Route: "POST /api/coupons/validate", rateLimitRule: rateLimitRule{Rate: 1, Burst: 10, Key: rateLimitByUser}},
+				{Route: "POST /api/coupons/redeem", rateLimitRule: rateLimitRule{Rate: 1, Burst: 10, Key: rateLimitByUser}},
+			},
+		},
+		CouponGuard: couponGuardConfig{
+			Enabled:       true,
+			MaxFailures:   5,
+			IPMaxFailures: 100,
+			Window:        10 * time.Minute,
+			Lockout:       time.Minute,
+			MaxLockout:    24 * time.Hour,
+			ResetAfter:    24 * time.Hour,
+		},
+		SponsorState: sponsorStateConfig{
+			MaxUsers:          10000,
+			ExpireAfter:       24 * time.Hour,
+			ReconcileInterval: 15 * time.Minute,
+			ReconcileBatch:    500,
+			ReconcileRate:     5,
+		},
+		Receipts: receiptsConfig{
+			QuarantineSize: 200,
+		},
+	}
+}
+
+// configPath returns the config file to load, or "" if there is none.
+func configPath() string {
+	if p := os.Getenv("CONFIG_FILE"); p != "" {
+		return p
+	}
+	if _, err := os.Stat(defaultConfigFile); err == nil {
+		return defaultConfigFile
+	}
+	return ""
+}
+
+// loadConfig reads path (if non-empty), applies environment overrides and
+// validates the result.
+func loadConfig(path string) (*config, error) {
+	cfg := defaultConfig()
+
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		if err := decodeConfigFile(path, raw, cfg); err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decodeConfigFile decodes raw into cfg as TOML when path ends in .toml and
+// as YAML otherwise. Unknown keys are rejected in both formats.
+func decodeConfigFile(path string, raw []byte, cfg *config) error {
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		md, err := toml.Decode(string(raw), cfg)
+		if err != nil {
+			return err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, k := range undecoded {
+				keys[i] = k.String()
+			}
+			return fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+		}
+		return nil
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	// An empty file decodes as io.EOF and means "all defaults".
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// applyEnv overrides cfg with any environment variables that are set.
+func (c *config) applyEnv(lookup func(string) (string, bool)) error {
+	var errs configErrors
+
+	str := func(name string, dst *string) {
+		if v, ok := lookup(name); ok && v != "" {
+			*dst = v
+		}
+	}
+	dur := func(name string, dst *time.Duration) {
+		if v, ok := lookup(name); ok && v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: invalid duration %q", name, v))
+				return
+			}
+			*dst = d
+		}
+	}
+	list := func(name string, dst *[]string) {
+		if v, ok := lookup(name); ok && v != "" {
+			*dst = splitList(v)
+		}
+	}
+
+	str("PORT", &c.Port)
+	str("SERVER_API_KEY", &c.APIKey)
+	str("SDK_ENVIRONMENT", &c.SDK.Environment)
+	str("SDK_API_URL", &c.SDK.APIURL)
+	str("SDK_RECORD_CASSETTE", &c.SDK.RecordCassette)
+	if v, ok := lookup("SDK_DEBUG"); ok && v != "" {
+		c.SDK.Debug = strings.EqualFold(v, "true")
+	}
+	str("LOG_LEVEL", &c.Log.Level)
+
+	str("WEBHOOK_SECRET", &c.Webhook.Secret)
+	list("WEBHOOK_SECRETS", &c.Webhook.Secrets)
+	if v, ok := lookup("WEBHOOK_STORE_SIZE"); ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("WEBHOOK_STORE_SIZE: invalid integer %q", v))
+		} else {
+			c.Webhook.StoreSize = n
+		}
+	}
+	if v, ok := lookup("WEBHOOK_SIMULATE_LIVE"); ok && v != "" {
+		c.Webhook.SimulateLive = strings.EqualFold(v, "true")
+	}
+	str("PUBLIC_BASE_URL", &c.Webhook.PublicBaseURL)
+	list("WEBHOOK_EVENTS", &c.Webhook.Events)
+	if v, ok := lookup("WEBHOOK_REMOVE_ON_SHUTDOWN"); ok && v != "" {
+		c.Webhook.RemoveOnShutdown = strings.EqualFold(v, "true")
+	}
+
+	dur("SERVER_READ_HEADER_TIMEOUT", &c.Server.ReadHeaderTimeout)
+	dur("SERVER_READ_TIMEOUT", &c.Server.ReadTimeout)
+	dur("SERVER_WRITE_TIMEOUT", &c.Server.WriteTimeout)
+	dur("SERVER_IDLE_TIMEOUT", &c.Server.IdleTimeout)
+	dur("SHUTDOWN_DRAIN_DELAY", &c.Server.ShutdownDrainDelay)
+	dur("SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout)
+
+	str("TLS_CERT_FILE", &c.TLS.CertFile)
+	str("TLS_KEY_FILE", &c.TLS.KeyFile)
+	str("TLS_CLIENT_CA_FILE", &c.TLS.ClientCAFile)
+	dur("TLS_RELOAD_INTERVAL", &c.TLS.ReloadInterval)
+	dur("SPONSOR_RECONCILE_INTERVAL", &c.SponsorState.ReconcileInterval)
+	str("REVENUE_STATE_FILE", &c.Revenue.StateFile)
+	str("REPORTING_CURRENCY", &c.Currency.ReportingCurrency)
+	str("FX_RATES_FILE", &c.Currency.RatesFile)
+
+	// RECEIPT_VERIFIERS is a comma-separated list of platform=verifier pairs.
+	if v, ok := lookup("RECEIPT_VERIFIERS"); ok && v != "" {
+		c.Receipts.Verifiers = map[string]string{}
+		for _, pair := range splitList(v) {
+			platform, verifier, found := strings.Cut(pair, "=")
+			if !found {
+				errs = append(errs, "RECEIPT_VERIFIERS: entries must be platform=verifier")
+				continue
+			}
+			c.Receipts.Verifiers[platform] = verifier
+		}
+	}
+	str("APPLE_BUNDLE_ID", &c.Receipts.Apple.BundleID)
+	str("APPLE_ROOT_CA_FILE", &c.Receipts.Apple.RootCAFile)
+	str("GOOGLE_PLAY_PACKAGE_NAME", &c.Receipts.Google.PackageName)
+	str("GOOGLE_PLAY_SERVICE_ACCOUNT_FILE", &c.Receipts.Google.ServiceAccountFile)
+	str("GOOGLE_PLAY_API_URL", &c.Receipts.Google.APIURL)
+	list("RECEIPT_SIGNING_SECRETS", &c.Receipts.Signed.Secrets)
+
+	list("CORS_ALLOWED_ORIGINS", &c.CORS.AllowedOrigins)
+
+	// AUTH_API_KEYS is a comma-separated list of name=key pairs.
+	if v, ok := lookup("AUTH_API_KEYS"); ok && v != "" {
+		c.Auth.Clients = nil
+		for _, pair := range splitList(v) {
+			name, key, found := strings.Cut(pair, "=")
+			if !found {
+				errs = append(errs, "AUTH_API_KEYS: entries must be name=key")
+				continue
+			}
+			c.Auth.Clients = append(c.Auth.Clients, apiClient{Name: name, Key: key})
+		}
+	}
+
+	if v, ok := lookup("RATE_LIMIT_ENABLED"); ok && v != "" {
+		c.RateLimit.Enabled = strings.EqualFold(v, "true")
+	}
+	if v, ok := lookup("VALIDATION_STRICT"); ok && v != "" {
+		c.Validation.Strict = strings.EqualFold(v, "true")
+	}
+	if v, ok := lookup("COUPON_GUARD_ENABLED"); ok && v != "" {
+		c.CouponGuard.Enabled = strings.EqualFold(v, "true")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// configErrors collects every problem found while loading a config.
+type configErrors []string
+
+func (e configErrors) Error() string {
+	return strings.Join(e, "; ")
+}
+
+// validate checks the whole config and reports every problem at once.
+func (c *config) validate() error {
+	var errs configErrors
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	if n, err := strconv.Atoi(c.Port); err != nil || n < 1 || n > 65535 {
+		add("port: must be a number between 1 and 65535, got %q", c.Port)
+	}
+
+	if c.APIKey == "" {
+		add("apiKey: required (set SERVER_API_KEY)")
+	} else if keyID, secret, ok := strings.Cut(c.APIKey, ":"); !ok || keyID == "" || secret == "" {
+		add("apiKey: must be in keyId:secret format")
+	}
+
+	switch playcamp.Environment(c.SDK.Environment) {
+	case "", playcamp.EnvironmentLive, playcamp.EnvironmentSandbox:
+	default:
+		add("sdk.environment: must be %q or %q, got %q", playcamp.EnvironmentSandbox, playcamp.EnvironmentLive, c.SDK.Environment)
+	}
+	if c.SDK.APIURL != "" {
+		if u, err := url.Parse(c.SDK.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("sdk.apiUrl: must be an absolute http or https URL, got %q", c.SDK.APIURL)
+		}
+	}
+
+	if _, _, err := parseLevelSpec(c.Log.Level); err != nil {
+		add("log.level: %v", err)
+	}
+
+	if c.Webhook.StoreSize < 1 {
+		add("webhook.storeSize: must be at least 1, got %d", c.Webhook.StoreSize)
+	}
+	for i, s := range c.Webhook.Secrets {
+		if s == "" {
+			add("webhook.secrets[%d]: must not be empty", i)
+		}
+	}
+	if c.Webhook.PublicBaseURL != "" {
+		u, err := url.Parse(c.Webhook.PublicBaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("webhook.publicBaseUrl: must be an absolute http or https URL, got %q", c.Webhook.PublicBaseURL)
+		}
+	}
+	for i, e := range c.Webhook.Events {
+		if !slices.Contains(enums["webhookEventType"], e) {
+			add("webhook.events[%d]: unknown event type %q", i, e)
+		}
+	}
+
+	for name, d := range map[string]time.Duration{
+		"server.readHeaderTimeout":       c.Server.ReadHeaderTimeout,
+		"server.readTimeout":             c.Server.ReadTimeout,
+		"server.writeTimeout":            c.Server.WriteTimeout,
+		"server.idleTimeout":             c.Server.IdleTimeout,
+		"server.shutdownDrainDelay":      c.Server.ShutdownDrainDelay,
+		"server.shutdownTimeout":         c.Server.ShutdownTimeout,
+		"sponsorState.expireAfter":       c.SponsorState.ExpireAfter,
+		"sponsorState.reconcileInterval": c.SponsorState.ReconcileInterval,
+	} {
+		if d < 0 {
+			add("%s: must not be negative", name)
+		}
+	}
+	if c.SponsorState.MaxUsers < 1 {
+		add("sponsorState.maxUsers: must be at least 1, got %d", c.SponsorState.MaxUsers)
+	}
+	if c.SponsorState.ReconcileBatch < 1 {
+		add("sponsorState.reconcileBatch: must be at least 1, got %d", c.SponsorState.ReconcileBatch)
+	}
+	if c.SponsorState.ReconcileRate <= 0 {
+		add("sponsorState.reconcileRate: must be greater than zero")
+	}
+	if rc := c.Currency.ReportingCurrency; rc != "" && !isCurrencyCode(rc) {
+		add("currency.reportingCurrency: must be an ISO 4217 code, got %q", rc)
+	}
+	if c.Currency.ReportingCurrency != "" && c.Currency.RatesFile == "" {
+		add("currency.reportingCurrency: requires currency.ratesFile")
+	}
+
+	c.Receipts.validate(add)
+
+	if c.Server.ShutdownTimeout == 0 {
+		add("server.shutdownTimeout: must be greater than zero")
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		add("tls: certFile and keyFile must be set together")
+	}
+	if c.TLS.ClientCAFile != "" && !c.TLS.enabled() {
+		add("tls.clientCAFile: requires certFile and keyFile")
+	}
+	if c.TLS.enabled() && c.TLS.ReloadInterval < time.Second {
+		add("tls.reloadInterval: must be at least 1s")
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		add("cors.allowedOrigins: must list at least one origin (use \"*\" for any)")
+	}
+	for i, o := range c.CORS.AllowedOrigins {
+		if o == "*" {
+			continue
+		}
+		if u, err := url.Parse(o); err != nil || u.Scheme == "" || u.Host == "" {
+			add("cors.allowedOrigins[%d]: must be \"*\" or scheme://host, got %q", i, o)
+		}
+	}
+
+	seen := map[string]bool{}
+	for i, cl := range c.Auth.Clients {
+		if cl.Name == "" {
+			add("auth.clients[%d].name: required", i)
+		} else if seen[cl.Name] {
+			add("auth.clients[%d].name: duplicate client %q", i, cl.Name)
+		}
+		seen[cl.Name] = true
+		if len(cl.Key) < 16 {
+			add("auth.clients[%d].key: must be at least 16 characters", i)
+		}
+	}
+
+	if c.RateLimit.Enabled {
+		validateRule := func(field string, rule rateLimitRule) {
+			if rule.Rate <= 0 {
+				add("%s.rate: must be greater than zero", field)
+			}
+			if rule.Burst < 1 {
+				add("%s.burst: must be at least 1", field)
+			}
+			switch rule.Key {
+			case rateLimitByClient, rateLimitByUser, rateLimitByIP:
+			default:
+				add("%s.key: must be %q, %q or %q, got %q", field, rateLimitByClient, rateLimitByUser, rateLimitByIP, rule.Key)
+			}
+		}
+		validateRule("rateLimit.default", c.RateLimit.Default)
+		for i, rt := range c.RateLimit.Routes {
+			field := fmt.Sprintf("rateLimit.routes[%d]", i)
+			if method, path, ok := strings.Cut(rt.Route, " "); !ok || method == "" || !strings.HasPrefix(path, "/") {
+				add("%s.route: must be \"METHOD /path\", got %q", field, rt.Route)
+			}
+			validateRule(field, rt.rateLimitRule)
+		}
+		if up := c.RateLimit.Upstream; up.Rate < 0 {
+			add("rateLimit.upstream.rate: must not be negative")
+		} else if up.Rate > 0 {
+			if up.Burst < 1 {
+				add("rateLimit.upstream.burst: must be at least 1")
+			}
+			if up.Reserve < 0 || up.Reserve >= up.Burst {
+				add("rateLimit.upstream.reserve: must be between 0 and burst-1")
+			}
+		}
+	}
+
+	if g := c.CouponGuard; g.Enabled {
+		if g.MaxFailures < 1 {
+			add("couponGuard.maxFailures: must be at least 1")
+		}
+		if g.IPMaxFailures != 0 && g.IPMaxFailures < g.MaxFailures {
+			add("couponGuard.ipMaxFailures: must be 0 or at least couponGuard.maxFailures")
+		}
+		if g.Window <= 0 {
+			add("couponGuard.window: must be greater than zero")
+		}
+		if g.Lockout <= 0 {
+			add("couponGuard.lockout: must be greater than zero")
+		}
+		if g.MaxLockout < g.Lockout {
+			add("couponGuard.maxLockout: must be at least couponGuard.lockout")
+		}
+		if g.ResetAfter <= 0 {
+			add("couponGuard.resetAfter: must be greater than zero")
+		}
+	}
+
+	// Sort for stable output since durations are checked via a map.
+	sort.Strings(errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// effectiveEnvironment returns the SDK environment, defaulting to live.
+func (c *config) effectiveEnvironment() string {
+	if c.SDK.Environment == "" {
+		return string(playcamp.EnvironmentLive)
+	}
+	return c.SDK.Environment
+}
+
+// effectiveAPIURL returns the base URL the SDK talks to.
+func (c *config) effectiveAPIURL() string {
+	if c.SDK.APIURL != "" {
+		return c.SDK.APIURL
+	}
+	return playcamp.EnvironmentURL(playcamp.Environment(c.effectiveEnvironment()))
+}
+
+// webhookSecrets returns every accepted webhook secret, primary first.
+func (c *config) webhookSecrets() []string {
+	var secrets []string
+	if c.Webhook.Secret != "" {
+		secrets = append(secrets, c.Webhook.Secret)
+	}
+	return append(secrets, c.Webhook.Secrets...)
+}
+
+// masked returns a copy of c that is safe to print.
+func (c *config) masked() config {
+	m := *c
+	m.APIKey = maskSecret(c.APIKey)
+	m.Webhook.Secret = maskSecret(c.Webhook.Secret)
+	m.Webhook.Secrets = make([]string, len(c.Webhook.Secrets))
+	for i, s := range c.Webhook.Secrets {
+		m.Webhook.Secrets[i] = maskSecret(s)
+	}
+	m.Receipts.Signed.Secrets = make([]string, len(c.Receipts.Signed.Secrets))
+	for i, s := range c.Receipts.Signed.Secrets {
+		m.Receipts.Signed.Secrets[i] = maskSecret(s)
+	}
+	m.Auth.Clients = make([]apiClient, len(c.Auth.Clients))
+	for i, cl := range c.Auth.Clients {
+		m.Auth.Clients[i] = apiClient{Name: cl.Name, Key: maskSecret(cl.Key)}
+	}
+	return m
+}
+
+// maskSecret hides all but a short prefix of s. API keys keep their key ID
+// since it identifies the key without granting access.
+func maskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	if keyID, _, ok := strings.Cut(s, ":"); ok {
+		return keyID + ":****"
+	}
+	if len(s) <= 8 {
+		return "****"
+	}
+	return s[:4] + "****"
+}
+
+// structuralChanges lists the structural settings that differ between c and next.
+func (c *config) structuralChanges(next *config) []string {
+	var changed []string
+	if c.Port != next.Port {
+		changed = append(changed, "port")
+	}
+	if c.APIKey != next.APIKey {
+		changed = append(changed, "apiKey")
+	}
+	if c.SDK.Environment != next.SDK.Environment {
+		changed = append(changed, "sdk.environment")
+	}
+	if c.SDK.APIURL != next.SDK.APIURL {
+		changed = append(changed, "sdk.apiUrl")
+	}
+	if c.SDK.RecordCassette != next.SDK.RecordCassette {
+		changed = append(changed, "sdk.recordCassette")
+	}
+	if c.Webhook.PublicBaseURL != next.Webhook.PublicBaseURL {
+		changed = append(changed, "webhook.publicBaseUrl")
+	}
+	if !slices.Equal(c.Webhook.Events, next.Webhook.Events) {
+		changed = append(changed, "webhook.events")
+	}
+	if c.Server != next.Server {
+		changed = append(changed, "server")
+	}
+	if c.TLS != next.TLS {
+		changed = append(changed, "tls")
+	}
+	if c.Revenue != next.Revenue {
+		changed = append(changed, "revenue")
+	}
+	return changed
+}
+
+// equal reports whether c and o configure the same limits.
+func (c rateLimitConfig) equal(o rateLimitConfig) bool {
+	return c.Enabled == o.Enabled && c.Default == o.Default && c.Upstream == o.Upstream && slices.Equal(c.Routes, o.Routes)
+}
+
+// withStructural returns next with the structural settings of c, so a reload
+// only changes what can be changed without a restart.
+func (c *config) withStructural(next *config) *config {
+	merged := *next
+	merged.Port = c.Port
+	merged.APIKey = c.APIKey
+	merged.SDK.Environment = c.SDK.Environment
+	merged.SDK.APIURL = c.SDK.APIURL
+	merged.SDK.RecordCassette = c.SDK.RecordCassette
+	merged.Webhook.PublicBaseURL = c.Webhook.PublicBaseURL
+	merged.Webhook.Events = c.Webhook.Events
+	merged.Server = c.Server
+	merged.TLS = c.TLS
+	merged.Revenue = c.Revenue
+	return &merged
+}
+
+// applyConfig pushes the non-structural settings of cfg into the running app.
+func (a *app) applyConfig(cfg *config) {
+	_ = a.logs.setLevels(cfg.Log.Level) // already validated
+	if cfg.SDK.Debug {
+		a.logs.setDefaultLevel(logSDK, slog.LevelDebug)
+	}
+	a.sdkTransport.logBodies.Store(cfg.SDK.Debug)
+	a.receivedWebhooks.resize(cfg.Webhook.StoreSize)
+	a.captures.resize(cfg.Webhook.StoreSize)
+	a.quarantine.resize(cfg.Receipts.QuarantineSize)
+	a.sponsorState.resize(cfg.SponsorState.MaxUsers)
+	// Resetting drops every bucket, so only do it when the limits changed.
+	if prev := a.cfg.Load(); prev == nil || !prev.RateLimit.equal(cfg.RateLimit) {
+		a.limiter.reset()
+	}
+	a.cfg.Store(cfg)
+}
+
+// reloadConfig re-reads the config file and environment and applies the
+// settings that can change at runtime.
+func (a *app) reloadConfig() {
+	log := a.lifecycle.log
+
+	next, err := loadConfig(a.configFile)
+	if err != nil {
+		log.Error("config reload rejected", "error", err.Error())
+		return
+	}
+
+	rates, err := loadFXRates(next.Currency.RatesFile)
+	if err != nil {
+		log.Error("config reload rejected", "error", err.Error())
+		return
+	}
+	verifiers, err := newReceiptVerifiers(next.Receipts, receiptHTTPClient)
+	if err != nil {
+		log.Error("config reload rejected", "error", err.Error())
+		return
+	}
+	a.fx.Store(rates)
+	a.receipts.Store(verifiers)
+
+	current := a.config()
+	if changed := current.structuralChanges(next); len(changed) > 0 {
+		log.Warn("config reload ignores settings that require a restart", "settings", changed)
+	}
+	next = current.withStructural(next)
+	a.applyConfig(next)
+	log.Info("config reloaded", "file", a.configFile, "config", next.masked())
+}
+
+// splitList splits a comma-separated list, dropping blanks.
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
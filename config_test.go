@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *config)
+		want   []string
+	}{
+		{"defaults", func(c *config) {}, nil},
+		{"http api url", func(c *config) { c.SDK.APIURL = "http://playcamp.internal:3003" }, nil},
+		{"relative api url", func(c *config) { c.SDK.APIURL = "/v1" }, []string{"sdk.apiUrl"}},
+		{"bad port and key", func(c *config) { c.Port = "0"; c.APIKey = "nocolon" }, []string{"port:", "apiKey:"}},
+		{"unknown environment", func(c *config) { c.SDK.Environment = "prod" }, []string{"sdk.environment"}},
+		{"bad log level", func(c *config) { c.Log.Level = "sdk=loud" }, []string{"log.level"}},
+		{"empty store", func(c *config) { c.Webhook.StoreSize = 0 }, []string{"webhook.storeSize"}},
+		{"unknown event", func(c *config) { c.Webhook.Events = []string{"payment.lost"} }, []string{"webhook.events[0]"}},
+		{"negative timeout", func(c *config) { c.Server.ReadTimeout = -time.Second }, []string{"server.readTimeout"}},
+		{"key without cert", func(c *config) { c.TLS.KeyFile = "server.key" }, []string{"tls: certFile and keyFile"}},
+		{"client CA without TLS", func(c *config) { c.TLS.ClientCAFile = "ca.pem" }, []string{"tls.clientCAFile"}},
+		{"duplicate client", func(c *config) {
+			c.Auth.Clients = []apiClient{{Name: "game", Key: "0123456789abcdef"}, {Name: "game", Key: "short"}}
+		}, []string{"auth.clients[1].name", "auth.clients[1].key"}},
+		{"bad rate limit route", func(c *config) {
+			c.RateLimit.Routes = []rateLimitRoute{{Route: "/api/payments", rateLimitRule: rateLimitRule{Rate: 1, Burst: 1, Key: "token"}}}
+		}, []string{"rateLimit.routes[0].route", "rateLimit.routes[0].key"}},
+		{"reserve above burst", func(c *config) { c.RateLimit.Upstream = upstreamBudget{Rate: 10, Burst: 5, Reserve: 5} }, []string{"rateLimit.upstream.reserve"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := defaultConfig()
+			cfg.APIKey = testAPIKey
+			tc.change(cfg)
+			err := cfg.validate()
+			if len(tc.want) == 0 {
+				if err != nil {
+					t.Fatalf("validate = %v", err)
+				}
+				return
+			}
+			var errs configErrors
+			if !errors.As(err, &errs) || len(errs) != len(tc.want) {
+				t.Fatalf("validate = %v, want %d errors", err, len(tc.want))
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("validate = %v, want %q", err, w)
+				}
+			}
+		})
+	}
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Setenv("SERVER_API_KEY", testAPIKey)
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{"yaml", "config.yaml", "port: \"5000\"\nwebhook:\n  storeSize: 7\nserver:\n  readTimeout: 3s\n", ""},
+		{"toml", "config.toml", "port = \"5000\"\n[webhook]\nstoreSize = 7\n[server]\nreadTimeout = \"3s\"\n", ""},
+		{"empty yaml", "config.yaml", "", ""},
+		{"unknown yaml key", "config.yaml", "webhook:\n  storSize: 7\n", "storSize"},
+		{"unknown toml key", "config.toml", "[webhook]\nstorSize = 7\n", "webhook.storSize"},
+		{"invalid value", "config.toml", "[webhook]\nstoreSize = 0\n", "webhook.storeSize"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := loadConfig(writeConfigFile(t, tc.file, tc.content))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("loadConfig = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.content == "" {
+				return
+			}
+			if cfg.Port != "5000" || cfg.Webhook.StoreSize != 7 || cfg.Server.ReadTimeout != 3*time.Second {
+				t.Errorf("config = %+v", cfg)
+			}
+		})
+	}
+
+	// Environment variables win over the file.
+	t.Setenv("PORT", "6000")
+	cfg, err := loadConfig(writeConfigFile(t, "config.toml", "port = \"5000\"\n"))
+	if err != nil || cfg.Port != "6000" {
+		t.Errorf("port = %v, %v; want env override", cfg, err)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	t.Setenv("SERVER_API_KEY", testAPIKey)
+	t.Setenv("SDK_API_URL", fake.URL)
+	path := writeConfigFile(t, "config.yaml", "port: \"5000\"\nlog:\n  level: info\n")
+	cfg, err := loadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := newApp(cfg, path, newLogRegistry(io.Discard))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.limiter.allow("client:game", rateLimitRule{Rate: 1, Burst: 1})
+	buckets := func() int {
+		a.limiter.mu.Lock()
+		defer a.limiter.mu.Unlock()
+		return len(a.limiter.buckets)
+	}
+
+	tests := []struct {
+		name        string
+		content     string
+		wantPort    string
+		wantStore   int
+		wantBuckets int
+	}{
+		{"runtime setting", "port: \"5000\"\nwebhook:\n  storeSize: 9\n", "5000", 9, 1},
+		{"structural setting kept", "port: \"5001\"\nwebhook:\n  storeSize: 9\n", "5000", 9, 1},
+		{"invalid file rejected", "webhook:\n  storeSize: -1\n", "5000", 9, 1},
+		{"rate limit change", "port: \"5000\"\nwebhook:\n  storeSize: 9\nrateLimit:\n  default:\n    rate: 2\n", "5000", 9, 0},
+	}
+	for _, tc := range tests {
+		if err := os.WriteFile(path, []byte(tc.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		a.reloadConfig()
+		got := a.config()
+		if got.Port != tc.wantPort || got.Webhook.StoreSize != tc.wantStore || buckets() != tc.wantBuckets {
+			t.Errorf("%s: port %s, storeSize %d, buckets %d; want %s, %d, %d", tc.name, got.Port, got.Webhook.StoreSize, buckets(), tc.wantPort, tc.wantStore, tc.wantBuckets)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// couponGuardSweepInterval is how often idle lockout entries are dropped.
+const couponGuardSweepInterval = time.Minute
+
+// couponGuard tracks failed coupon attempts per user and per IP and locks out
+// keys that keep guessing. Users are scoped to the authenticated API client, so
+// one game cannot lock out another's players. Game servers call on behalf of
+// many players from one address, so IPs have their own, higher threshold, and
+// are only reported when it is zero. Each lockout in a row lasts twice as long
+// as the previous one.
+type couponGuard struct {
+	log *slog.Logger
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*lockoutEntry
+}
+
+// lockoutEntry is the attempt history of one key: "client:<name>:user:<id>",
+// "user:<id>" when auth is off, or "ip:<addr>".
+type lockoutEntry struct {
+	failures    int
+	strikes     int
+	windowStart time.Time
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// lockoutView is the JSON form of a lockoutEntry.
+type lockoutView struct {
+	Key           string  `json:"key"`
+	Locked        bool    `json:"locked"`
+	LockedUntil   *string `json:"lockedUntil,omitempty"`
+	Failures      int     `json:"failures"`
+	Strikes       int     `json:"strikes"`
+	LastFailureAt string  `json:"lastFailureAt"`
+}
+
+func newCouponGuard(log *slog.Logger) *couponGuard {
+	return &couponGuard{log: log, now: time.Now, entries: map[string]*lockoutEntry{}}
+}
+
+// lockoutKey returns the key an attempt by userID counts against.
+func lockoutKey(r *http.Request, userID string) string {
+	if client := clientFromContext(r.Context()); client != "" {
+		return "client:" + client + ":user:" + userID
+	}
+	return "user:" + userID
+}
+
+// ipLockoutKey returns the key an attempt from r's address counts against.
+func ipLockoutKey(r *http.Request) string {
+	return "ip:" + remoteIP(r)
+}
+
+// lockedUntil returns when key's lockout ends, or the zero time if it is not
+// locked.
+func (g *couponGuard) lockedUntil(key string) time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if e, ok := g.entries[key]; ok && e.lockedUntil.After(g.now()) {
+		return e.lockedUntil
+	}
+	return time.Time{}
+}
+
+// recordFailure counts a failed attempt against key, locking it out after
+// maxFailures failures. With maxFailures zero the key is never locked.
+func (g *couponGuard) recordFailure(cfg couponGuardConfig, key string, maxFailures int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	e, ok := g.entries[key]
+	if !ok {
+		e = &lockoutEntry{windowStart: now}
+		g.entries[key] = e
+	}
+	if now.Sub(e.lastFailure) > cfg.ResetAfter {
+		e.strikes = 0
+	}
+	if now.Sub(e.windowStart) > cfg.Window {
+		e.failures = 0
+		e.windowStart = now
+	}
+	e.failures++
+	e.lastFailure = now
+
+	if maxFailures > 0 && e.failures >= maxFailures {
+		e.strikes++
+		d := cfg.Lockout << (e.strikes - 1)
+		if d > cfg.MaxLockout || d <= 0 {
+			d = cfg.MaxLockout
+		}
+		e.lockedUntil = now.Add(d)
+		e.failures = 0
+		e.windowStart = now
+		g.log.Warn("coupon attempts locked out", "key", key, "strikes", e.strikes, "lockedUntil", e.lockedUntil.UTC().Format(time.RFC3339))
+	}
+}
+
+// recordSuccess clears the failure count of key unless it is locked out.
+func (g *couponGuard) recordSuccess(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if e, ok := g.entries[key]; ok && e.lockedUntil.Before(g.now()) {
+		e.failures = 0
+	}
+}
+
+// list returns every tracked key, locked ones first.
+func (g *couponGuard) list() []lockoutView {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	views := make([]lockoutView, 0, len(g.entries))
+	for k, e := range g.entries {
+		views = append(views, e.view(k, now))
+	}
+	sort.Slice(views, func(i, j int) bool {
+		if views[i].Locked != views[j].Locked {
+			return views[i].Locked
+		}
+		return views[i].Key < views[j].Key
+	})
+	return views
+}
+
+// get returns the entry for key.
+func (g *couponGuard) get(key string) (lockoutView, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	e, ok := g.entries[key]
+	if !ok {
+		return lockoutView{}, false
+	}
+	return e.view(key, g.now()), true
+}
+
+// clear forgets key, or every key when key is empty. It reports whether
+// anything was removed.
+func (g *couponGuard) clear(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if key == "" {
+		removed := len(g.entries) > 0
+		g.entries = map[string]*lockoutEntry{}
+		return removed
+	}
+	_, ok := g.entries[key]
+	delete(g.entries, key)
+	return ok
+}
+
+func (e *lockoutEntry) view(key string, now time.Time) lockoutView {
+	v := lockoutView{
+		Key:           key,
+		Failures:      e.failures,
+		Strikes:       e.strikes,
+		LastFailureAt: e.lastFailure.UTC().Format(time.RFC3339),
+	}
+	if e.lockedUntil.After(now) {
+		until := e.lockedUntil.UTC().Format(time.RFC3339)
+		v.Locked = true
+		v.LockedUntil = &until
+	}
+	return v
+}
+
+// sweep drops entries with no lockout whose strikes have expired.
+func (g *couponGuard) sweep(resetAfter time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	for k, e := range g.entries {
+		if e.lockedUntil.Before(now) && now.Sub(e.lastFailure) > resetAfter {
+			delete(g.entries, k)
+		}
+	}
+}
+
+// runCouponGuardSweeper sweeps idle entries until ctx is done.
+func (a *app) runCouponGuardSweeper(ctx context.Context) {
+	ticker := time.NewTicker(couponGuardSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.couponGuard.sweep(a.config().CouponGuard.ResetAfter)
+		}
+	}
+}
+
+// rejectIfLockedOut writes a 429 and returns true when key or r's address is
+// locked out.
+func (a *app) rejectIfLockedOut(w http.ResponseWriter, r *http.Request, key string) bool {
+	if !a.config().CouponGuard.Enabled {
+		return false
+	}
+	until := a.couponGuard.lockedUntil(key)
+	if ip := a.couponGuard.lockedUntil(ipLockoutKey(r)); ip.After(until) {
+		until = ip
+	}
+	if until.IsZero() {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(time.Until(until)))))
+	writeProblem(w, newProblem(r, problemLockedOut, http.StatusTooManyRequests,
+		"too many failed coupon attempts; try again after "+until.UTC().Format(time.RFC3339)))
+	return true
+}
+
+// recordCouponAttempt updates the guard with the outcome of an attempt. A
+// success clears the user's failures but not the address's, so valid codes
+// cannot hide guessing from many user IDs.
+func (a *app) recordCouponAttempt(r *http.Request, key string, failed bool) {
+	cfg := a.config().CouponGuard
+	if !cfg.Enabled {
+		return
+	}
+	if failed {
+		a.couponGuard.recordFailure(cfg, key, cfg.MaxFailures)
+		a.couponGuard.recordFailure(cfg, ipLockoutKey(r), cfg.IPMaxFailures)
+		return
+	}
+	a.couponGuard.recordSuccess(key)
+}
+
+// --- Handlers ---
+
+// handleListCouponLockouts handles GET /api/coupons/lockouts
+func (a *app) handleListCouponLockouts(w http.ResponseWriter, r *http.Request) {
+	views := a.couponGuard.list()
+	if r.URL.Query().Get("locked") == "true" {
+		locked := views[:0]
+		for _, v := range views {
+			if v.Locked {
+				locked = append(locked, v)
+			}
+		}
+		views = locked
+	}
+	writeJSON(w, http.StatusOK, views)
+}
+
+// handleGetCouponLockout handles GET /api/coupons/lockouts/{key}
+func (a *app) handleGetCouponLockout(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	v, ok := a.couponGuard.get(key)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "no attempts recorded for "+key)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+// handleClearCouponLockouts handles DELETE /api/coupons/lockouts
+func (a *app) handleClearCouponLockouts(w http.ResponseWriter, r *http.Request) {
+	a.couponGuard.clear("")
+	writeJSON(w, http.StatusOK, map[string]bool{"cleared": true})
+}
+
+// handleClearCouponLockout handles DELETE /api/coupons/lockouts/{key}
+func (a *app) handleClearCouponLockout(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if !strings.HasPrefix(key, "user:") && !strings.HasPrefix(key, "client:") && !strings.HasPrefix(key, "ip:") {
+		writeError(w, r, http.StatusBadRequest, "key must be client:<name>:user:<userId>, user:<userId> or ip:<addr>")
+		return
+	}
+	if !a.couponGuard.clear(key) {
+		writeError(w, r, http.StatusNotFound, "no attempts recorded for "+key)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"cleared": true})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockoutKey(t *testing.T) {
+	tests := []struct {
+		client string
+		userID string
+		want   string
+	}{
+		{"game", "u1", "client:game:user:u1"},
+		{"admin", "u1", "client:admin:user:u1"},
+		{"", "u1", "user:u1"},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/coupons/validate", nil)
+		if tc.client != "" {
+			r = r.WithContext(context.WithValue(r.Context(), clientKey{}, tc.client))
+		}
+		if got := lockoutKey(r, tc.userID); got != tc.want {
+			t.Errorf("lockoutKey(%q, %q) = %q, want %q", tc.client, tc.userID, got, tc.want)
+		}
+	}
+}
+
+func TestCouponGuard(t *testing.T) {
+	cfg := couponGuardConfig{Enabled: true, MaxFailures: 2, Window: time.Minute, Lockout: time.Minute, MaxLockout: 3 * time.Minute, ResetAfter: time.Hour}
+	g := newCouponGuard(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	now := time.Unix(1700000000, 0)
+	g.now = func() time.Time { return now }
+	const key = "client:game:user:u1"
+
+	// Each step advances the clock, then records a failure or a success.
+	tests := []struct {
+		name       string
+		advance    time.Duration
+		fail       bool
+		wantLocked time.Duration // remaining lockout, 0 when not locked
+		wantStrike int
+	}{
+		{"first failure", 0, true, 0, 0},
+		{"second failure locks", time.Second, true, time.Minute, 1},
+		{"lockout expires", time.Minute, false, 0, 1},
+		{"failure outside window", 2 * time.Minute, true, 0, 1},
+		{"second lockout doubles", time.Second, true, 2 * time.Minute, 2},
+		{"lockout expires again", 2 * time.Minute, true, 0, 2},
+		{"third lockout capped", time.Second, true, 3 * time.Minute, 3},
+		{"strikes forgotten", 2 * time.Hour, true, 0, 0},
+		{"success clears failures", time.Second, false, 0, 0},
+		{"count restarts", time.Second, true, 0, 0},
+	}
+	for _, tc := range tests {
+		now = now.Add(tc.advance)
+		if tc.fail {
+			g.recordFailure(cfg, key, cfg.MaxFailures)
+		} else {
+			g.recordSuccess(key)
+		}
+		var locked time.Duration
+		if until := g.lockedUntil(key); !until.IsZero() {
+			locked = until.Sub(now)
+		}
+		v, _ := g.get(key)
+		if locked != tc.wantLocked || v.Strikes != tc.wantStrike {
+			t.Errorf("%s: locked %v, strikes %d; want %v, %d", tc.name, locked, v.Strikes, tc.wantLocked, tc.wantStrike)
+		}
+	}
+	if v, _ := g.get(key); v.Failures != 1 {
+		t.Errorf("failures = %d, want 1", v.Failures)
+	}
+
+	// Other users of the same client are unaffected.
+	if !g.lockedUntil("client:game:user:u2").IsZero() {
+		t.Error("u2 locked out")
+	}
+
+	g.sweep(cfg.ResetAfter)
+	if _, ok := g.get(key); !ok {
+		t.Error("recent entry swept")
+	}
+	now = now.Add(2 * time.Hour)
+	g.sweep(cfg.ResetAfter)
+	if len(g.list()) != 0 {
+		t.Errorf("entries after sweep = %+v", g.list())
+	}
+}
+
+func TestCouponLockoutPerClient(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodPost, "/v1/server/coupons/validate", http.StatusOK, `{"data":{"valid":false,"errorCode":"NOT_FOUND"}}`)
+	a := newTestApp(t, fake, nil)
+	cfg := *a.config()
+	cfg.Auth.Clients = []apiClient{{Name: "game", Key: "game-key-0123456789"}, {Name: "admin", Key: "admin-key-0123456789"}}
+	a.cfg.Store(&cfg)
+
+	send := func(key, userID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/coupons/validate", strings.NewReader(`{"couponCode":"GUESS","userId":"`+userID+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		a.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	for i := 0; i < cfg.CouponGuard.MaxFailures; i++ {
+		send("game-key-0123456789", "u1")
+	}
+
+	// Every request comes from the same address; only game's u1 is locked.
+	tests := []struct {
+		name   string
+		key    string
+		userID string
+		want   int
+	}{
+		{"locked user", "game-key-0123456789", "u1", http.StatusTooManyRequests},
+		{"other user, same client and IP", "game-key-0123456789", "u2", http.StatusOK},
+		{"same user id, other client", "admin-key-0123456789", "u1", http.StatusOK},
+	}
+	for _, tc := range tests {
+		if got := send(tc.key, tc.userID); got != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+
+	if v, ok := a.couponGuard.get("client:game:user:u1"); !ok || !v.Locked {
+		t.Errorf("lockout = %+v, %v", v, ok)
+	}
+}
+
+func TestCouponLockoutPerIP(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodPost, "/v1/server/coupons/validate", http.StatusOK, `{"data":{"valid":false,"errorCode":"NOT_FOUND"}}`)
+	a := newTestApp(t, fake, nil)
+
+	send := func(ip, userID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/api/coupons/validate", strings.NewReader(`{"couponCode":"GUESS","userId":"`+userID+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		a.router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	guess := func(ip string, n int) {
+		for i := 0; i < n; i++ {
+			send(ip, fmt.Sprintf("guess-%d", i))
+		}
+	}
+
+	tests := []struct {
+		name          string
+		ipMaxFailures int
+		guesses       int
+		want          int
+		wantLocked    bool
+	}{
+		{"below threshold", 8, 7, http.StatusOK, false},
+		{"at threshold", 8, 8, http.StatusTooManyRequests, true},
+		{"report only", 0, 20, http.StatusOK, false},
+	}
+	for _, tc := range tests {
+		cfg := *a.config()
+		cfg.CouponGuard.IPMaxFailures = tc.ipMaxFailures
+		a.cfg.Store(&cfg)
+		a.couponGuard.clear("")
+
+		// Each user fails only once; only the address adds up.
+		guess("203.0.113.7", tc.guesses)
+		v, ok := a.couponGuard.get("ip:203.0.113.7")
+		if !ok || v.Locked != tc.wantLocked {
+			t.Errorf("%s: ip entry = %+v, %v", tc.name, v, ok)
+		}
+		if got := send("203.0.113.7", "fresh"); got != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, got, tc.want)
+		}
+		if got := send("198.51.100.1", "fresh"); got != http.StatusOK {
+			t.Errorf("%s: other IP status = %d, want 200", tc.name, got)
+		}
+	}
+}
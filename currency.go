@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// iso4217MinorUnits maps each active ISO 4217 currency code to the number of
+// digits after the decimal separator in its minor unit.
+var iso4217MinorUnits = map[string]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2,
+	"AWG": 2, "AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3, "BIF": 0,
+	"BMD": 2, "BND": 2, "BOB": 2, "BRL": 2, "BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2,
+	"BZD": 2, "CAD": 2, "CDF": 2, "CHF": 2, "CLP": 0, "CNY": 2, "COP": 2, "CRC": 2,
+	"CUP": 2, "CVE": 2, "CZK": 2, "DJF": 0, "DKK": 2, "DOP": 2, "DZD": 2, "EGP": 2,
+	"ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2,
+	"GIP": 2, "GMD": 2, "GNF": 0, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2,
+	"HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "IQD": 3, "IRR": 2, "ISK": 0, "JMD": 2,
+	"JOD": 3, "JPY": 0, "KES": 2, "KGS": 2, "KHR": 2, "KMF": 0, "KPW": 2, "KRW": 0,
+	"KWD": 3, "KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2, "LSL": 2,
+	"LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2, "MOP": 2,
+	"MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MYR": 2, "MZN": 2, "NAD": 2,
+	"NGN": 2, "NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2, "PEN": 2,
+	"PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2, "PYG": 0, "QAR": 2, "RON": 2, "RSD": 2,
+	"RUB": 2, "RWF": 0, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2,
+	"SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2, "SYP": 2,
+	"SZL": 2, "THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2, "TRY": 2, "TTD": 2,
+	"TWD": 2, "TZS": 2, "UAH": 2, "UGX": 0, "USD": 2, "UYU": 2, "UZS": 2, "VES": 2,
+	"VND": 0, "VUV": 0, "WST": 2, "XAF": 0, "XCD": 2, "XOF": 0, "XPF": 0, "YER": 2,
+	"ZAR": 2, "ZMW": 2, "ZWG": 2,
+}
+
+// isCurrencyCode reports whether code is an active ISO 4217 code.
+func isCurrencyCode(code string) bool {
+	_, ok := iso4217MinorUnits[code]
+	return ok
+}
+
+// minorUnitDigits returns the minor-unit digits of currency, assuming two for
+// codes outside ISO 4217.
+func minorUnitDigits(currency string) int {
+	if d, ok := iso4217MinorUnits[currency]; ok {
+		return d
+	}
+	return 2
+}
+
+// money is an exact amount in the minor unit of its currency, such as cents
+// or won. Sums of money never drift the way float64 sums do.
+type money struct {
+	Currency string
+	Minor    int64
+}
+
+// moneyFromFloat converts a major-unit amount, as the SDK carries it, to
+// money. The float's shortest decimal form is used, so 1.005 is 1.005 rather
+// than 1.00499999999999989..., then rounded half away from zero to the
+// currency's minor unit.
+func moneyFromFloat(amount float64, currency string) money {
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(amount, 'f', -1, 64))
+	return money{Currency: currency, Minor: roundRat(r.Mul(r, pow10Rat(minorUnitDigits(currency))))}
+}
+
+// parseMoney parses a decimal string such as "12.34". More decimal places
+// than the currency has are an error, not rounded away.
+func parseMoney(s, currency string) (money, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "/eE") {
+		return money{}, fmt.Errorf("%q is not a decimal amount", s)
+	}
+	r.Mul(r, pow10Rat(minorUnitDigits(currency)))
+	if !r.IsInt() {
+		return money{}, fmt.Errorf("%s has more decimal places than %s allows (%d)", s, currency, minorUnitDigits(currency))
+	}
+	if !r.Num().IsInt64() {
+		return money{}, fmt.Errorf("%s is out of range", s)
+	}
+	return money{Currency: currency, Minor: r.Num().Int64()}, nil
+}
+
+// String formats m in major units with the currency's decimal places.
+func (m money) String() string {
+	digits := minorUnitDigits(m.Currency)
+	v, sign := m.Minor, ""
+	if v < 0 {
+		sign, v = "-", -v
+	}
+	s := strconv.FormatInt(v, 10)
+	if digits == 0 {
+		return sign + s
+	}
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+	return sign + s[:len(s)-digits] + "." + s[len(s)-digits:]
+}
+
+// Float returns m in major units, for SDK parameters.
+func (m money) Float() float64 {
+	f, _ := strconv.ParseFloat(m.String(), 64)
+	return f
+}
+
+func pow10Rat(n int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+}
+
+// roundRat rounds r to an integer, half away from zero.
+func roundRat(r *big.Rat) int64 {
+	num, den := new(big.Int).Set(r.Num()), r.Denom()
+	neg := num.Sign() < 0
+	num.Abs(num)
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Mul(rem, big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if neg {
+		q.Neg(q)
+	}
+	return q.Int64()
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestMoney(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     string
+	}{
+		{9.99, "USD", "9.99"},
+		{0.05, "USD", "0.05"},
+		{1.005, "USD", "1.01"},
+		{5900, "KRW", "5900"},
+		{1.234, "BHD", "1.234"},
+		{-4.5, "EUR", "-4.50"},
+		{-0.125, "EUR", "-0.13"},
+	}
+	for _, tc := range tests {
+		if got := moneyFromFloat(tc.amount, tc.currency).String(); got != tc.want {
+			t.Errorf("%v %s = %q, want %q", tc.amount, tc.currency, got, tc.want)
+		}
+	}
+
+	for s, ok := range map[string]bool{"12.34": true, "12": true, "-0.5": true, "12.345": false, "1e3": false, "": false, "abc": false} {
+		if _, err := parseMoney(s, "USD"); (err == nil) != ok {
+			t.Errorf("parseMoney(%q) err = %v, want ok %v", s, err, ok)
+		}
+	}
+	if m, _ := parseMoney("1200", "KRW"); m.Minor != 1200 {
+		t.Errorf("1200 KRW minor = %d", m.Minor)
+	}
+}
@@ -1,14 +1,115 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 
+	"github.com/go-chi/chi/v5/middleware"
 	playcamp "github.com/playcamp/playcamp-go-sdk"
 )
 
+// problemTypePrefix prefixes every problem type URI. The suffixes are part of
+// the API contract: clients switch on them, so never rename one.
+const problemTypePrefix = "urn:playcamp:problem:"
+
+// Problem types, one per error class.
+const (
+	problemBadRequest   = problemTypePrefix + "bad-request"
+	problemInvalidInput = problemTypePrefix + "invalid-input"
+	problemUnauthorized = problemTypePrefix + "unauthorized"
+	problemForbidden    = problemTypePrefix + "forbidden"
+	problemNotFound     = problemTypePrefix + "not-found"
+	problemMethod       = problemTypePrefix + "method-not-allowed"
+	problemConflict     = problemTypePrefix + "conflict"
+	problemValidation   = problemTypePrefix + "validation-failed"
+	problemRateLimited  = problemTypePrefix + "rate-limited"
+	problemLockedOut    = problemTypePrefix + "coupon-locked-out"
+	problemReceipt      = problemTypePrefix + "receipt-rejected"
+	problemReceiptCheck = problemTypePrefix + "receipt-unverified"
+	problemUpstream     = problemTypePrefix + "upstream-error"
+	problemUnreachable  = problemTypePrefix + "upstream-unreachable"
+	problemUnavailable  = problemTypePrefix + "unavailable"
+	problemInternal     = problemTypePrefix + "internal"
+)
+
+// problem is an RFC 9457 (formerly 7807) problem details object.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Code is the PlayCamp error code, when the upstream sent one.
+	Code string `json:"code,omitempty"`
+	// UpstreamStatus is the status PlayCamp responded with.
+	UpstreamStatus int            `json:"upstreamStatus,omitempty"`
+	Errors         []problemField `json:"errors,omitempty"`
+	RequestID      string         `json:"requestId,omitempty"`
+	IsTest         bool           `json:"isTest"`
+}
+
+// problemField is a single field-level validation failure.
+type problemField struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Target  string `json:"target,omitempty"`
+}
+
+// problemTypeForStatus picks the problem type for locally generated errors.
+func problemTypeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return problemBadRequest
+	case http.StatusUnauthorized:
+		return problemUnauthorized
+	case http.StatusForbidden:
+		return problemForbidden
+	case http.StatusNotFound:
+		return problemNotFound
+	case http.StatusMethodNotAllowed:
+		return problemMethod
+	case http.StatusConflict:
+		return problemConflict
+	case http.StatusUnprocessableEntity:
+		return problemValidation
+	case http.StatusTooManyRequests:
+		return problemRateLimited
+	case http.StatusBadGateway:
+		return problemUnreachable
+	case http.StatusServiceUnavailable:
+		return problemUnavailable
+	}
+	if status >= 500 {
+		return problemInternal
+	}
+	return problemBadRequest
+}
+
+// newProblem fills in the fields every problem carries.
+func newProblem(r *http.Request, typ string, status int, detail string) *problem {
+	return &problem{
+		Type:      typ,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: middleware.GetReqID(r.Context()),
+		IsTest:    isTestRequest(r),
+	}
+}
+
+// writeProblem writes p as application/problem+json.
+func writeProblem(w http.ResponseWriter, p *problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
 // handleSDKError maps an SDK error to the appropriate HTTP status code and writes the error response.
-func handleSDKError(w http.ResponseWriter, err error) {
+func handleSDKError(w http.ResponseWriter, r *http.Request, err error) {
 	var (
 		badReqErr     *playcamp.BadRequestError
 		authErr       *playcamp.AuthError
@@ -24,26 +125,78 @@ func handleSDKError(w http.ResponseWriter, err error) {
 
 	switch {
 	case errors.As(err, &badReqErr):
-		writeError(w, http.StatusBadRequest, badReqErr.Message)
+		writeProblem(w, upstreamProblem(r, problemBadRequest, http.StatusBadRequest, &badReqErr.APIError))
 	case errors.As(err, &authErr):
-		writeError(w, http.StatusUnauthorized, authErr.Message)
+		writeProblem(w, upstreamProblem(r, problemUnauthorized, http.StatusUnauthorized, &authErr.APIError))
 	case errors.As(err, &forbiddenErr):
-		writeError(w, http.StatusForbidden, forbiddenErr.Message)
+		writeProblem(w, upstreamProblem(r, problemForbidden, http.StatusForbidden, &forbiddenErr.APIError))
 	case errors.As(err, &notFoundErr):
-		writeError(w, http.StatusNotFound, notFoundErr.Message)
+		writeProblem(w, upstreamProblem(r, problemNotFound, http.StatusNotFound, &notFoundErr.APIError))
 	case errors.As(err, &conflictErr):
-		writeError(w, http.StatusConflict, conflictErr.Message)
+		writeProblem(w, upstreamProblem(r, problemConflict, http.StatusConflict, &conflictErr.APIError))
 	case errors.As(err, &validationErr):
-		writeError(w, http.StatusUnprocessableEntity, validationErr.Message)
+		writeProblem(w, upstreamProblem(r, problemValidation, http.StatusUnprocessableEntity, &validationErr.APIError))
 	case errors.As(err, &rateLimitErr):
-		writeError(w, http.StatusTooManyRequests, rateLimitErr.Message)
+		writeProblem(w, upstreamProblem(r, problemRateLimited, http.StatusTooManyRequests, &rateLimitErr.APIError))
 	case errors.As(err, &networkErr):
-		writeError(w, http.StatusBadGateway, networkErr.Message)
+		writeProblem(w, newProblem(r, problemUnreachable, http.StatusBadGateway, networkErr.Message))
 	case errors.As(err, &inputErr):
-		writeError(w, http.StatusBadRequest, inputErr.Error())
+		p := newProblem(r, problemInvalidInput, http.StatusBadRequest, inputErr.Message)
+		p.Errors = []problemField{{Field: inputErr.Field, Message: inputErr.Message}}
+		writeProblem(w, p)
 	case errors.As(err, &apiErr):
-		writeError(w, apiErr.StatusCode, apiErr.Message)
+		writeProblem(w, upstreamProblem(r, problemUpstream, apiErr.StatusCode, apiErr))
 	default:
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+	}
+}
+
+// isNotFound reports whether err is a PlayCamp 404.
+func isNotFound(err error) bool {
+	var notFoundErr *playcamp.NotFoundError
+	return errors.As(err, &notFoundErr)
+}
+
+// upstreamProblem builds a problem from a PlayCamp API error, carrying over
+// its code, status and field-level details.
+func upstreamProblem(r *http.Request, typ string, status int, e *playcamp.APIError) *problem {
+	p := newProblem(r, typ, status, e.Message)
+	p.Code = e.Code
+	p.UpstreamStatus = e.StatusCode
+	for _, d := range e.Details {
+		p.Errors = append(p.Errors, problemField{Field: d.Path, Message: d.Message, Target: d.Target})
+	}
+	return p
+}
+
+// --- Request state ---
+
+// requestState is mutable per-request state that handlers record for the
+// error writer.
+type requestState struct {
+	isTest bool
+}
+
+type requestStateKey struct{}
+
+// trackRequestState installs an empty requestState on every request.
+func trackRequestState(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), requestStateKey{}, &requestState{})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func stateFromRequest(r *http.Request) *requestState {
+	st, _ := r.Context().Value(requestStateKey{}).(*requestState)
+	return st
+}
+
+// isTestRequest reports whether the request was served by the test-mode SDK.
+// Before an SDK has been chosen it falls back to the isTest query parameter.
+func isTestRequest(r *http.Request) bool {
+	if st := stateFromRequest(r); st != nil && st.isTest {
+		return true
 	}
+	return isTestFromQuery(r)
 }
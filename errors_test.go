@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// TestSDKErrorMapping covers every error class handleSDKError maps. POST
+// routes are used so the SDK does not retry 429 and 5xx responses.
+func TestSDKErrorMapping(t *testing.T) {
+	tests := []struct {
+		status      int
+		body        string
+		wantStatus  int
+		wantProblem string
+	}{
+		{400, `{"code":"BAD_REQUEST","message":"bad"}`, 400, "bad-request"},
+		{401, `{"code":"UNAUTHORIZED","message":"invalid key"}`, 401, "unauthorized"},
+		{403, `{"code":"FORBIDDEN","message":"no access"}`, 403, "forbidden"},
+		{404, `{"code":"NOT_FOUND","message":"missing"}`, 404, "not-found"},
+		{409, `{"code":"DUPLICATE_TRANSACTION","message":"exists"}`, 409, "conflict"},
+		{422, `{"code":"VALIDATION_ERROR","message":"invalid","details":[{"path":"amount","message":"must be positive"}]}`, 422, "validation-failed"},
+		{429, `{"code":"RATE_LIMITED","message":"slow down"}`, 429, "rate-limited"},
+		{500, `{"error":"boom"}`, 500, "upstream-error"},
+		{503, `not json`, 503, "upstream-error"},
+	}
+
+	for _, tc := range tests {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			fake := newFakePlayCamp(t)
+			fake.on(http.MethodPost, "/v1/server/payments", tc.status, tc.body)
+			a := newTestApp(t, fake, nil)
+
+			rec := serve(a, http.MethodPost, "/api/payments", testPayment)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tc.wantStatus, rec.Body)
+			}
+			p := decodeProblem(t, rec)
+			if p.Type != problemTypePrefix+tc.wantProblem {
+				t.Errorf("type = %q, want %q", p.Type, tc.wantProblem)
+			}
+			if p.UpstreamStatus != tc.status {
+				t.Errorf("upstreamStatus = %d, want %d", p.UpstreamStatus, tc.status)
+			}
+			if p.Detail == "" || p.Instance != "/api/payments" || p.RequestID == "" {
+				t.Errorf("problem = %+v", p)
+			}
+			if tc.status == 422 && (len(p.Errors) != 1 || p.Errors[0].Field != "amount") {
+				t.Errorf("errors = %+v", p.Errors)
+			}
+		})
+	}
+}
+
+func TestSDKNetworkError(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	a := newTestApp(t, fake, nil)
+	fake.Close()
+
+	rec := serve(a, http.MethodPost, "/api/payments", testPayment)
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502; body %s", rec.Code, rec.Body)
+	}
+	if p := decodeProblem(t, rec); p.Type != problemUnreachable {
+		t.Errorf("type = %q", p.Type)
+	}
+}
+
+// TestLocalSDKErrors covers errors the SDK raises without calling PlayCamp.
+func TestLocalSDKErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantProblem string
+	}{
+		{"input validation", &playcamp.InputValidationError{Field: "userId", Message: "must be a non-empty string"}, 400, problemInvalidInput},
+		{"unexpected", errors.New("playcamp: failed to decode response"), 500, problemInternal},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handleSDKError(rec, httptest.NewRequest(http.MethodGet, "/api/campaigns", nil), tc.err)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			p := decodeProblem(t, rec)
+			if p.Type != tc.wantProblem {
+				t.Errorf("type = %q, want %q", p.Type, tc.wantProblem)
+			}
+			if tc.wantStatus == 400 && (len(p.Errors) != 1 || p.Errors[0].Field != "userId") {
+				t.Errorf("errors = %+v", p.Errors)
+			}
+		})
+	}
+}
+
+// TestTestModeSwitching checks that getSDK picks the test-mode instance from
+// the query string or the body, and that errors report it.
+func TestTestModeSwitching(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		upstream string
+		wantTest bool
+	}{
+		{"query off", http.MethodGet, "/api/campaigns/c1", "", "/v1/server/campaigns/c1", false},
+		{"query on", http.MethodGet, "/api/campaigns/c1?isTest=true", "", "/v1/server/campaigns/c1", true},
+		{"body off", http.MethodPost, "/api/sponsors", `{"userId":"u1","creatorKey":"neo","isTest":false}`, "/v1/server/sponsors", false},
+		{"body on", http.MethodPost, "/api/sponsors", `{"userId":"u1","creatorKey":"neo","isTest":true}`, "/v1/server/sponsors", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newFakePlayCamp(t)
+			fake.on(tc.method, tc.upstream, http.StatusConflict, `{"code":"CONFLICT","message":"conflict"}`)
+			a := newTestApp(t, fake, nil)
+
+			rec := serve(a, tc.method, tc.target, tc.body)
+			reqs := fake.received()
+			if len(reqs) != 1 {
+				t.Fatalf("PlayCamp calls = %d", len(reqs))
+			}
+			if got := reqs[0].Query.Get("isTest") == "true"; got != tc.wantTest {
+				t.Errorf("upstream isTest = %v, want %v", got, tc.wantTest)
+			}
+			if auth := reqs[0].Header.Get("Authorization"); auth != "Bearer "+testAPIKey {
+				t.Errorf("Authorization = %q", auth)
+			}
+			if p := decodeProblem(t, rec); p.IsTest != tc.wantTest {
+				t.Errorf("problem isTest = %v, want %v", p.IsTest, tc.wantTest)
+			}
+		})
+	}
+}
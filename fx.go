@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Exchange rates come from a local YAML file of dated rates against a base
+// currency, so reports are converted the same way every time they run:
+//
+//	base: USD
+//	rates:
+//	  "2026-10-01":
+//	    KRW: "1385.20"
+//	    JPY: "149.35"
+//
+// A rate is the number of currency units per one base unit. A conversion on a
+// day uses the latest rates dated on or before it. Rates are kept as exact
+// decimals, and converting between two non-base currencies goes through the
+// base before rounding once to the target's minor unit.
+
+// fxRates is a loaded rates file. It is read-only once loaded.
+type fxRates struct {
+	File  string
+	Base  string
+	dates []string
+	rates map[string]map[string]*big.Rat
+}
+
+type fxRatesFile struct {
+	Base  string                       `yaml:"base"`
+	Rates map[string]map[string]string `yaml:"rates"`
+}
+
+// loadFXRates reads and checks a rates file. An empty path yields no rates.
+func loadFXRates(path string) (*fxRates, error) {
+	if path == "" {
+		return &fxRates{}, nil
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file fxRatesFile
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if !isCurrencyCode(file.Base) {
+		return nil, fmt.Errorf("%s: base must be an ISO 4217 code, got %q", path, file.Base)
+	}
+
+	fx := &fxRates{File: path, Base: file.Base, rates: map[string]map[string]*big.Rat{}}
+	for day, rates := range file.Rates {
+		if _, err := time.Parse(dayLayout, day); err != nil {
+			return nil, fmt.Errorf("%s: rates key %q must be a date such as 2026-01-31", path, day)
+		}
+		fx.rates[day] = map[string]*big.Rat{}
+		for currency, v := range rates {
+			rate, ok := new(big.Rat).SetString(v)
+			if !isCurrencyCode(currency) || !ok || rate.Sign() <= 0 {
+				return nil, fmt.Errorf("%s: %s: %s must be an ISO 4217 code with a positive rate, got %q", path, day, currency, v)
+			}
+			fx.rates[day][currency] = rate
+		}
+		fx.dates = append(fx.dates, day)
+	}
+	sort.Strings(fx.dates)
+	return fx, nil
+}
+
+// rate returns the base-to-currency rate in effect on day and the date of
+// the rates used.
+func (fx *fxRates) rate(currency, day string) (*big.Rat, string, error) {
+	// Latest date on or before day.
+	i := sort.SearchStrings(fx.dates, day)
+	if i == len(fx.dates) || fx.dates[i] != day {
+		i--
+	}
+	if i < 0 {
+		return nil, "", fmt.Errorf("no exchange rates on or before %s", day)
+	}
+	date := fx.dates[i]
+	if currency == fx.Base {
+		return big.NewRat(1, 1), date, nil
+	}
+	rate, ok := fx.rates[date][currency]
+	if !ok {
+		return nil, "", fmt.Errorf("no %s rate in the rates of %s", currency, date)
+	}
+	return rate, date, nil
+}
+
+// fxConversion is the result of converting money.
+type fxConversion struct {
+	Money money
+	// Rate is target units per source unit, as a decimal string.
+	Rate     string
+	RateDate string
+}
+
+// convert converts m to currency at the rates in effect on day.
+func (fx *fxRates) convert(m money, currency, day string) (fxConversion, error) {
+	if m.Currency == currency {
+		return fxConversion{Money: m, Rate: "1"}, nil
+	}
+	from, date, err := fx.rate(m.Currency, day)
+	if err != nil {
+		return fxConversion{}, err
+	}
+	to, _, err := fx.rate(currency, day)
+	if err != nil {
+		return fxConversion{}, err
+	}
+
+	// minor_to = minor_from / 10^d_from / from * to * 10^d_to
+	rate := new(big.Rat).Quo(to, from)
+	v := new(big.Rat).SetInt64(m.Minor)
+	v.Mul(v, rate)
+	v.Mul(v, pow10Rat(minorUnitDigits(currency)))
+	v.Quo(v, pow10Rat(minorUnitDigits(m.Currency)))
+	return fxConversion{
+		Money:    money{Currency: currency, Minor: roundRat(v)},
+		Rate:     rate.FloatString(8),
+		RateDate: date,
+	}, nil
+}
+
+// fxRates returns the loaded exchange rates.
+func (a *app) fxRates() *fxRates {
+	return a.fx.Load()
+}
+
+// --- FX Endpoints ---
+
+type fxConvertResult struct {
+	Amount            string `json:"amount"`
+	Currency          string `json:"currency"`
+	Converted         string `json:"converted"`
+	ConvertedCurrency string `json:"convertedCurrency"`
+	Rate              string `json:"rate"`
+	RateDate          string `json:"rateDate,omitempty"`
+}
+
+// handleFXConvert handles GET /api/fx/convert
+func (a *app) handleFXConvert(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	from, to := q.Get("from"), q.Get("to")
+	if to == "" {
+		to = a.config().Currency.ReportingCurrency
+	}
+	if !isCurrencyCode(from) || !isCurrencyCode(to) {
+		writeError(w, r, http.StatusBadRequest, "from and to must be ISO 4217 codes; to defaults to the reporting currency")
+		return
+	}
+	day := q.Get("date")
+	if day == "" {
+		day = time.Now().UTC().Format(dayLayout)
+	}
+	if _, err := time.Parse(dayLayout, day); err != nil {
+		writeError(w, r, http.StatusBadRequest, "date must be a date such as 2026-01-31")
+		return
+	}
+	m, err := parseMoney(q.Get("amount"), from)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "amount: "+err.Error())
+		return
+	}
+
+	c, err := a.fxRates().convert(m, to, day)
+	if err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, fxConvertResult{
+		Amount:            m.String(),
+		Currency:          m.Currency,
+		Converted:         c.Money.String(),
+		ConvertedCurrency: c.Money.Currency,
+		Rate:              c.Rate,
+		RateDate:          c.RateDate,
+	})
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testRates = `base: USD
+rates:
+  "2026-10-01":
+    KRW: "1400"
+    EUR: "0.9"
+  "2026-10-08":
+    KRW: "1350.5"
+`
+
+func writeTestRates(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rates.yaml")
+	if err := os.WriteFile(path, []byte(testRates), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFXConvert(t *testing.T) {
+	fx, err := loadFXRates(writeTestRates(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		amount, from, to, day string
+		want, rateDate        string
+	}{
+		{"10.00", "USD", "KRW", "2026-10-05", "14000", "2026-10-01"},
+		{"10.00", "USD", "KRW", "2026-10-08", "13505", "2026-10-08"},
+		{"14000", "KRW", "USD", "2026-10-05", "10.00", "2026-10-01"},
+		{"1400", "KRW", "EUR", "2026-10-02", "0.90", "2026-10-01"},
+	}
+	for _, tc := range tests {
+		m, _ := parseMoney(tc.amount, tc.from)
+		c, err := fx.convert(m, tc.to, tc.day)
+		if err != nil {
+			t.Errorf("%s %s -> %s: %v", tc.amount, tc.from, tc.to, err)
+			continue
+		}
+		if c.Money.String() != tc.want || c.RateDate != tc.rateDate {
+			t.Errorf("%s %s -> %s on %s = %s (%s), want %s (%s)", tc.amount, tc.from, tc.to, tc.day, c.Money, c.RateDate, tc.want, tc.rateDate)
+		}
+	}
+
+	// No rates before the first date, and EUR is missing from 2026-10-08.
+	for _, day := range []string{"2026-09-30", "2026-10-09"} {
+		if _, err := fx.convert(money{Currency: "USD", Minor: 100}, "EUR", day); err == nil {
+			t.Errorf("%s: converted without a rate", day)
+		}
+	}
+}
+
+func TestExampleRatesFile(t *testing.T) {
+	if _, err := loadFXRates("rates.example.yaml"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHandleFXConvert(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), map[string]string{
+		"REPORTING_CURRENCY": "USD",
+		"FX_RATES_FILE":      writeTestRates(t),
+	})
+
+	var got fxConvertResult
+	decodeData(t, serve(a, http.MethodGet, "/api/fx/convert?amount=7000&from=KRW&date=2026-10-03", ""), &got)
+	if got.Converted != "5.00" || got.ConvertedCurrency != "USD" || got.RateDate != "2026-10-01" {
+		t.Errorf("result = %+v", got)
+	}
+
+	for query, want := range map[string]int{
+		"?amount=1.005&from=USD&to=KRW":      http.StatusBadRequest,
+		"?amount=1&from=usd":                 http.StatusBadRequest,
+		"?amount=1&from=USD&date=today":      http.StatusBadRequest,
+		"?amount=1&from=JPY&date=2026-10-03": http.StatusUnprocessableEntity,
+	} {
+		if rec := serve(a, http.MethodGet, "/api/fx/convert"+query, ""); rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", query, rec.Code, want)
+		}
+	}
+}
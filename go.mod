@@ -3,7 +3,9 @@ module github.com/playcamp/playcamp-go-sdk-example
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/go-chi/chi/v5 v5.2.1
 	github.com/joho/godotenv v1.5.1
 	github.com/playcamp/playcamp-go-sdk v0.0.4
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -9,7 +9,7 @@ import (
 
 // handleListCampaigns handles GET /api/campaigns
 func (a *app) handleListCampaigns(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 
 	page := parsePositiveInt(r.URL.Query().Get("page"), 1)
 	limit := parsePositiveInt(r.URL.Query().Get("limit"), 20)
@@ -19,7 +19,7 @@ func (a *app) handleListCampaigns(w http.ResponseWriter, r *http.Request) {
 		Limit: playcamp.Int(limit),
 	})
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, result)
@@ -27,12 +27,12 @@ func (a *app) handleListCampaigns(w http.ResponseWriter, r *http.Request) {
 
 // handleGetCampaign handles GET /api/campaigns/{id}
 func (a *app) handleGetCampaign(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 	id := chi.URLParam(r, "id")
 
 	campaign, err := sdk.Campaigns.Get(r.Context(), id)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, campaign)
@@ -40,12 +40,12 @@ func (a *app) handleGetCampaign(w http.ResponseWriter, r *http.Request) {
 
 // handleGetCampaignCreators handles GET /api/campaigns/{id}/creators
 func (a *app) handleGetCampaignCreators(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 	id := chi.URLParam(r, "id")
 
 	creators, err := sdk.Campaigns.GetCreators(r.Context(), id)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, creators)
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+func TestCampaignHandlers(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{
+			name:   "list with paging",
+			method: http.MethodGet, target: "/api/campaigns?page=2&limit=5",
+			upstream:   "GET /v1/server/campaigns",
+			response:   `{"data":[{"campaignId":"c1","status":"ACTIVE"}],"pagination":{"page":2,"limit":5,"total":6,"totalPages":2}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, got fakeRequest) {
+				if got.Query.Get("page") != "2" || got.Query.Get("limit") != "5" {
+					t.Errorf("upstream query = %v", got.Query)
+				}
+				var page playcamp.PageResult[playcamp.Campaign]
+				decodeData(t, rec, &page)
+				if len(page.Data) != 1 || page.Data[0].CampaignID != "c1" || page.HasNextPage {
+					t.Errorf("page = %+v", page)
+				}
+			},
+		},
+		{
+			name:   "list defaults bad paging",
+			method: http.MethodGet, target: "/api/campaigns?page=-1&limit=x",
+			upstream:   "GET /v1/server/campaigns",
+			response:   `{"data":[],"pagination":{"page":1,"limit":20,"total":0,"totalPages":0}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, _ *httptest.ResponseRecorder, got fakeRequest) {
+				if got.Query.Get("page") != "1" || got.Query.Get("limit") != "20" {
+					t.Errorf("upstream query = %v", got.Query)
+				}
+			},
+		},
+		{
+			name:   "get",
+			method: http.MethodGet, target: "/api/campaigns/c1",
+			upstream:   "GET /v1/server/campaigns/c1",
+			response:   `{"data":{"campaignId":"c1","campaignName":{"en":"Launch"}}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				var c playcamp.Campaign
+				decodeData(t, rec, &c)
+				if c.CampaignName["en"] != "Launch" {
+					t.Errorf("campaign = %+v", c)
+				}
+			},
+		},
+		{
+			name:   "get missing",
+			method: http.MethodGet, target: "/api/campaigns/nope",
+			upstream:   "GET /v1/server/campaigns/nope",
+			status:     http.StatusNotFound,
+			response:   `{"code":"CAMPAIGN_NOT_FOUND","message":"campaign not found"}`,
+			wantStatus: http.StatusNotFound, wantProblem: "not-found",
+		},
+		{
+			name:   "creators",
+			method: http.MethodGet, target: "/api/campaigns/c1/creators",
+			upstream:   "GET /v1/server/campaigns/c1/creators",
+			response:   `{"data":[{"creatorKey":"k1"},{"creatorKey":"k2"}]}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				var creators []playcamp.Creator
+				decodeData(t, rec, &creators)
+				if len(creators) != 2 {
+					t.Errorf("creators = %+v", creators)
+				}
+			},
+		},
+	})
+}
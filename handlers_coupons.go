@@ -7,20 +7,34 @@ import (
 	playcamp "github.com/playcamp/playcamp-go-sdk"
 )
 
+type validateCouponRequest struct {
+	CouponCode string `json:"couponCode" validate:"required,max=64"`
+	UserID     string `json:"userId" validate:"required,max=128"`
+	IsTest     *bool  `json:"isTest,omitempty"`
+}
+
+type redeemCouponRequest struct {
+	CouponCode   string  `json:"couponCode" validate:"required,max=64"`
+	UserID       string  `json:"userId" validate:"required,max=128"`
+	GameUserUUID *string `json:"gameUserUuid,omitempty" validate:"max=128"`
+	CallbackID   string  `json:"callbackId,omitempty" validate:"max=128"`
+	IsTest       *bool   `json:"isTest,omitempty"`
+}
+
 // handleValidateCoupon handles POST /api/coupons/validate
 func (a *app) handleValidateCoupon(w http.ResponseWriter, r *http.Request) {
-	var body struct {
-		CouponCode string `json:"couponCode"`
-		UserID     string `json:"userId"`
-		IsTest     *bool  `json:"isTest,omitempty"`
-	}
-	if err := decodeJSON(r, &body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+	var body validateCouponRequest
+	if !a.bind(w, r, &body) {
 		return
 	}
 
 	isTest := body.IsTest != nil && *body.IsTest
-	sdk := a.getSDK(isTest)
+	sdk := a.getSDK(r, isTest)
+
+	key := lockoutKey(r, body.UserID)
+	if a.rejectIfLockedOut(w, r, key) {
+		return
+	}
 
 	result, err := sdk.Coupons.Validate(r.Context(), playcamp.ValidateCouponServerParams{
 		CouponCode: body.CouponCode,
@@ -28,28 +42,30 @@ func (a *app) handleValidateCoupon(w http.ResponseWriter, r *http.Request) {
 		IsTest:     body.IsTest,
 	})
 	if err != nil {
-		handleSDKError(w, err)
+		if isNotFound(err) {
+			a.recordCouponAttempt(r, key, true)
+		}
+		handleSDKError(w, r, err)
 		return
 	}
+	a.recordCouponAttempt(r, key, !result.Valid)
 	writeJSON(w, http.StatusOK, result)
 }
 
 // handleRedeemCoupon handles POST /api/coupons/redeem
 func (a *app) handleRedeemCoupon(w http.ResponseWriter, r *http.Request) {
-	var body struct {
-		CouponCode   string  `json:"couponCode"`
-		UserID       string  `json:"userId"`
-		GameUserUUID *string `json:"gameUserUuid,omitempty"`
-		CallbackID   string  `json:"callbackId,omitempty"`
-		IsTest       *bool   `json:"isTest,omitempty"`
-	}
-	if err := decodeJSON(r, &body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+	var body redeemCouponRequest
+	if !a.bind(w, r, &body) {
 		return
 	}
 
 	isTest := body.IsTest != nil && *body.IsTest
-	sdk := a.getSDK(isTest)
+	sdk := a.getSDK(r, isTest)
+
+	key := lockoutKey(r, body.UserID)
+	if a.rejectIfLockedOut(w, r, key) {
+		return
+	}
 
 	result, err := sdk.Coupons.Redeem(r.Context(), playcamp.RedeemCouponParams{
 		CouponCode:   body.CouponCode,
@@ -59,15 +75,19 @@ func (a *app) handleRedeemCoupon(w http.ResponseWriter, r *http.Request) {
 		IsTest:       body.IsTest,
 	})
 	if err != nil {
-		handleSDKError(w, err)
+		if isNotFound(err) {
+			a.recordCouponAttempt(r, key, true)
+		}
+		handleSDKError(w, r, err)
 		return
 	}
+	a.recordCouponAttempt(r, key, !result.Success)
 	writeJSON(w, http.StatusOK, result)
 }
 
 // handleGetCouponHistory handles GET /api/coupons/user/{userId}
 func (a *app) handleGetCouponHistory(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 	userID := chi.URLParam(r, "userId")
 
 	page := parsePositiveInt(r.URL.Query().Get("page"), 1)
@@ -78,7 +98,7 @@ func (a *app) handleGetCouponHistory(w http.ResponseWriter, r *http.Request) {
 		Limit: playcamp.Int(limit),
 	})
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, result)
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+func TestCouponHandlers(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{
+			name:   "validate",
+			method: http.MethodPost, target: "/api/coupons/validate",
+			body:       `{"couponCode":"NEO-1","userId":"u1"}`,
+			upstream:   "POST /v1/server/coupons/validate",
+			response:   `{"data":{"valid":true,"couponCode":"NEO-1","creatorKey":"neo"}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, got fakeRequest) {
+				if body := jsonBody(t, got); body["couponCode"] != "NEO-1" || body["userId"] != "u1" {
+					t.Errorf("upstream body = %v", body)
+				}
+				var v playcamp.CouponValidation
+				decodeData(t, rec, &v)
+				if !v.Valid || v.CreatorKey != "neo" {
+					t.Errorf("validation = %+v", v)
+				}
+			},
+		},
+		{
+			name:   "validate missing fields",
+			method: http.MethodPost, target: "/api/coupons/validate",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest, wantProblem: "invalid-input",
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				if p := decodeProblem(t, rec); len(p.Errors) != 2 {
+					t.Errorf("errors = %+v, want couponCode and userId", p.Errors)
+				}
+			},
+		},
+		{
+			name:   "redeem",
+			method: http.MethodPost, target: "/api/coupons/redeem",
+			body:       `{"couponCode":"NEO-1","userId":"u1","callbackId":"cb-1"}`,
+			upstream:   "POST /v1/server/coupons/redeem",
+			response:   `{"data":{"success":true,"usageId":42,"reward":[{"itemId":"gem","itemQuantity":10}]}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, got fakeRequest) {
+				if body := jsonBody(t, got); body["callbackId"] != "cb-1" {
+					t.Errorf("upstream body = %v", body)
+				}
+				var res playcamp.RedeemResult
+				decodeData(t, rec, &res)
+				if res.UsageID != 42 || len(res.Reward) != 1 {
+					t.Errorf("result = %+v", res)
+				}
+			},
+		},
+		{
+			name:   "redeem already used",
+			method: http.MethodPost, target: "/api/coupons/redeem",
+			body:       `{"couponCode":"NEO-1","userId":"u1"}`,
+			upstream:   "POST /v1/server/coupons/redeem",
+			status:     http.StatusConflict,
+			response:   `{"code":"ALREADY_USED","message":"coupon already used"}`,
+			wantStatus: http.StatusConflict, wantProblem: "conflict",
+		},
+		{
+			name:   "history",
+			method: http.MethodGet, target: "/api/coupons/user/u1?limit=10",
+			upstream:   "GET /v1/server/coupons/user/u1",
+			response:   `{"data":[{"id":1,"userId":"u1","couponCode":"NEO-1"}],"pagination":{"page":1,"limit":10,"total":1,"totalPages":1}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				var page playcamp.PageResult[playcamp.CouponUsage]
+				decodeData(t, rec, &page)
+				if len(page.Data) != 1 || page.Pagination.Limit != 10 {
+					t.Errorf("page = %+v", page)
+				}
+			},
+		},
+	})
+}
+
+func TestCouponLockout(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodPost, "/v1/server/coupons/validate", http.StatusOK, `{"data":{"valid":false,"errorCode":"NOT_FOUND"}}`)
+	a := newTestApp(t, fake, nil)
+	body := `{"couponCode":"GUESS","userId":"u1"}`
+
+	max := a.config().CouponGuard.MaxFailures
+	for i := 0; i < max; i++ {
+		if rec := serve(a, http.MethodPost, "/api/coupons/validate", body); rec.Code != http.StatusOK {
+			t.Fatalf("attempt %d: status = %d", i+1, rec.Code)
+		}
+	}
+
+	rec := serve(a, http.MethodPost, "/api/coupons/validate", body)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("status after %d failures = %d, want 429", max, rec.Code)
+	}
+	if p := decodeProblem(t, rec); p.Type != problemLockedOut {
+		t.Errorf("problem type = %q", p.Type)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("missing Retry-After")
+	}
+	if n := len(fake.received()); n != max {
+		t.Errorf("PlayCamp calls = %d, want %d; locked-out attempts must not reach PlayCamp", n, max)
+	}
+
+	if rec := serve(a, http.MethodDelete, "/api/coupons/lockouts/user:u1", ""); rec.Code != http.StatusOK {
+		t.Fatalf("clear: status = %d", rec.Code)
+	}
+	if rec := serve(a, http.MethodDelete, "/api/coupons/lockouts", ""); rec.Code != http.StatusOK {
+		t.Fatalf("clear all: status = %d", rec.Code)
+	}
+	if rec := serve(a, http.MethodPost, "/api/coupons/validate", body); rec.Code != http.StatusOK {
+		t.Errorf("status after clearing = %d, want 200", rec.Code)
+	}
+}
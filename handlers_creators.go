@@ -9,11 +9,11 @@ import (
 
 // handleSearchCreators handles GET /api/creators/search
 func (a *app) handleSearchCreators(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 
 	keyword := r.URL.Query().Get("keyword")
 	if keyword == "" {
-		writeError(w, http.StatusBadRequest, "keyword query parameter is required")
+		writeError(w, r, http.StatusBadRequest, "keyword query parameter is required")
 		return
 	}
 
@@ -28,7 +28,7 @@ func (a *app) handleSearchCreators(w http.ResponseWriter, r *http.Request) {
 
 	creators, err := sdk.Creators.Search(r.Context(), params)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, creators)
@@ -36,12 +36,12 @@ func (a *app) handleSearchCreators(w http.ResponseWriter, r *http.Request) {
 
 // handleGetCreator handles GET /api/creators/{key}
 func (a *app) handleGetCreator(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 	key := chi.URLParam(r, "key")
 
 	creator, err := sdk.Creators.Get(r.Context(), key)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, creator)
@@ -49,12 +49,12 @@ func (a *app) handleGetCreator(w http.ResponseWriter, r *http.Request) {
 
 // handleGetCreatorCoupons handles GET /api/creators/{key}/coupons
 func (a *app) handleGetCreatorCoupons(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 	key := chi.URLParam(r, "key")
 
 	coupons, err := sdk.Creators.GetCoupons(r.Context(), key)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, coupons)
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+func TestCreatorHandlers(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{
+			name:   "search",
+			method: http.MethodGet, target: "/api/creators/search?keyword=neo&campaignId=c1&limit=3",
+			upstream:   "GET /v1/server/creators/search",
+			response:   `{"data":[{"creatorKey":"neo","creatorName":"Neo"}]}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, got fakeRequest) {
+				q := got.Query
+				if q.Get("keyword") != "neo" || q.Get("campaignId") != "c1" || q.Get("limit") != "3" {
+					t.Errorf("upstream query = %v", q)
+				}
+				var creators []playcamp.Creator
+				decodeData(t, rec, &creators)
+				if len(creators) != 1 || creators[0].CreatorName != "Neo" {
+					t.Errorf("creators = %+v", creators)
+				}
+			},
+		},
+		{
+			name:   "search without keyword",
+			method: http.MethodGet, target: "/api/creators/search",
+			wantStatus: http.StatusBadRequest, wantProblem: "bad-request",
+		},
+		{
+			name:   "get",
+			method: http.MethodGet, target: "/api/creators/neo",
+			upstream:   "GET /v1/server/creators/neo",
+			response:   `{"data":{"creatorKey":"neo","creatorId":7}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				var c playcamp.Creator
+				decodeData(t, rec, &c)
+				if c.CreatorID != 7 {
+					t.Errorf("creator = %+v", c)
+				}
+			},
+		},
+		{
+			name:   "coupons",
+			method: http.MethodGet, target: "/api/creators/neo/coupons",
+			upstream:   "GET /v1/server/creators/neo/coupons",
+			response:   `{"data":[{"code":"NEO-1","packageNo":1,"status":"ACTIVE"}]}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				var coupons []playcamp.CreatorCoupon
+				decodeData(t, rec, &coupons)
+				if len(coupons) != 1 || coupons[0].CouponCode != "NEO-1" {
+					t.Errorf("coupons = %+v", coupons)
+				}
+			},
+		},
+	})
+}
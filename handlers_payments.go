@@ -1,70 +1,116 @@
 package main
 
 import (
-	"encoding/json"
+	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	playcamp "github.com/playcamp/playcamp-go-sdk"
 )
 
+// paymentItem is one payment, on its own or as part of a bulk request.
+type paymentItem struct {
+	UserID           string                     `json:"userId" validate:"required,max=128"`
+	TransactionID    string                     `json:"transactionId" validate:"required,max=128"`
+	ProductID        string                     `json:"productId" validate:"required,max=128"`
+	ProductName      *string                    `json:"productName,omitempty" validate:"max=256"`
+	Amount           float64                    `json:"amount" validate:"positive"`
+	Currency         string                     `json:"currency" validate:"required,currency"`
+	Platform         playcamp.PaymentPlatform   `json:"platform" validate:"required,enum=paymentPlatform"`
+	DistributionType *playcamp.DistributionType `json:"distributionType,omitempty" validate:"enum=distributionType"`
+	PurchasedAt      *string                    `json:"purchasedAt,omitempty" validate:"rfc3339"`
+	Receipt          *string                    `json:"receipt,omitempty" validate:"max=65536"`
+	CampaignID       *string                    `json:"campaignId,omitempty" validate:"max=64"`
+	CreatorKey       *string                    `json:"creatorKey,omitempty" validate:"max=64"`
+}
+
+// check rejects amounts finer than the currency's minor unit, such as
+// 1200.5 KRW, which PlayCamp and the revenue ledger would round differently.
+func (p paymentItem) check() []problemField {
+	if m := moneyFromFloat(p.Amount, p.Currency); m.Float() != p.Amount {
+		return []problemField{{Field: "amount", Message: fmt.Sprintf("must have at most %d decimal places for %s", minorUnitDigits(p.Currency), p.Currency)}}
+	}
+	return nil
+}
+
+// params converts p to SDK parameters. purchasedAt defaults to now.
+func (p paymentItem) params() playcamp.CreatePaymentParams {
+	purchasedAt := time.Now().UTC()
+	if p.PurchasedAt != nil && *p.PurchasedAt != "" {
+		// Already validated as RFC 3339.
+		purchasedAt, _ = time.Parse(time.RFC3339, *p.PurchasedAt)
+	}
+	return playcamp.CreatePaymentParams{
+		UserID:           p.UserID,
+		TransactionID:    p.TransactionID,
+		ProductID:        p.ProductID,
+		ProductName:      p.ProductName,
+		Amount:           p.Amount,
+		Currency:         p.Currency,
+		Platform:         p.Platform,
+		DistributionType: p.DistributionType,
+		PurchasedAt:      purchasedAt,
+		Receipt:          p.Receipt,
+		CampaignID:       p.CampaignID,
+		CreatorKey:       p.CreatorKey,
+	}
+}
+
+type createPaymentRequest struct {
+	paymentItem
+	CallbackID string `json:"callbackId,omitempty" validate:"max=128"`
+	IsTest     *bool  `json:"isTest,omitempty"`
+}
+
+type createBulkPaymentRequest struct {
+	Payments   []paymentItem `json:"payments" validate:"required,dive"`
+	CallbackID string        `json:"callbackId,omitempty" validate:"max=128"`
+	IsTest     *bool         `json:"isTest,omitempty"`
+}
+
+// bulkPaymentResult is PlayCamp's result for the payments that were sent,
+// plus those held back because their receipt failed.
+type bulkPaymentResult struct {
+	playcamp.BulkPaymentResult
+	Quarantined []quarantinedRef `json:"quarantined,omitempty"`
+}
+
+// quarantinedRef points at a bulk payment that was quarantined.
+type quarantinedRef struct {
+	Index         int    `json:"index"`
+	TransactionID string `json:"transactionId"`
+	Reason        string `json:"reason"`
+	Retryable     bool   `json:"retryable"`
+}
+
+type refundPaymentRequest struct {
+	CallbackID string `json:"callbackId,omitempty" validate:"max=128"`
+	IsTest     *bool  `json:"isTest,omitempty"`
+}
+
 // handleCreatePayment handles POST /api/payments
 func (a *app) handleCreatePayment(w http.ResponseWriter, r *http.Request) {
-	var body struct {
-		UserID           string                    `json:"userId"`
-		TransactionID    string                    `json:"transactionId"`
-		ProductID        string                    `json:"productId"`
-		ProductName      *string                   `json:"productName,omitempty"`
-		Amount           float64                   `json:"amount"`
-		Currency         string                    `json:"currency"`
-		Platform         playcamp.PaymentPlatform  `json:"platform"`
-		DistributionType *playcamp.DistributionType `json:"distributionType,omitempty"`
-		PurchasedAt      *string                   `json:"purchasedAt,omitempty"`
-		Receipt          *string                   `json:"receipt,omitempty"`
-		CampaignID       *string                   `json:"campaignId,omitempty"`
-		CreatorKey       *string                   `json:"creatorKey,omitempty"`
-		CallbackID       string                    `json:"callbackId,omitempty"`
-		IsTest           *bool                     `json:"isTest,omitempty"`
-	}
-	if err := decodeJSON(r, &body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+	var body createPaymentRequest
+	if !a.bind(w, r, &body) {
 		return
 	}
 
 	isTest := body.IsTest != nil && *body.IsTest
-	sdk := a.getSDK(isTest)
+	sdk := a.getSDK(r, isTest)
 
-	// Parse purchasedAt or default to now.
-	purchasedAt := time.Now().UTC()
-	if body.PurchasedAt != nil && *body.PurchasedAt != "" {
-		parsed, err := time.Parse(time.RFC3339, *body.PurchasedAt)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, "invalid purchasedAt format, expected RFC3339")
-			return
-		}
-		purchasedAt = parsed
+	if q := a.checkReceipt(r.Context(), body.paymentItem, isTest, body.CallbackID); q != nil {
+		writeQuarantined(w, r, q)
+		return
 	}
 
-	payment, err := sdk.Payments.Create(r.Context(), playcamp.CreatePaymentParams{
-		UserID:           body.UserID,
-		TransactionID:    body.TransactionID,
-		ProductID:        body.ProductID,
-		ProductName:      body.ProductName,
-		Amount:           body.Amount,
-		Currency:         body.Currency,
-		Platform:         body.Platform,
-		DistributionType: body.DistributionType,
-		PurchasedAt:      purchasedAt,
-		Receipt:          body.Receipt,
-		CampaignID:       body.CampaignID,
-		CreatorKey:       body.CreatorKey,
-		CallbackID:       body.CallbackID,
-		IsTest:           body.IsTest,
-	})
+	params := body.params()
+	params.CallbackID = body.CallbackID
+	params.IsTest = body.IsTest
+
+	payment, err := sdk.Payments.Create(r.Context(), params)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusCreated, payment)
@@ -72,12 +118,12 @@ func (a *app) handleCreatePayment(w http.ResponseWriter, r *http.Request) {
 
 // handleGetPayment handles GET /api/payments/{transactionId}
 func (a *app) handleGetPayment(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 	txnID := chi.URLParam(r, "transactionId")
 
 	payment, err := sdk.Payments.Get(r.Context(), txnID)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, payment)
@@ -85,7 +131,7 @@ func (a *app) handleGetPayment(w http.ResponseWriter, r *http.Request) {
 
 // handleGetUserPayments handles GET /api/payments/user/{userId}
 func (a *app) handleGetUserPayments(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 	userID := chi.URLParam(r, "userId")
 
 	page := parsePositiveInt(r.URL.Query().Get("page"), 1)
@@ -96,7 +142,7 @@ func (a *app) handleGetUserPayments(w http.ResponseWriter, r *http.Request) {
 		Limit: playcamp.Int(limit),
 	})
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, result)
@@ -104,70 +150,38 @@ func (a *app) handleGetUserPayments(w http.ResponseWriter, r *http.Request) {
 
 // handleCreateBulkPayment handles POST /api/payments/bulk
 func (a *app) handleCreateBulkPayment(w http.ResponseWriter, r *http.Request) {
-	var body struct {
-		Payments   []json.RawMessage `json:"payments"`
-		CallbackID string            `json:"callbackId,omitempty"`
-		IsTest     *bool             `json:"isTest,omitempty"`
-	}
-	if err := decodeJSON(r, &body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
-		return
-	}
-
-	if len(body.Payments) == 0 {
-		writeError(w, http.StatusBadRequest, "payments array is required and must not be empty")
+	var body createBulkPaymentRequest
+	if !a.bind(w, r, &body) {
 		return
 	}
 
 	isTest := body.IsTest != nil && *body.IsTest
-	sdk := a.getSDK(isTest)
-
-	// Parse each payment
-	var payments []playcamp.CreatePaymentParams
-	for i, raw := range body.Payments {
-		var p struct {
-			UserID           string                     `json:"userId"`
-			TransactionID    string                     `json:"transactionId"`
-			ProductID        string                     `json:"productId"`
-			ProductName      *string                    `json:"productName,omitempty"`
-			Amount           float64                    `json:"amount"`
-			Currency         string                     `json:"currency"`
-			Platform         playcamp.PaymentPlatform   `json:"platform"`
-			DistributionType *playcamp.DistributionType `json:"distributionType,omitempty"`
-			PurchasedAt      *string                    `json:"purchasedAt,omitempty"`
-			Receipt          *string                    `json:"receipt,omitempty"`
-			CampaignID       *string                    `json:"campaignId,omitempty"`
-			CreatorKey       *string                    `json:"creatorKey,omitempty"`
-		}
-		if err := json.Unmarshal(raw, &p); err != nil {
-			writeError(w, http.StatusBadRequest, "invalid payment at index "+strconv.Itoa(i))
-			return
+	sdk := a.getSDK(r, isTest)
+
+	var (
+		payments    []playcamp.CreatePaymentParams
+		quarantined []quarantinedRef
+		rejected    []problemField
+		retryable   = true
+	)
+	for i, q := range a.checkReceipts(r.Context(), body.Payments, isTest, body.CallbackID) {
+		if q == nil {
+			payments = append(payments, body.Payments[i].params())
+			continue
 		}
-
-		purchasedAt := time.Now().UTC()
-		if p.PurchasedAt != nil && *p.PurchasedAt != "" {
-			parsed, err := time.Parse(time.RFC3339, *p.PurchasedAt)
-			if err != nil {
-				writeError(w, http.StatusBadRequest, "invalid purchasedAt at index "+strconv.Itoa(i))
-				return
-			}
-			purchasedAt = parsed
+		quarantined = append(quarantined, quarantinedRef{Index: i, TransactionID: q.TransactionID, Reason: q.Reason, Retryable: q.Retryable})
+		rejected = append(rejected, problemField{Field: fmt.Sprintf("payments[%d].receipt", i), Message: q.Reason})
+		retryable = retryable && q.Retryable
+	}
+	if len(payments) == 0 {
+		typ, status := problemReceipt, http.StatusUnprocessableEntity
+		if retryable {
+			typ, status = problemReceiptCheck, http.StatusServiceUnavailable
 		}
-
-		payments = append(payments, playcamp.CreatePaymentParams{
-			UserID:           p.UserID,
-			TransactionID:    p.TransactionID,
-			ProductID:        p.ProductID,
-			ProductName:      p.ProductName,
-			Amount:           p.Amount,
-			Currency:         p.Currency,
-			Platform:         p.Platform,
-			DistributionType: p.DistributionType,
-			PurchasedAt:      purchasedAt,
-			Receipt:          p.Receipt,
-			CampaignID:       p.CampaignID,
-			CreatorKey:       p.CreatorKey,
-		})
+		p := newProblem(r, typ, status, "no payment passed receipt verification; all were quarantined and none was reported")
+		p.Errors = rejected
+		writeProblem(w, p)
+		return
 	}
 
 	result, err := sdk.Payments.CreateBulk(r.Context(), playcamp.CreateBulkPaymentParams{
@@ -176,25 +190,24 @@ func (a *app) handleCreateBulkPayment(w http.ResponseWriter, r *http.Request) {
 		IsTest:     body.IsTest,
 	})
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
-	writeJSON(w, http.StatusCreated, result)
+	writeJSON(w, http.StatusCreated, bulkPaymentResult{BulkPaymentResult: *result, Quarantined: quarantined})
 }
 
 // handleRefundPayment handles POST /api/payments/{transactionId}/refund
 func (a *app) handleRefundPayment(w http.ResponseWriter, r *http.Request) {
 	txnID := chi.URLParam(r, "transactionId")
 
-	var body struct {
-		CallbackID string `json:"callbackId,omitempty"`
-		IsTest     *bool  `json:"isTest,omitempty"`
-	}
 	// Body is optional for refund.
-	_ = decodeJSON(r, &body)
+	var body refundPaymentRequest
+	if !a.bind(w, r, &body) {
+		return
+	}
 
 	isTest := body.IsTest != nil && *body.IsTest
-	sdk := a.getSDK(isTest)
+	sdk := a.getSDK(r, isTest)
 
 	var opts *playcamp.RefundPaymentOptions
 	if body.IsTest != nil || body.CallbackID != "" {
@@ -206,7 +219,7 @@ func (a *app) handleRefundPayment(w http.ResponseWriter, r *http.Request) {
 
 	payment, err := sdk.Payments.Refund(r.Context(), txnID, opts)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, payment)
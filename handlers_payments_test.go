@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+const testPayment = `{"userId":"u1","transactionId":"tx-1","productId":"gems_100","amount":1200,"currency":"KRW","platform":"Android"}`
+
+func TestPaymentHandlers(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{
+			name:   "create",
+			method: http.MethodPost, target: "/api/payments",
+			body:       `{"userId":"u1","transactionId":"tx-1","productId":"gems_100","amount":1200,"currency":"KRW","platform":"Android","purchasedAt":"2026-01-02T03:04:05Z","creatorKey":"neo"}`,
+			upstream:   "POST /v1/server/payments",
+			response:   `{"data":{"id":1,"transactionId":"tx-1","amount":1200,"currency":"KRW","status":"COMPLETED"}}`,
+			wantStatus: http.StatusCreated,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, got fakeRequest) {
+				body := jsonBody(t, got)
+				if body["purchasedAt"] != "2026-01-02T03:04:05Z" || body["creatorKey"] != "neo" || body["amount"] != 1200.0 {
+					t.Errorf("upstream body = %v", body)
+				}
+				var p playcamp.Payment
+				decodeData(t, rec, &p)
+				if p.TransactionID != "tx-1" {
+					t.Errorf("payment = %+v", p)
+				}
+			},
+		},
+		{
+			name:   "create defaults purchasedAt",
+			method: http.MethodPost, target: "/api/payments",
+			body:       testPayment,
+			upstream:   "POST /v1/server/payments",
+			response:   `{"data":{"transactionId":"tx-1"}}`,
+			wantStatus: http.StatusCreated,
+			check: func(t *testing.T, _ *httptest.ResponseRecorder, got fakeRequest) {
+				if body := jsonBody(t, got); body["purchasedAt"] == "" || body["purchasedAt"] == nil {
+					t.Errorf("purchasedAt not set: %v", body)
+				}
+			},
+		},
+		{
+			name:   "create invalid",
+			method: http.MethodPost, target: "/api/payments",
+			body:       `{"userId":"u1","transactionId":"tx-1","productId":"p","amount":0,"currency":"XYZ","platform":"Switch"}`,
+			wantStatus: http.StatusBadRequest, wantProblem: "invalid-input",
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				fields := map[string]bool{}
+				for _, e := range decodeProblem(t, rec).Errors {
+					fields[e.Field] = true
+				}
+				for _, f := range []string{"amount", "currency", "platform"} {
+					if !fields[f] {
+						t.Errorf("no violation for %s: %v", f, fields)
+					}
+				}
+			},
+		},
+		{
+			name:   "create amount finer than the minor unit",
+			method: http.MethodPost, target: "/api/payments",
+			body:       `{"userId":"u1","transactionId":"tx-1","productId":"p","amount":1200.5,"currency":"KRW","platform":"Android"}`,
+			wantStatus: http.StatusBadRequest, wantProblem: "invalid-input",
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				if errs := decodeProblem(t, rec).Errors; len(errs) != 1 || errs[0].Field != "amount" {
+					t.Errorf("errors = %+v", errs)
+				}
+			},
+		},
+		{
+			name:   "bulk amount finer than the minor unit",
+			method: http.MethodPost, target: "/api/payments/bulk",
+			body:       `{"payments":[` + testPayment + `,{"userId":"u1","transactionId":"tx-2","productId":"p","amount":4.999,"currency":"USD","platform":"Android"}]}`,
+			wantStatus: http.StatusBadRequest, wantProblem: "invalid-input",
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				if errs := decodeProblem(t, rec).Errors; len(errs) != 1 || errs[0].Field != "payments[1].amount" {
+					t.Errorf("errors = %+v", errs)
+				}
+			},
+		},
+		{
+			name:   "bulk",
+			method: http.MethodPost, target: "/api/payments/bulk",
+			body:       `{"payments":[` + testPayment + `,` + testPayment + `]}`,
+			upstream:   "POST /v1/server/payments/bulk",
+			response:   `{"data":{"totalRequested":2,"successful":1,"skipped":1,"results":[]}}`,
+			wantStatus: http.StatusCreated,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, got fakeRequest) {
+				if payments, _ := jsonBody(t, got)["payments"].([]any); len(payments) != 2 {
+					t.Errorf("upstream payments = %v", payments)
+				}
+				var res playcamp.BulkPaymentResult
+				decodeData(t, rec, &res)
+				if res.TotalRequested != 2 || res.Skipped != 1 {
+					t.Errorf("result = %+v", res)
+				}
+			},
+		},
+		{
+			name:   "bulk reports item paths",
+			method: http.MethodPost, target: "/api/payments/bulk",
+			body:       `{"payments":[` + testPayment + `,{"userId":"u1"}]}`,
+			wantStatus: http.StatusBadRequest, wantProblem: "invalid-input",
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				if errs := decodeProblem(t, rec).Errors; len(errs) == 0 || errs[0].Field != "payments[1].transactionId" {
+					t.Errorf("errors = %+v", errs)
+				}
+			},
+		},
+		{
+			name:   "get",
+			method: http.MethodGet, target: "/api/payments/tx-1",
+			upstream:   "GET /v1/server/payments/tx-1",
+			response:   `{"data":{"transactionId":"tx-1","status":"COMPLETED"}}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "user payments",
+			method: http.MethodGet, target: "/api/payments/user/u1?page=3",
+			upstream:   "GET /v1/server/payments/user/u1",
+			response:   `{"data":[],"pagination":{"page":3,"limit":20,"total":40,"totalPages":2}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, _ *httptest.ResponseRecorder, got fakeRequest) {
+				if got.Query.Get("page") != "3" {
+					t.Errorf("upstream query = %v", got.Query)
+				}
+			},
+		},
+		{
+			name:   "refund without body",
+			method: http.MethodPost, target: "/api/payments/tx-1/refund",
+			upstream:   "POST /v1/server/payments/tx-1/refund",
+			response:   `{"data":{"transactionId":"tx-1","status":"REFUNDED"}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				var p playcamp.Payment
+				decodeData(t, rec, &p)
+				if p.Status != "REFUNDED" {
+					t.Errorf("payment = %+v", p)
+				}
+			},
+		},
+		{
+			name:   "refund unknown",
+			method: http.MethodPost, target: "/api/payments/tx-9/refund",
+			body:       `{"callbackId":"cb-1"}`,
+			upstream:   "POST /v1/server/payments/tx-9/refund",
+			status:     http.StatusNotFound,
+			response:   `{"code":"PAYMENT_NOT_FOUND","message":"payment not found"}`,
+			wantStatus: http.StatusNotFound, wantProblem: "not-found",
+		},
+	})
+}
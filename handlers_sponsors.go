@@ -7,14 +7,29 @@ import (
 	playcamp "github.com/playcamp/playcamp-go-sdk"
 )
 
+type createSponsorRequest struct {
+	UserID     string  `json:"userId" validate:"required,max=128"`
+	CreatorKey string  `json:"creatorKey" validate:"required,max=64"`
+	CampaignID *string `json:"campaignId,omitempty" validate:"max=64"`
+	CallbackID string  `json:"callbackId,omitempty" validate:"max=128"`
+	IsTest     *bool   `json:"isTest,omitempty"`
+}
+
+type updateSponsorRequest struct {
+	CampaignID    *string `json:"campaignId,omitempty" validate:"max=64"`
+	NewCreatorKey string  `json:"newCreatorKey" validate:"required,max=64"`
+	CallbackID    string  `json:"callbackId,omitempty" validate:"max=128"`
+	IsTest        *bool   `json:"isTest,omitempty"`
+}
+
 // handleGetSponsor handles GET /api/sponsors/{userId}
 func (a *app) handleGetSponsor(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 	userID := chi.URLParam(r, "userId")
 
 	sponsors, err := sdk.Sponsors.GetByUser(r.Context(), userID)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, sponsors)
@@ -22,20 +37,13 @@ func (a *app) handleGetSponsor(w http.ResponseWriter, r *http.Request) {
 
 // handleCreateSponsor handles POST /api/sponsors
 func (a *app) handleCreateSponsor(w http.ResponseWriter, r *http.Request) {
-	var body struct {
-		UserID     string  `json:"userId"`
-		CreatorKey string  `json:"creatorKey"`
-		CampaignID *string `json:"campaignId,omitempty"`
-		CallbackID string  `json:"callbackId,omitempty"`
-		IsTest     *bool   `json:"isTest,omitempty"`
-	}
-	if err := decodeJSON(r, &body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+	var body createSponsorRequest
+	if !a.bind(w, r, &body) {
 		return
 	}
 
 	isTest := body.IsTest != nil && *body.IsTest
-	sdk := a.getSDK(isTest)
+	sdk := a.getSDK(r, isTest)
 
 	sponsor, err := sdk.Sponsors.Create(r.Context(), playcamp.CreateSponsorParams{
 		UserID:     body.UserID,
@@ -45,7 +53,7 @@ func (a *app) handleCreateSponsor(w http.ResponseWriter, r *http.Request) {
 		IsTest:     body.IsTest,
 	})
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusCreated, sponsor)
@@ -55,19 +63,13 @@ func (a *app) handleCreateSponsor(w http.ResponseWriter, r *http.Request) {
 func (a *app) handleUpdateSponsor(w http.ResponseWriter, r *http.Request) {
 	userID := chi.URLParam(r, "userId")
 
-	var body struct {
-		CampaignID    *string `json:"campaignId,omitempty"`
-		NewCreatorKey string  `json:"newCreatorKey"`
-		CallbackID    string  `json:"callbackId,omitempty"`
-		IsTest        *bool   `json:"isTest,omitempty"`
-	}
-	if err := decodeJSON(r, &body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+	var body updateSponsorRequest
+	if !a.bind(w, r, &body) {
 		return
 	}
 
 	isTest := body.IsTest != nil && *body.IsTest
-	sdk := a.getSDK(isTest)
+	sdk := a.getSDK(r, isTest)
 
 	sponsor, err := sdk.Sponsors.Update(r.Context(), userID, playcamp.UpdateSponsorParams{
 		CampaignID:    body.CampaignID,
@@ -76,7 +78,7 @@ func (a *app) handleUpdateSponsor(w http.ResponseWriter, r *http.Request) {
 		IsTest:        body.IsTest,
 	})
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, sponsor)
@@ -84,7 +86,7 @@ func (a *app) handleUpdateSponsor(w http.ResponseWriter, r *http.Request) {
 
 // handleDeleteSponsor handles DELETE /api/sponsors/{userId}
 func (a *app) handleDeleteSponsor(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 	userID := chi.URLParam(r, "userId")
 
 	campaignID := r.URL.Query().Get("campaignId")
@@ -102,7 +104,7 @@ func (a *app) handleDeleteSponsor(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := sdk.Sponsors.Delete(r.Context(), userID, opts); err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]bool{"deleted": true})
@@ -110,7 +112,7 @@ func (a *app) handleDeleteSponsor(w http.ResponseWriter, r *http.Request) {
 
 // handleGetSponsorHistory handles GET /api/sponsors/{userId}/history
 func (a *app) handleGetSponsorHistory(w http.ResponseWriter, r *http.Request) {
-	sdk := a.getSDK(isTestFromQuery(r))
+	sdk := a.getSDK(r, isTestFromQuery(r))
 	userID := chi.URLParam(r, "userId")
 
 	opts := &playcamp.GetSponsorHistoryOptions{
@@ -123,7 +125,7 @@ func (a *app) handleGetSponsorHistory(w http.ResponseWriter, r *http.Request) {
 
 	result, err := sdk.Sponsors.GetHistory(r.Context(), userID, opts)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, result)
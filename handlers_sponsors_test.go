@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+func TestSponsorHandlers(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{
+			name:   "get",
+			method: http.MethodGet, target: "/api/sponsors/u1",
+			upstream:   "GET /v1/server/sponsors/user/u1",
+			response:   `{"data":[{"userId":"u1","campaignId":"c1","creatorKey":"neo","isActive":true}]}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				var sponsors []playcamp.Sponsor
+				decodeData(t, rec, &sponsors)
+				if len(sponsors) != 1 || !sponsors[0].IsActive {
+					t.Errorf("sponsors = %+v", sponsors)
+				}
+			},
+		},
+		{
+			name:   "create",
+			method: http.MethodPost, target: "/api/sponsors",
+			body:       `{"userId":"u1","creatorKey":"neo","campaignId":"c1"}`,
+			upstream:   "POST /v1/server/sponsors",
+			response:   `{"data":{"userId":"u1","campaignId":"c1","creatorKey":"neo","isActive":true}}`,
+			wantStatus: http.StatusCreated,
+			check: func(t *testing.T, _ *httptest.ResponseRecorder, got fakeRequest) {
+				body := jsonBody(t, got)
+				if body["userId"] != "u1" || body["creatorKey"] != "neo" || body["campaignId"] != "c1" {
+					t.Errorf("upstream body = %v", body)
+				}
+			},
+		},
+		{
+			name:   "create without creator",
+			method: http.MethodPost, target: "/api/sponsors",
+			body:       `{"userId":"u1"}`,
+			wantStatus: http.StatusBadRequest, wantProblem: "invalid-input",
+		},
+		{
+			name:   "update",
+			method: http.MethodPut, target: "/api/sponsors/u1",
+			body:       `{"newCreatorKey":"trinity"}`,
+			upstream:   "PUT /v1/server/sponsors/user/u1",
+			response:   `{"data":{"userId":"u1","creatorKey":"trinity"}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, _ *httptest.ResponseRecorder, got fakeRequest) {
+				if body := jsonBody(t, got); body["newCreatorKey"] != "trinity" {
+					t.Errorf("upstream body = %v", body)
+				}
+			},
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete, target: "/api/sponsors/u1?campaignId=c1&callbackId=cb-9",
+			upstream:   "DELETE /v1/server/sponsors/user/u1",
+			status:     http.StatusNoContent,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, _ *httptest.ResponseRecorder, got fakeRequest) {
+				if got.Query.Get("campaignId") != "c1" || got.Query.Get("callbackId") != "cb-9" {
+					t.Errorf("upstream query = %v", got.Query)
+				}
+			},
+		},
+		{
+			name:   "history",
+			method: http.MethodGet, target: "/api/sponsors/u1/history?campaignId=c1",
+			upstream:   "GET /v1/server/sponsors/user/u1/history",
+			response:   `{"data":[{"id":1,"action":"CREATED"},{"id":2,"action":"CHANGED"}],"pagination":{"page":1,"limit":20,"total":2,"totalPages":1}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, got fakeRequest) {
+				if got.Query.Get("campaignId") != "c1" {
+					t.Errorf("upstream query = %v", got.Query)
+				}
+				var page playcamp.PageResult[playcamp.SponsorHistory]
+				decodeData(t, rec, &page)
+				if len(page.Data) != 2 {
+					t.Errorf("page = %+v", page)
+				}
+			},
+		},
+	})
+}
@@ -1,12 +1,12 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -30,6 +30,24 @@ type receivedWebhook struct {
 	Events     []webhookEvent  `json:"events"`
 	ReceivedAt string          `json:"receivedAt"`
 	RawBody    json.RawMessage `json:"rawBody,omitempty"`
+	// SchemaErrors lists event data that does not match the documented
+	// payload shape. The webhook is still stored and acknowledged.
+	SchemaErrors []problemField `json:"schemaErrors,omitempty"`
+	// DuplicateOf is the ID of an earlier valid webhook with the same body,
+	// which PlayCamp sends again when it retries a delivery.
+	DuplicateOf string `json:"duplicateOf,omitempty"`
+}
+
+// hasEvent reports whether wh carries an event of one of types.
+func (wh receivedWebhook) hasEvent(types ...playcamp.WebhookEventType) bool {
+	for _, evt := range wh.Events {
+		for _, t := range types {
+			if playcamp.WebhookEventType(evt.Event) == t {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 type webhookEvent struct {
@@ -43,13 +61,21 @@ func newWebhookStore(maxSize int) *webhookStore {
 	return &webhookStore{maxSize: maxSize}
 }
 
-func (s *webhookStore) add(wh receivedWebhook) {
+func (s *webhookStore) add(wh receivedWebhook) receivedWebhook {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.counter++
 	wh.ID = fmt.Sprintf("wh_%d", s.counter)
 	wh.ReceivedAt = time.Now().UTC().Format(time.RFC3339)
+	if wh.Valid {
+		for _, prev := range s.webhooks {
+			if prev.Valid && bytes.Equal(prev.RawBody, wh.RawBody) {
+				wh.DuplicateOf = prev.ID
+				break
+			}
+		}
+	}
 
 	// Prepend (newest first).
 	s.webhooks = append([]receivedWebhook{wh}, s.webhooks...)
@@ -57,6 +83,7 @@ func (s *webhookStore) add(wh receivedWebhook) {
 	if len(s.webhooks) > s.maxSize {
 		s.webhooks = s.webhooks[:s.maxSize]
 	}
+	return wh
 }
 
 func (s *webhookStore) list() []receivedWebhook {
@@ -68,19 +95,44 @@ func (s *webhookStore) list() []receivedWebhook {
 	return result
 }
 
+// resize changes how many webhooks are kept, dropping the oldest if needed.
+func (s *webhookStore) resize(maxSize int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxSize = maxSize
+	if len(s.webhooks) > s.maxSize {
+		s.webhooks = s.webhooks[:s.maxSize]
+	}
+}
+
 func (s *webhookStore) clear() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.webhooks = nil
 }
 
+type createWebhookRequest struct {
+	EventType  playcamp.WebhookEventType `json:"eventType" validate:"required,enum=webhookEventType"`
+	URL        string                    `json:"url" validate:"required,url,max=2048"`
+	RetryCount *int                      `json:"retryCount,omitempty" validate:"gte=0"`
+	TimeoutMs  *int                      `json:"timeoutMs,omitempty" validate:"positive"`
+}
+
+type updateWebhookRequest struct {
+	URL        *string `json:"url,omitempty" validate:"url,max=2048"`
+	IsActive   *bool   `json:"isActive,omitempty"`
+	RetryCount *int    `json:"retryCount,omitempty" validate:"gte=0"`
+	TimeoutMs  *int    `json:"timeoutMs,omitempty" validate:"positive"`
+}
+
 // --- Webhook Management Handlers ---
 
 // handleListWebhooks handles GET /api/webhooks
 func (a *app) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
 	webhooks, err := a.server.Webhooks.List(r.Context())
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, webhooks)
@@ -88,14 +140,8 @@ func (a *app) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
 
 // handleCreateWebhook handles POST /api/webhooks
 func (a *app) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
-	var body struct {
-		EventType  playcamp.WebhookEventType `json:"eventType"`
-		URL        string                    `json:"url"`
-		RetryCount *int                      `json:"retryCount,omitempty"`
-		TimeoutMs  *int                      `json:"timeoutMs,omitempty"`
-	}
-	if err := decodeJSON(r, &body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+	var body createWebhookRequest
+	if !a.bind(w, r, &body) {
 		return
 	}
 
@@ -106,7 +152,7 @@ func (a *app) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
 		TimeoutMs:  body.TimeoutMs,
 	})
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusCreated, webhook)
@@ -116,19 +162,23 @@ func (a *app) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
 func (a *app) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid webhook ID")
+		writeError(w, r, http.StatusBadRequest, "invalid webhook ID")
 		return
 	}
 
-	var body playcamp.UpdateWebhookParams
-	if err := decodeJSON(r, &body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
+	var body updateWebhookRequest
+	if !a.bind(w, r, &body) {
 		return
 	}
 
-	webhook, err := a.server.Webhooks.Update(r.Context(), id, body)
+	webhook, err := a.server.Webhooks.Update(r.Context(), id, playcamp.UpdateWebhookParams{
+		URL:        body.URL,
+		IsActive:   body.IsActive,
+		RetryCount: body.RetryCount,
+		TimeoutMs:  body.TimeoutMs,
+	})
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, webhook)
@@ -138,12 +188,12 @@ func (a *app) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
 func (a *app) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid webhook ID")
+		writeError(w, r, http.StatusBadRequest, "invalid webhook ID")
 		return
 	}
 
 	if err := a.server.Webhooks.Delete(r.Context(), id); err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]bool{"deleted": true})
@@ -153,13 +203,13 @@ func (a *app) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
 func (a *app) handleGetWebhookLogs(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid webhook ID")
+		writeError(w, r, http.StatusBadRequest, "invalid webhook ID")
 		return
 	}
 
 	logs, err := a.server.Webhooks.GetLogs(r.Context(), id)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, logs)
@@ -169,13 +219,13 @@ func (a *app) handleGetWebhookLogs(w http.ResponseWriter, r *http.Request) {
 func (a *app) handleTestWebhook(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "invalid webhook ID")
+		writeError(w, r, http.StatusBadRequest, "invalid webhook ID")
 		return
 	}
 
 	result, err := a.server.Webhooks.Test(r.Context(), id)
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, result)
@@ -187,17 +237,18 @@ func (a *app) handleTestWebhook(w http.ResponseWriter, r *http.Request) {
 func (a *app) handleWebhookReceiver(w http.ResponseWriter, r *http.Request) {
 	body, err := readRawBody(r)
 	if err != nil {
-		writeError(w, http.StatusBadRequest, "failed to read body")
+		writeError(w, r, http.StatusBadRequest, "failed to read body")
 		return
 	}
 
-	signature := r.Header.Get("X-Webhook-Signature")
+	wh := a.receiveWebhook(r.Context(), body, r.Header.Get("X-Webhook-Signature"))
+	writeJSON(w, http.StatusOK, map[string]any{"received": true, "id": wh.ID})
+}
 
-	result := webhookutil.Verify(webhookutil.VerifyOptions{
-		Payload:   body,
-		Signature: signature,
-		Secret:    a.webhookSecret,
-	})
+// receiveWebhook verifies and stores a delivery and applies its events to
+// sponsor state and the revenue ledger. Invalid deliveries are stored too.
+func (a *app) receiveWebhook(ctx context.Context, body []byte, signature string) receivedWebhook {
+	result := a.verifyWebhook(body, signature)
 
 	wh := receivedWebhook{
 		Valid:   result.Valid,
@@ -219,7 +270,10 @@ func (a *app) handleWebhookReceiver(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	a.receivedWebhooks.add(wh)
+	wh.SchemaErrors = checkWebhookSchema(wh.Events)
+	wh = a.receivedWebhooks.add(wh)
+	a.applySponsorWebhook(ctx, wh)
+	a.applyRevenueWebhook(ctx, wh)
 
 	// Log webhook reception.
 	if result.Valid {
@@ -227,12 +281,42 @@ func (a *app) handleWebhookReceiver(w http.ResponseWriter, r *http.Request) {
 		for _, evt := range wh.Events {
 			events = append(events, evt.Event)
 		}
-		log.Printf("[webhook] received valid webhook: events=[%s]", strings.Join(events, ", "))
+		a.webhookLog.InfoContext(ctx, "received valid webhook", "id", wh.ID, "events", events)
+		if wh.DuplicateOf != "" {
+			a.webhookLog.InfoContext(ctx, "webhook is a duplicate delivery", "id", wh.ID, "duplicateOf", wh.DuplicateOf)
+		}
+		if len(wh.SchemaErrors) > 0 {
+			a.webhookLog.WarnContext(ctx, "webhook payload does not match schema", "errors", wh.SchemaErrors)
+		}
 	} else {
-		log.Printf("[webhook] received invalid webhook: %s", result.Error)
+		a.webhookLog.WarnContext(ctx, "received invalid webhook", "error", result.Error)
+	}
+	return wh
+}
+
+// verifyWebhook checks signature against every configured secret and returns
+// the first valid result, or the result for the primary secret if none match.
+func (a *app) verifyWebhook(body []byte, signature string) webhookutil.VerifyResult {
+	secrets := a.acceptedWebhookSecrets()
+	if len(secrets) == 0 {
+		secrets = []string{""}
 	}
 
-	writeJSON(w, http.StatusOK, map[string]bool{"received": true})
+	var first webhookutil.VerifyResult
+	for i, secret := range secrets {
+		result := webhookutil.Verify(webhookutil.VerifyOptions{
+			Payload:   body,
+			Signature: signature,
+			Secret:    secret,
+		})
+		if result.Valid {
+			return result
+		}
+		if i == 0 {
+			first = result
+		}
+	}
+	return first
 }
 
 // --- In-Memory Webhook Store Endpoints ---
@@ -247,29 +331,3 @@ func (a *app) handleClearReceivedWebhooks(w http.ResponseWriter, r *http.Request
 	a.receivedWebhooks.clear()
 	writeJSON(w, http.StatusOK, map[string]bool{"cleared": true})
 }
-
-// handleSimulateWebhook handles POST /api/webhooks/simulate
-func (a *app) handleSimulateWebhook(w http.ResponseWriter, r *http.Request) {
-	var body struct {
-		Event string          `json:"event"`
-		Data  json.RawMessage `json:"data"`
-	}
-	if err := decodeJSON(r, &body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
-		return
-	}
-
-	wh := receivedWebhook{
-		Valid: true,
-		Events: []webhookEvent{
-			{
-				Event:     body.Event,
-				Timestamp: time.Now().UTC().Format(time.RFC3339),
-				Data:      body.Data,
-			},
-		},
-	}
-
-	a.receivedWebhooks.add(wh)
-	writeJSON(w, http.StatusOK, map[string]bool{"simulated": true})
-}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+	"github.com/playcamp/playcamp-go-sdk/webhookutil"
+)
+
+func TestWebhookHandlers(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{
+			name:   "list",
+			method: http.MethodGet, target: "/api/webhooks",
+			upstream:   "GET /v1/server/webhooks",
+			response:   `{"data":[{"id":1,"eventType":"coupon.redeemed","url":"https://example.com/hook","isActive":true}]}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				var hooks []playcamp.Webhook
+				decodeData(t, rec, &hooks)
+				if len(hooks) != 1 || hooks[0].EventType != playcamp.WebhookEventCouponRedeemed {
+					t.Errorf("webhooks = %+v", hooks)
+				}
+			},
+		},
+		{
+			name:   "create",
+			method: http.MethodPost, target: "/api/webhooks",
+			body:       `{"eventType":"payment.created","url":"https://example.com/hook","retryCount":3}`,
+			upstream:   "POST /v1/server/webhooks",
+			response:   `{"data":{"id":2,"eventType":"payment.created","secret":"whsec_new"}}`,
+			wantStatus: http.StatusCreated,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, got fakeRequest) {
+				if body := jsonBody(t, got); body["retryCount"] != 3.0 {
+					t.Errorf("upstream body = %v", body)
+				}
+				var hook playcamp.WebhookWithSecret
+				decodeData(t, rec, &hook)
+				if hook.Secret != "whsec_new" || hook.ID != 2 {
+					t.Errorf("webhook = %+v", hook)
+				}
+			},
+		},
+		{
+			name:   "create unknown event",
+			method: http.MethodPost, target: "/api/webhooks",
+			body:       `{"eventType":"coupon.deleted","url":"ftp://example.com"}`,
+			wantStatus: http.StatusBadRequest, wantProblem: "invalid-input",
+		},
+		{
+			name:   "update",
+			method: http.MethodPut, target: "/api/webhooks/2",
+			body:       `{"isActive":false}`,
+			upstream:   "PUT /v1/server/webhooks/2",
+			response:   `{"data":{"id":2,"isActive":false}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, _ *httptest.ResponseRecorder, got fakeRequest) {
+				if body := jsonBody(t, got); body["isActive"] != false || body["url"] != nil {
+					t.Errorf("upstream body = %v", body)
+				}
+			},
+		},
+		{
+			name:   "update bad id",
+			method: http.MethodPut, target: "/api/webhooks/two",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest, wantProblem: "bad-request",
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete, target: "/api/webhooks/2",
+			upstream:   "DELETE /v1/server/webhooks/2",
+			status:     http.StatusNoContent,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "logs",
+			method: http.MethodGet, target: "/api/webhooks/2/logs",
+			upstream:   "GET /v1/server/webhooks/2/logs",
+			response:   `{"data":[{"id":"log1","webhookId":2,"status":"SUCCESS","attempt":1}]}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "test",
+			method: http.MethodPost, target: "/api/webhooks/2/test",
+			upstream:   "POST /v1/server/webhooks/2/test",
+			response:   `{"data":{"success":true,"responseStatus":200}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, _ fakeRequest) {
+				var res playcamp.WebhookTestResult
+				decodeData(t, rec, &res)
+				if !res.Success {
+					t.Errorf("result = %+v", res)
+				}
+			},
+		},
+		{
+			name:   "get is not supported",
+			method: http.MethodGet, target: "/api/webhooks/2",
+			wantStatus: http.StatusNotFound, wantProblem: "not-found",
+		},
+	})
+}
+
+func TestWebhookReceiverSignatures(t *testing.T) {
+	payload := []byte(`{"events":[{"event":"coupon.redeemed","timestamp":"2026-01-01T00:00:00Z","data":{"couponCode":"NEO-1","userId":"u1","usageId":1}}]}`)
+	tampered := []byte(strings.Replace(string(payload), "NEO-1", "NEO-2", 1))
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		wantValid bool
+		wantError string
+	}{
+		{
+			name:      "simple signature",
+			body:      payload,
+			signature: webhookutil.ConstructSignature(payload, testWebhookSecret, nil),
+			wantValid: true,
+		},
+		{
+			name:      "timestamped signature",
+			body:      payload,
+			signature: webhookutil.ConstructSignature(payload, testWebhookSecret, &webhookutil.SignatureOptions{Timestamped: true}),
+			wantValid: true,
+		},
+		{
+			name:      "tampered body",
+			body:      tampered,
+			signature: webhookutil.ConstructSignature(payload, testWebhookSecret, nil),
+		},
+		{
+			name:      "wrong secret",
+			body:      payload,
+			signature: webhookutil.ConstructSignature(payload, "other_secret", nil),
+		},
+		{
+			name:      "stale timestamp",
+			body:      payload,
+			signature: webhookutil.ConstructSignature(payload, testWebhookSecret, &webhookutil.SignatureOptions{Timestamped: true, Timestamp: now - 3600}),
+			wantError: "tolerance",
+		},
+		{
+			name: "missing signature",
+			body: payload,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t, newFakePlayCamp(t), nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/playcamp", strings.NewReader(string(tc.body)))
+			if tc.signature != "" {
+				req.Header.Set("X-Webhook-Signature", tc.signature)
+			}
+			rec := httptest.NewRecorder()
+			a.router.ServeHTTP(rec, req)
+
+			// The receiver always acknowledges so PlayCamp does not retry.
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d; body %s", rec.Code, rec.Body)
+			}
+			stored := a.receivedWebhooks.list()
+			if len(stored) != 1 {
+				t.Fatalf("stored = %d webhooks, want 1", len(stored))
+			}
+			wh := stored[0]
+			if wh.Valid != tc.wantValid {
+				t.Errorf("valid = %v, want %v (error %q)", wh.Valid, tc.wantValid, wh.Error)
+			}
+			if !tc.wantValid && wh.Error == "" {
+				t.Error("invalid webhook stored without an error")
+			}
+			if tc.wantError != "" && !strings.Contains(wh.Error, tc.wantError) {
+				t.Errorf("error = %q, want it to mention %q", wh.Error, tc.wantError)
+			}
+			if tc.wantValid && (len(wh.Events) != 1 || wh.Events[0].Event != "coupon.redeemed") {
+				t.Errorf("events = %+v", wh.Events)
+			}
+		})
+	}
+}
+
+func TestWebhookReceiverRotatedSecret(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), map[string]string{
+		"WEBHOOK_SECRET":  "whsec_new",
+		"WEBHOOK_SECRETS": testWebhookSecret,
+	})
+	payload := []byte(`{"events":[]}`)
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/playcamp", strings.NewReader(string(payload)))
+	req.Header.Set("X-Webhook-Signature", webhookutil.ConstructSignature(payload, testWebhookSecret, nil))
+	a.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if wh := a.receivedWebhooks.list(); len(wh) != 1 || !wh[0].Valid {
+		t.Errorf("webhook signed with the previous secret was rejected: %+v", wh)
+	}
+}
+
+func TestReceivedWebhookStore(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), map[string]string{"WEBHOOK_STORE_SIZE": "2"})
+
+	for i := 0; i < 3; i++ {
+		body := fmt.Sprintf(`{"event":"payment.created","data":{"transactionId":"tx-%d"}}`, i)
+		if rec := serve(a, http.MethodPost, "/api/webhooks/simulate", body); rec.Code != http.StatusOK {
+			t.Fatalf("simulate: status = %d; body %s", rec.Code, rec.Body)
+		}
+	}
+
+	var stored []receivedWebhook
+	decodeData(t, serve(a, http.MethodGet, "/api/webhooks/received", ""), &stored)
+	if len(stored) != 2 {
+		t.Fatalf("stored = %d, want the store size 2", len(stored))
+	}
+	if !strings.Contains(string(stored[0].Events[0].Data), "tx-2") {
+		t.Errorf("newest webhook is not first: %s", stored[0].Events[0].Data)
+	}
+
+	serve(a, http.MethodDelete, "/api/webhooks/received", "")
+	if n := len(a.receivedWebhooks.list()); n != 0 {
+		t.Errorf("stored after clear = %d", n)
+	}
+
+	rec := serve(a, http.MethodPost, "/api/webhooks/simulate", `{"event":"nope"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("simulate unknown event: status = %d", rec.Code)
+	}
+}
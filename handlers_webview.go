@@ -6,25 +6,22 @@ import (
 	playcamp "github.com/playcamp/playcamp-go-sdk"
 )
 
+type webviewTokenRequest struct {
+	UserID     string `json:"userId" validate:"required,max=128"`
+	CampaignID string `json:"campaignId,omitempty" validate:"max=64"`
+	CallbackID string `json:"callbackId,omitempty" validate:"max=128"`
+	IsTest     *bool  `json:"isTest,omitempty"`
+}
+
 // handleWebviewToken handles POST /webview/token
 func (a *app) handleWebviewToken(w http.ResponseWriter, r *http.Request) {
-	var body struct {
-		UserID     string `json:"userId"`
-		CampaignID string `json:"campaignId,omitempty"`
-		CallbackID string `json:"callbackId,omitempty"`
-		IsTest     *bool  `json:"isTest,omitempty"`
-	}
-	if err := decodeJSON(r, &body); err != nil {
-		writeError(w, http.StatusBadRequest, "invalid JSON body")
-		return
-	}
-	if body.UserID == "" {
-		writeError(w, http.StatusBadRequest, "userId is required")
+	var body webviewTokenRequest
+	if !a.bind(w, r, &body) {
 		return
 	}
 
 	isTest := body.IsTest != nil && *body.IsTest
-	sdk := a.getSDK(isTest)
+	sdk := a.getSDK(r, isTest)
 
 	result, err := sdk.Webview.CreateOTT(r.Context(), playcamp.WebviewOttParams{
 		UserID:     body.UserID,
@@ -32,7 +29,7 @@ func (a *app) handleWebviewToken(w http.ResponseWriter, r *http.Request) {
 		CallbackID: body.CallbackID,
 	})
 	if err != nil {
-		handleSDKError(w, err)
+		handleSDKError(w, r, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, result)
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+func TestWebviewHandlers(t *testing.T) {
+	runHandlerCases(t, []handlerCase{
+		{
+			name:   "token",
+			method: http.MethodPost, target: "/webview/token",
+			body:       `{"userId":"u1","campaignId":"c1"}`,
+			upstream:   "POST /v1/server/webview/ott",
+			response:   `{"data":{"ott":"ott_abc","expiresAt":"2026-01-01T00:05:00Z"}}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, rec *httptest.ResponseRecorder, got fakeRequest) {
+				if body := jsonBody(t, got); body["userId"] != "u1" || body["campaignId"] != "c1" {
+					t.Errorf("upstream body = %v", body)
+				}
+				var res playcamp.WebviewOttResult
+				decodeData(t, rec, &res)
+				if res.OTT != "ott_abc" {
+					t.Errorf("result = %+v", res)
+				}
+			},
+		},
+		{
+			name:   "token without user",
+			method: http.MethodPost, target: "/webview/token",
+			body:       `{}`,
+			wantStatus: http.StatusBadRequest, wantProblem: "invalid-input",
+		},
+	})
+}
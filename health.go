@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+const (
+	// upstreamCheckTTL is how long a PlayCamp reachability result is reused so
+	// that frequent probes don't spend API quota.
+	upstreamCheckTTL = 30 * time.Second
+
+	// checkTimeout bounds a single readiness check.
+	checkTimeout = 5 * time.Second
+
+	// maxReadyBacklog is the most background work an instance may have
+	// queued and still report ready. Past it, PlayCamp lookups are not
+	// keeping up, and taking the instance out of rotation lets it drain.
+	maxReadyBacklog = 1000
+)
+
+// readinessCheck is a named dependency check run by /readyz.
+type readinessCheck struct {
+	name  string
+	ttl   time.Duration
+	check func(ctx context.Context) error
+}
+
+type checkResult struct {
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	CheckedAt string `json:"checkedAt"`
+	Cached    bool   `json:"cached,omitempty"`
+
+	at time.Time
+}
+
+// healthChecker runs readiness checks and caches their results per TTL.
+type healthChecker struct {
+	checks []readinessCheck
+
+	mu    sync.Mutex
+	cache map[string]checkResult
+}
+
+func newHealthChecker() *healthChecker {
+	return &healthChecker{cache: map[string]checkResult{}}
+}
+
+// add registers a readiness check. A zero ttl runs the check on every probe.
+func (h *healthChecker) add(name string, ttl time.Duration, check func(ctx context.Context) error) {
+	h.checks = append(h.checks, readinessCheck{name: name, ttl: ttl, check: check})
+}
+
+// run executes every check concurrently and reports whether all passed.
+func (h *healthChecker) run(ctx context.Context) (map[string]checkResult, bool) {
+	results := make(map[string]checkResult, len(h.checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, c := range h.checks {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := h.runOne(ctx, c)
+			mu.Lock()
+			results[c.name] = res
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, res := range results {
+		if res.Status != "ok" {
+			ready = false
+		}
+	}
+	return results, ready
+}
+
+func (h *healthChecker) runOne(ctx context.Context, c readinessCheck) checkResult {
+	if c.ttl > 0 {
+		h.mu.Lock()
+		cached, ok := h.cache[c.name]
+		h.mu.Unlock()
+		if ok && time.Since(cached.at) < c.ttl {
+			cached.Cached = true
+			return cached
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	now := time.Now().UTC()
+	res := checkResult{Status: "ok", CheckedAt: now.Format(time.RFC3339), at: now}
+	if err := c.check(ctx); err != nil {
+		res.Status = "fail"
+		res.Error = err.Error()
+	}
+
+	if c.ttl > 0 {
+		h.mu.Lock()
+		h.cache[c.name] = res
+		h.mu.Unlock()
+	}
+	return res
+}
+
+// playcampCheck verifies that the PlayCamp API is reachable and accepts our key
+// using the cheapest read available.
+func playcampCheck(sdk *playcamp.Server) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := sdk.Campaigns.List(ctx, &playcamp.PaginationOptions{
+			Page:  playcamp.Int(1),
+			Limit: playcamp.Int(1),
+		})
+		return err
+	}
+}
+
+// registerReadinessChecks wires the dependencies /readyz reports on.
+func (a *app) registerReadinessChecks() {
+	a.health.add("lifecycle", 0, a.lifecycle.readiness)
+	a.health.add("playcamp", upstreamCheckTTL, playcampCheck(a.server))
+	a.health.add("playcampTest", upstreamCheckTTL, playcampCheck(a.testServer))
+	a.health.add("backlog", 0, a.backlogCheck)
+}
+
+// backlogCheck fails when too many revenue transactions are waiting to be
+// looked up in PlayCamp. These are the only queued background work; received
+// webhooks are kept in memory and need no check.
+func (a *app) backlogCheck(context.Context) error {
+	if n := a.revenue.backlog(); n > maxReadyBacklog {
+		return fmt.Errorf("%d revenue transactions waiting to be looked up, limit %d", n, maxReadyBacklog)
+	}
+	return nil
+}
+
+// --- Handlers ---
+
+// handleHealthz handles GET /healthz
+func (a *app) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz handles GET /readyz
+func (a *app) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	checks, ready := a.health.run(r.Context())
+
+	status, code := "ready", http.StatusOK
+	if !ready {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// handleStatus handles GET /status
+func (a *app) handleStatus(w http.ResponseWriter, r *http.Request) {
+	cfg := a.config()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"environment":             cfg.effectiveEnvironment(),
+		"apiUrl":                  cfg.effectiveAPIURL(),
+		"debug":                   cfg.SDK.Debug,
+		"webhookSecretConfigured": len(a.acceptedWebhookSecrets()) > 0,
+		"authEnabled":             len(cfg.Auth.Clients) > 0,
+		"startedAt":               a.startedAt.UTC().Format(time.RFC3339),
+		"uptimeSeconds":           int64(time.Since(a.startedAt).Seconds()),
+		"version":                 buildVersion(),
+	})
+}
+
+// versionInfo describes the running binary.
+type versionInfo struct {
+	Version    string `json:"version"`
+	Revision   string `json:"revision,omitempty"`
+	BuildTime  string `json:"buildTime,omitempty"`
+	Modified   bool   `json:"modified,omitempty"`
+	GoVersion  string `json:"goVersion"`
+	SDKVersion string `json:"sdkVersion,omitempty"`
+	ChiVersion string `json:"chiVersion,omitempty"`
+}
+
+// buildVersion reads version information embedded by the Go toolchain.
+func buildVersion() versionInfo {
+	v := versionInfo{Version: "(devel)", GoVersion: runtime.Version()}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	if info.Main.Version != "" {
+		v.Version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v.Revision = s.Value
+		case "vcs.time":
+			v.BuildTime = s.Value
+		case "vcs.modified":
+			v.Modified = s.Value == "true"
+		}
+	}
+	for _, dep := range info.Deps {
+		switch dep.Path {
+		case "github.com/playcamp/playcamp-go-sdk":
+			v.SDKVersion = dep.Version
+		case "github.com/go-chi/chi/v5":
+			v.ChiVersion = dep.Version
+		}
+	}
+	return v
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckerCachesByTTL(t *testing.T) {
+	h := newHealthChecker()
+	// The checks run concurrently, so each counts its own calls.
+	var cachedCalls, liveCalls int
+	fail := false
+	h.add("cached", time.Minute, func(context.Context) error {
+		cachedCalls++
+		return nil
+	})
+	h.add("live", 0, func(context.Context) error {
+		liveCalls++
+		if fail {
+			return errors.New("down")
+		}
+		return nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, ready := h.run(context.Background()); !ready {
+			t.Fatalf("run %d not ready", i)
+		}
+	}
+	fail = true
+	results, ready := h.run(context.Background())
+	if ready || results["live"].Status != "fail" || results["live"].Error != "down" || !results["cached"].Cached {
+		t.Errorf("results = %+v, ready %v", results, ready)
+	}
+	if cachedCalls != 1 || liveCalls != 4 {
+		t.Errorf("calls = %d cached, %d live", cachedCalls, liveCalls)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodGet, "/v1/server/campaigns", http.StatusOK, `{"data":[],"pagination":{"page":1,"limit":1,"total":0,"totalPages":0}}`)
+	a := newTestApp(t, fake, nil)
+
+	var body struct {
+		Status string                 `json:"status"`
+		Checks map[string]checkResult `json:"checks"`
+	}
+	decodeData(t, serve(a, http.MethodGet, "/readyz", ""), &body)
+	for _, name := range []string{"lifecycle", "playcamp", "playcampTest", "backlog"} {
+		if body.Checks[name].Status != "ok" {
+			t.Errorf("%s = %+v", name, body.Checks[name])
+		}
+	}
+
+	for i := 0; i <= maxReadyBacklog; i++ {
+		a.revenue.addUnresolved(revenueTxKey{transactionID: fmt.Sprint("t", i)})
+	}
+	rec := serve(a, http.MethodGet, "/readyz", "")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503; body %s", rec.Code, rec.Body)
+	}
+}
@@ -14,16 +14,9 @@ func writeJSON(w http.ResponseWriter, status int, v any) {
 	json.NewEncoder(w).Encode(map[string]any{"data": v})
 }
 
-// writeError writes a JSON error response.
-func writeError(w http.ResponseWriter, status int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(map[string]any{"error": message})
-}
-
-// decodeJSON decodes the request body into v.
-func decodeJSON(r *http.Request, v any) error {
-	return json.NewDecoder(r.Body).Decode(v)
+// writeError writes a problem+json error response.
+func writeError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeProblem(w, newProblem(r, problemTypeForStatus(status), status, message))
 }
 
 // readRawBody reads the entire request body as bytes.
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// errShuttingDown is reported by readiness while the server drains.
+var errShuttingDown = errors.New("server is shutting down")
+
+// lifecycle tracks background work and shutdown hooks so that main can drain
+// them after the HTTP server has stopped accepting requests.
+type lifecycle struct {
+	log *slog.Logger
+
+	// ctx is cancelled when shutdown begins. Background workers should stop
+	// taking new work when it is done, flush what they hold, and return.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	draining     atomic.Bool
+	drainOnce    sync.Once
+	drainStarted chan struct{}
+
+	mu    sync.Mutex
+	hooks []shutdownHook
+}
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func newLifecycle(log *slog.Logger) *lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &lifecycle{log: log, ctx: ctx, cancel: cancel, drainStarted: make(chan struct{})}
+}
+
+// goBackground runs fn in a tracked goroutine. Shutdown waits for it to
+// return, up to the shutdown deadline.
+func (l *lifecycle) goBackground(name string, fn func(ctx context.Context)) {
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		fn(l.ctx)
+		l.log.Debug("background worker stopped", "worker", name)
+	}()
+}
+
+// onShutdown registers fn to run after background workers have drained.
+// Hooks run in reverse registration order.
+func (l *lifecycle) onShutdown(name string, fn func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, shutdownHook{name: name, fn: fn})
+}
+
+// isDraining reports whether shutdown has begun.
+func (l *lifecycle) isDraining() bool {
+	return l.draining.Load()
+}
+
+// drained is closed when shutdown begins. Long-running handlers select on it
+// so they do not hold up the drain.
+func (l *lifecycle) drained() <-chan struct{} {
+	return l.drainStarted
+}
+
+// readiness fails once shutdown has begun so load balancers stop routing here.
+func (l *lifecycle) readiness(context.Context) error {
+	if l.isDraining() {
+		return errShuttingDown
+	}
+	return nil
+}
+
+// beginDrain marks the server as draining without stopping anything yet.
+func (l *lifecycle) beginDrain() {
+	l.draining.Store(true)
+	l.drainOnce.Do(func() { close(l.drainStarted) })
+}
+
+// shutdown stops background workers, waits for them within ctx, then runs
+// the registered hooks. It returns the first error encountered.
+func (l *lifecycle) shutdown(ctx context.Context) error {
+	l.beginDrain()
+	l.cancel()
+
+	var firstErr error
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		firstErr = errors.New("background workers did not finish before the shutdown deadline")
+		l.log.Warn("background workers still running at shutdown deadline")
+	}
+
+	l.mu.Lock()
+	hooks := make([]shutdownHook, len(l.hooks))
+	copy(hooks, l.hooks)
+	l.mu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if err := h.fn(ctx); err != nil {
+			l.log.Error("shutdown hook failed", "hook", h.name, "error", err.Error())
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
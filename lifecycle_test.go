@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+func TestLifecycleShutdown(t *testing.T) {
+	l := newLifecycle(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var mu sync.Mutex
+	var order []string
+	record := func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, s)
+	}
+	l.goBackground("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		record("worker")
+	})
+	l.onShutdown("first", func(context.Context) error {
+		record("first")
+		return nil
+	})
+	l.onShutdown("second", func(context.Context) error {
+		record("second")
+		return errors.New("flush failed")
+	})
+
+	select {
+	case <-l.drained():
+		t.Fatal("drained before shutdown")
+	default:
+	}
+	if err := l.readiness(context.Background()); err != nil {
+		t.Fatalf("readiness = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := l.shutdown(ctx); err == nil || err.Error() != "flush failed" {
+		t.Errorf("shutdown = %v, want hook error", err)
+	}
+	if got := strings.Join(order, ","); got != "worker,second,first" {
+		t.Errorf("order = %s", got)
+	}
+	select {
+	case <-l.drained():
+	default:
+		t.Error("drained not closed after shutdown")
+	}
+	if err := l.readiness(context.Background()); !errors.Is(err, errShuttingDown) {
+		t.Errorf("readiness = %v", err)
+	}
+}
+
+func TestLifecycleShutdownDeadline(t *testing.T) {
+	l := newLifecycle(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	release := make(chan struct{})
+	defer close(release)
+	l.goBackground("stuck", func(context.Context) { <-release })
+	hookRan := false
+	l.onShutdown("hook", func(context.Context) error {
+		hookRan = true
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.shutdown(ctx); err == nil {
+		t.Error("shutdown = nil, want deadline error")
+	}
+	if !hookRan {
+		t.Error("hooks skipped after deadline")
+	}
+}
+
+func TestScenarioStopsOnDrain(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		done <- serve(a, http.MethodPost, "/api/webhooks/scenarios/run", `{"scenario":{"name":"slow","steps":[{"event":"payment.created"},{"event":"payment.created","delay":"50s"}]}}`)
+	}()
+
+	// Wait for the first delivery so the run is parked on the delay.
+	deadline := time.Now().Add(5 * time.Second)
+	for len(a.receivedWebhooks.list()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("first step not delivered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	a.lifecycle.beginDrain()
+
+	select {
+	case rec := <-done:
+		var res scenarioRunResult
+		decodeData(t, rec, &res)
+		if rec.Code != http.StatusOK || !res.Interrupted || len(res.Deliveries) != 1 {
+			t.Errorf("status = %d, result %+v", rec.Code, res)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("scenario run did not stop on drain")
+	}
+}
+
+func TestLogDiscardedState(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	var buf bytes.Buffer
+	a.lifecycle.log = slog.New(slog.NewJSONHandler(&buf, nil))
+	a.quarantine.add(quarantinedPayment{
+		TransactionID: "GPA.7",
+		Reason:        "purchase is pending",
+		Payment:       paymentItem{UserID: "u1", TransactionID: "GPA.7", Amount: 4.99, Currency: "USD", Receipt: playcamp.String("store-receipt-token")},
+	})
+
+	a.logDiscardedState(context.Background())
+	out := buf.String()
+	if !strings.Contains(out, `"transactionId":"GPA.7"`) || !strings.Contains(out, `"userId":"u1"`) {
+		t.Errorf("log = %s", out)
+	}
+	if strings.Contains(out, "store-receipt-token") {
+		t.Errorf("receipt logged: %s", out)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Subsystem names used for per-subsystem log levels.
+const (
+	logApp     = "app"
+	logHTTP    = "http"
+	logSDK     = "sdk"
+	logWebhook = "webhook"
+)
+
+// redactedValue replaces the value of any sensitive log attribute or JSON field.
+const redactedValue = "[REDACTED]"
+
+// sensitiveKeys lists attribute and JSON field names (lower-cased) whose values
+// must never reach the logs.
+var sensitiveKeys = map[string]bool{
+	"apikey":              true,
+	"api_key":             true,
+	"authorization":       true,
+	"secret":              true,
+	"webhooksecret":       true,
+	"signature":           true,
+	"x-webhook-signature": true,
+	"receipt":             true,
+	"ott":                 true,
+	"token":               true,
+}
+
+func isSensitiveKey(key string) bool {
+	return sensitiveKeys[strings.ToLower(key)]
+}
+
+// logRegistry hands out subsystem loggers that share one JSON handler but
+// have independently adjustable levels.
+type logRegistry struct {
+	handler slog.Handler
+
+	mu        sync.Mutex
+	fallback  slog.Level
+	overrides map[string]slog.Level
+	levels    map[string]*slog.LevelVar
+}
+
+func newLogRegistry(w io.Writer) *logRegistry {
+	return &logRegistry{
+		handler: &requestIDHandler{slog.NewJSONHandler(w, &slog.HandlerOptions{
+			Level:       slog.LevelDebug,
+			ReplaceAttr: redactAttr,
+		})},
+		fallback:  slog.LevelInfo,
+		overrides: map[string]slog.Level{},
+		levels:    map[string]*slog.LevelVar{},
+	}
+}
+
+// logger returns the logger for the given subsystem.
+func (l *logRegistry) logger(subsystem string) *slog.Logger {
+	return slog.New(&levelHandler{
+		Handler: l.handler.WithAttrs([]slog.Attr{slog.String("subsystem", subsystem)}),
+		level:   l.levelVar(subsystem),
+	})
+}
+
+func (l *logRegistry) levelVar(subsystem string) *slog.LevelVar {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	lv, ok := l.levels[subsystem]
+	if !ok {
+		lv = new(slog.LevelVar)
+		lv.Set(l.levelFor(subsystem))
+		l.levels[subsystem] = lv
+	}
+	return lv
+}
+
+// levelFor returns the configured level of a subsystem. Callers must hold l.mu.
+func (l *logRegistry) levelFor(subsystem string) slog.Level {
+	if lvl, ok := l.overrides[subsystem]; ok {
+		return lvl
+	}
+	return l.fallback
+}
+
+// setLevels applies a level spec such as "info,webhook=debug,sdk=warn". The
+// bare entry sets the default; subsystems not named in the spec follow it.
+func (l *logRegistry) setLevels(spec string) error {
+	fallback, overrides, err := parseLevelSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.fallback = fallback
+	l.overrides = overrides
+	for name, lv := range l.levels {
+		lv.Set(l.levelFor(name))
+	}
+	return nil
+}
+
+// parseLevelSpec parses a level spec into the default level and per-subsystem
+// overrides. An empty spec means info for everything.
+func parseLevelSpec(spec string) (slog.Level, map[string]slog.Level, error) {
+	fallback := slog.LevelInfo
+	overrides := map[string]slog.Level{}
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, scoped := strings.Cut(part, "=")
+		if !scoped {
+			value = name
+		}
+
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+			return 0, nil, fmt.Errorf("invalid log level %q", part)
+		}
+		if scoped {
+			overrides[strings.TrimSpace(name)] = lvl
+		} else {
+			fallback = lvl
+		}
+	}
+	return fallback, overrides, nil
+}
+
+// setDefaultLevel sets a subsystem's level unless the spec already names it.
+func (l *logRegistry) setDefaultLevel(subsystem string, lvl slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.overrides[subsystem]; ok {
+		return
+	}
+	l.overrides[subsystem] = lvl
+	if lv, ok := l.levels[subsystem]; ok {
+		lv.Set(lvl)
+	}
+}
+
+// levelHandler filters records against a subsystem's own level.
+type levelHandler struct {
+	slog.Handler
+	level *slog.LevelVar
+}
+
+func (h *levelHandler) Enabled(_ context.Context, lvl slog.Level) bool {
+	return lvl >= h.level.Level()
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// requestIDHandler adds the request ID carried by the context to every record.
+type requestIDHandler struct {
+	slog.Handler
+}
+
+func (h *requestIDHandler) Handle(ctx context.Context, rec slog.Record) error {
+	if id := middleware.GetReqID(ctx); id != "" {
+		rec.AddAttrs(slog.String("requestId", id))
+	}
+	return h.Handler.Handle(ctx, rec)
+}
+
+func (h *requestIDHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &requestIDHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *requestIDHandler) WithGroup(name string) slog.Handler {
+	return &requestIDHandler{h.Handler.WithGroup(name)}
+}
+
+// redactAttr masks sensitive attributes before they are written.
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if isSensitiveKey(a.Key) {
+		return slog.String(a.Key, redactedValue)
+	}
+	return a
+}
+
+// redactJSON returns body with sensitive fields masked at any depth. Non-JSON
+// bodies are replaced by a placeholder since their contents are unknown.
+func redactJSON(body []byte) json.RawMessage {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return json.RawMessage(`"[non-JSON body]"`)
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return json.RawMessage(`"[unencodable body]"`)
+	}
+	return out
+}
+
+func redactValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			if isSensitiveKey(k) {
+				t[k] = redactedValue
+			} else {
+				t[k] = redactValue(child)
+			}
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = redactValue(child)
+		}
+	}
+	return v
+}
+
+// --- Middleware ---
+
+// requestIDHeader echoes the request ID assigned by middleware.RequestID back
+// to the caller so client and server logs can be correlated.
+func requestIDHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := middleware.GetReqID(r.Context()); id != "" {
+			w.Header().Set(middleware.RequestIDHeader, id)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestLogger logs one structured line per request.
+func requestLogger(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+				log.InfoContext(r.Context(), "request",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"status", status,
+					"bytes", ww.BytesWritten(),
+					"durationMs", time.Since(start).Milliseconds(),
+					"remote", r.RemoteAddr,
+				)
+			}()
+
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
+
+// recoverer turns handler panics into a logged 500 response.
+func recoverer(log *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				log.ErrorContext(r.Context(), "panic in handler",
+					"panic", fmt.Sprint(rec),
+					"stack", string(debug.Stack()),
+				)
+				writeError(w, r, http.StatusInternalServerError, "internal server error")
+			}()
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// --- SDK transport ---
+
+// sdkTransport logs every PlayCamp API call under the request ID of the
+// inbound request that triggered it, and forwards that ID upstream.
+type sdkTransport struct {
+	next      http.RoundTripper
+	log       *slog.Logger
+	logBodies atomic.Bool
+}
+
+func (t *sdkTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if id := middleware.GetReqID(ctx); id != "" {
+		req = req.Clone(ctx)
+		req.Header.Set(middleware.RequestIDHeader, id)
+	}
+
+	debugEnabled := t.log.Enabled(ctx, slog.LevelDebug)
+	attrs := []any{"method", req.Method, "url", req.URL.String()}
+	logBodies := debugEnabled && t.logBodies.Load()
+	if logBodies && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			raw, _ := io.ReadAll(body)
+			body.Close()
+			if len(raw) > 0 {
+				attrs = append(attrs, "requestBody", redactJSON(raw))
+			}
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	attrs = append(attrs, "durationMs", time.Since(start).Milliseconds())
+	if err != nil {
+		t.log.WarnContext(ctx, "sdk request failed", append(attrs, "error", err.Error())...)
+		return nil, err
+	}
+
+	attrs = append(attrs, "status", resp.StatusCode)
+
+	// The SDK cancels the request context before reading the body, which
+	// races with the read. Buffer the body while the context is still live.
+	raw, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if readErr != nil {
+		t.log.WarnContext(ctx, "sdk request failed", append(attrs, "error", readErr.Error())...)
+		return nil, readErr
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(raw))
+	if logBodies && len(raw) > 0 {
+		attrs = append(attrs, "responseBody", redactJSON(raw))
+	}
+	t.log.DebugContext(ctx, "sdk request", attrs...)
+	return resp, nil
+}
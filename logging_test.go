@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{`{"userId":"u1","receipt":"MIIT"}`, `{"receipt":"[REDACTED]","userId":"u1"}`},
+		{`{"payments":[{"Receipt":"a"},{"amount":1}]}`, `{"payments":[{"Receipt":"[REDACTED]"},{"amount":1}]}`},
+		{`{"data":{"token":"ott","nested":{"apiKey":"k"}}}`, `{"data":{"nested":{"apiKey":"[REDACTED]"},"token":"[REDACTED]"}}`},
+		{`[1,"secret"]`, `[1,"secret"]`},
+		{`receipt=MIIT`, `"[non-JSON body]"`},
+	}
+	for _, tc := range tests {
+		if got := string(redactJSON([]byte(tc.body))); got != tc.want {
+			t.Errorf("redactJSON(%s) = %s, want %s", tc.body, got, tc.want)
+		}
+	}
+}
+
+// logLines decodes the JSON lines written to buf.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(line), &m); err != nil {
+			t.Fatalf("log line %q: %v", line, err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestLogRegistry(t *testing.T) {
+	var buf bytes.Buffer
+	logs := newLogRegistry(&buf)
+	if err := logs.setLevels("warn,webhook=debug"); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-1")
+
+	logs.logger(logApp).InfoContext(ctx, "dropped")
+	logs.logger(logWebhook).DebugContext(ctx, "kept", "signature", "sha256=abc", "userId", "u1")
+	logs.logger(logApp).Warn("also kept", "apiKey", "key:secret")
+
+	lines := logLines(t, &buf)
+	if len(lines) != 2 {
+		t.Fatalf("lines = %v", lines)
+	}
+	if l := lines[0]; l["subsystem"] != logWebhook || l["requestId"] != "req-1" || l["signature"] != redactedValue || l["userId"] != "u1" {
+		t.Errorf("webhook line = %v", l)
+	}
+	if l := lines[1]; l["apiKey"] != redactedValue {
+		t.Errorf("app line = %v", l)
+	}
+
+	// Reloading the spec changes loggers already handed out.
+	if err := logs.setLevels("info"); err != nil {
+		t.Fatal(err)
+	}
+	if logs.logger(logWebhook).Enabled(ctx, slog.LevelDebug) || !logs.logger(logApp).Enabled(ctx, slog.LevelInfo) {
+		t.Error("levels not updated after setLevels")
+	}
+}
+
+func TestParseLevelSpec(t *testing.T) {
+	tests := []struct {
+		spec      string
+		fallback  slog.Level
+		overrides map[string]slog.Level
+		wantErr   bool
+	}{
+		{"", slog.LevelInfo, map[string]slog.Level{}, false},
+		{"debug", slog.LevelDebug, map[string]slog.Level{}, false},
+		{"info, sdk=warn ,webhook=debug", slog.LevelInfo, map[string]slog.Level{"sdk": slog.LevelWarn, "webhook": slog.LevelDebug}, false},
+		{"sdk=loud", 0, nil, true},
+	}
+	for _, tc := range tests {
+		fallback, overrides, err := parseLevelSpec(tc.spec)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%q: err = %v", tc.spec, err)
+			continue
+		}
+		if tc.wantErr {
+			continue
+		}
+		if fallback != tc.fallback || len(overrides) != len(tc.overrides) {
+			t.Errorf("%q = %v %v, want %v %v", tc.spec, fallback, overrides, tc.fallback, tc.overrides)
+		}
+		for k, v := range tc.overrides {
+			if overrides[k] != v {
+				t.Errorf("%q: %s = %v, want %v", tc.spec, k, overrides[k], v)
+			}
+		}
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestSDKTransportRedactsBodies(t *testing.T) {
+	var buf bytes.Buffer
+	logs := newLogRegistry(&buf)
+	logs.setDefaultLevel(logSDK, slog.LevelDebug)
+
+	var forwardedID string
+	transport := &sdkTransport{log: logs.logger(logSDK), next: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		forwardedID = r.Header.Get(middleware.RequestIDHeader)
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(`{"data":{"token":"ott-123"}}`))}, nil
+	})}
+	transport.logBodies.Store(true)
+
+	ctx := context.WithValue(context.Background(), middleware.RequestIDKey, "req-9")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.example.com/v1/server/payments", strings.NewReader(`{"receipt":"MIIT","amount":1}`))
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := io.ReadAll(resp.Body); !strings.Contains(string(body), "ott-123") {
+		t.Errorf("response body altered: %s", body)
+	}
+	if forwardedID != "req-9" {
+		t.Errorf("forwarded request ID = %q", forwardedID)
+	}
+	if out := buf.String(); strings.Contains(out, "MIIT") || strings.Contains(out, "ott-123") || !strings.Contains(out, `"requestId":"req-9"`) {
+		t.Errorf("log = %s", out)
+	}
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/joho/godotenv"
 	playcamp "github.com/playcamp/playcamp-go-sdk"
 )
@@ -17,12 +22,43 @@ import (
 type app struct {
 	server           *playcamp.Server
 	testServer       *playcamp.Server
-	webhookSecret    string
 	receivedWebhooks *webhookStore
+	captures         *captureStore
+	sponsorState     *sponsorView
+	revenue          *revenueLedger
+	quarantine       *quarantineStore
+	webhookLog       *slog.Logger
+	health           *healthChecker
+	lifecycle        *lifecycle
+
+	// cfg is the running configuration. A SIGHUP reload swaps it for one
+	// with the same structural settings.
+	cfg          atomic.Pointer[config]
+	fx           atomic.Pointer[fxRates]
+	receipts     atomic.Pointer[receiptVerifiers]
+	configFile   string
+	logs         *logRegistry
+	sdkTransport *sdkTransport
+	limiter      *rateLimiter
+	couponGuard  *couponGuard
+	registration webhookRegistration
+	routes       *routeTable
+	router       chi.Router
+	openAPI      openAPICache
+	startedAt    time.Time
 }
 
-// getSDK returns the appropriate SDK instance based on test mode.
-func (a *app) getSDK(isTest bool) *playcamp.Server {
+// config returns the running configuration.
+func (a *app) config() *config {
+	return a.cfg.Load()
+}
+
+// getSDK returns the appropriate SDK instance based on test mode and records
+// the choice so error responses can report it.
+func (a *app) getSDK(r *http.Request, isTest bool) *playcamp.Server {
+	if st := stateFromRequest(r); st != nil {
+		st.isTest = isTest
+	}
 	if isTest {
 		return a.testServer
 	}
@@ -30,211 +66,286 @@ func (a *app) getSDK(isTest bool) *playcamp.Server {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "routes":
+			os.Exit(runRoutesCommand(os.Args[2:]))
+		case "replay":
+			os.Exit(runReplayCommand(os.Args[2:]))
+		}
+	}
+
 	// Load .env file (ignore error if not present).
 	_ = godotenv.Load()
 
-	// Read required config.
-	apiKey := os.Getenv("SERVER_API_KEY")
-	if apiKey == "" {
-		log.Fatal("SERVER_API_KEY environment variable is required")
+	if len(os.Args) > 1 && isCLICommand(os.Args[1]) {
+		os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
+	logs := newLogRegistry(os.Stdout)
+	appLog := logs.logger(logApp)
+	slog.SetDefault(appLog)
+
+	// Load config.yaml (or CONFIG_FILE), then apply environment overrides.
+	configFile := configPath()
+	cfg, err := loadConfig(configFile)
+	if err != nil {
+		fatal(appLog, "invalid configuration", err)
+	}
+
+	a, err := newApp(cfg, configFile, logs)
+	if err != nil {
+		fatal(appLog, "failed to start server", err)
 	}
+	a.watchReloadSignal()
+	a.lifecycle.goBackground("rate-limit-sweep", a.limiter.runSweeper)
+	a.lifecycle.goBackground("coupon-guard-sweep", a.runCouponGuardSweeper)
+	a.lifecycle.goBackground("sponsor-reconcile", a.runSponsorReconciler)
+	a.lifecycle.goBackground("revenue-resolve", a.runRevenueResolver)
+	a.lifecycle.onShutdown("in-memory-state", a.logDiscardedState)
+	a.startReceiverRegistration()
+
+	appLog.Info("effective configuration", "file", configFile, "config", cfg.masked())
 
-	webhookSecret := os.Getenv("WEBHOOK_SECRET")
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "4000"
+	// Print startup banner.
+	effectiveAPIURL := cfg.effectiveAPIURL()
+	envInfo := fmt.Sprintf("Environment: %s", cfg.SDK.Environment)
+	if cfg.SDK.APIURL != "" {
+		envInfo = fmt.Sprintf("Custom: %s", effectiveAPIURL)
+	}
+	if cfg.SDK.Environment == "" {
+		envInfo = "Environment: live"
+	}
+
+	debugStatus := "Debug: OFF"
+	if cfg.SDK.Debug {
+		debugStatus = "Debug: ON"
 	}
 
+	scheme := "http"
+	if cfg.TLS.enabled() {
+		scheme = "https"
+	}
+
+	fmt.Printf(`
+╔═══════════════════════════════════════════════════╗
+║     PlayCamp SDK Example Server (Go)              ║
+╠═══════════════════════════════════════════════════╣
+║  Server: %s://localhost:%s
+║  SDK API: %s
+║  %s
+║  %s
+╚═══════════════════════════════════════════════════╝
+
+`, scheme, cfg.Port, effectiveAPIURL, envInfo, debugStatus)
+	fmt.Print(a.routeBanner())
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           a.router,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(logs.logger(logHTTP).Handler(), slog.LevelWarn),
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	if cfg.TLS.enabled() {
+		certs, err := newCertReloader(cfg.TLS, appLog)
+		if err != nil {
+			fatal(appLog, "failed to load TLS certificate", err)
+		}
+		srv.TLSConfig = certs.serverConfig()
+		a.health.add("tls", 0, certs.readiness)
+		a.lifecycle.goBackground("tls-reload", func(ctx context.Context) {
+			certs.watch(ctx, cfg.TLS.ReloadInterval)
+		})
+		go func() { serveErr <- srv.ListenAndServeTLS("", "") }()
+	} else {
+		go func() { serveErr <- srv.ListenAndServe() }()
+	}
+
+	select {
+	case err := <-serveErr:
+		fatal(appLog, "server stopped", err)
+	case <-ctx.Done():
+	}
+	// A second signal terminates immediately.
+	stop()
+
+	a.shutdown(srv, cfg.Server.ShutdownDrainDelay, cfg.Server.ShutdownTimeout)
+}
+
+// newApp creates the SDK instances and the app for cfg, with its router
+// built and readiness checks registered. Background work is started by main.
+func newApp(cfg *config, configFile string, logs *logRegistry) (*app, error) {
+	appLog := logs.logger(logApp)
+
 	// Build SDK options.
 	var opts []playcamp.Option
 
-	env := os.Getenv("SDK_ENVIRONMENT")
-	if env != "" {
-		opts = append(opts, playcamp.WithEnvironment(playcamp.Environment(env)))
+	if cfg.SDK.Environment != "" {
+		opts = append(opts, playcamp.WithEnvironment(playcamp.Environment(cfg.SDK.Environment)))
 	}
 
-	if baseURL := os.Getenv("SDK_API_URL"); baseURL != "" {
-		opts = append(opts, playcamp.WithBaseURL(baseURL))
+	if cfg.SDK.APIURL != "" {
+		opts = append(opts, playcamp.WithBaseURL(cfg.SDK.APIURL))
 	}
 
-	if strings.EqualFold(os.Getenv("SDK_DEBUG"), "true") {
-		opts = append(opts, playcamp.WithDebug(playcamp.DebugOptions{
-			Enabled:         true,
-			LogRequestBody:  true,
-			LogResponseBody: true,
-		}))
+	// SDK traffic is logged by our own transport so that request IDs are
+	// attached and receipts, tokens and keys are redacted.
+	transport := &sdkTransport{next: http.DefaultTransport, log: logs.logger(logSDK)}
+	if cfg.SDK.RecordCassette != "" {
+		transport.next = newCassetteRecorder(http.DefaultTransport, cfg.SDK.RecordCassette, logs.logger(logSDK))
+		appLog.Warn("recording SDK traffic", "cassette", cfg.SDK.RecordCassette)
 	}
+	opts = append(opts, playcamp.WithHTTPClient(&http.Client{Transport: transport}))
 
 	// Create normal SDK instance.
-	server, err := playcamp.NewServer(apiKey, opts...)
+	server, err := playcamp.NewServer(cfg.APIKey, opts...)
 	if err != nil {
-		log.Fatalf("Failed to create SDK server: %v", err)
+		return nil, fmt.Errorf("create SDK server: %w", err)
 	}
 
 	// Create test-mode SDK instance.
 	testOpts := append([]playcamp.Option{playcamp.WithTestMode(true)}, opts...)
-	testServer, err := playcamp.NewServer(apiKey, testOpts...)
+	testServer, err := playcamp.NewServer(cfg.APIKey, testOpts...)
 	if err != nil {
-		log.Fatalf("Failed to create test SDK server: %v", err)
+		return nil, fmt.Errorf("create test-mode SDK server: %w", err)
+	}
+
+	rates, err := loadFXRates(cfg.Currency.RatesFile)
+	if err != nil {
+		return nil, fmt.Errorf("load FX rates: %w", err)
+	}
+	verifiers, err := newReceiptVerifiers(cfg.Receipts, receiptHTTPClient)
+	if err != nil {
+		return nil, fmt.Errorf("load receipt verifiers: %w", err)
+	}
+	revenue := newRevenueLedger()
+	if err := revenue.loadState(cfg.Revenue.StateFile); err != nil {
+		return nil, fmt.Errorf("load revenue state: %w", err)
 	}
 
 	a := &app{
 		server:           server,
 		testServer:       testServer,
-		webhookSecret:    webhookSecret,
-		receivedWebhooks: newWebhookStore(50),
-	}
-
-	// Router setup.
-	r := chi.NewRouter()
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(corsMiddleware)
-
-	// --- Campaigns ---
-	r.Get("/api/campaigns", a.handleListCampaigns)
-	r.Get("/api/campaigns/{id}", a.handleGetCampaign)
-	r.Get("/api/campaigns/{id}/creators", a.handleGetCampaignCreators)
-
-	// --- Creators (literal path before parameterized) ---
-	r.Get("/api/creators/search", a.handleSearchCreators)
-	r.Get("/api/creators/{key}", a.handleGetCreator)
-	r.Get("/api/creators/{key}/coupons", a.handleGetCreatorCoupons)
-
-	// --- Coupons ---
-	r.Post("/api/coupons/validate", a.handleValidateCoupon)
-	r.Post("/api/coupons/redeem", a.handleRedeemCoupon)
-	r.Get("/api/coupons/user/{userId}", a.handleGetCouponHistory)
-
-	// --- Sponsors ---
-	r.Post("/api/sponsors", a.handleCreateSponsor)
-	r.Get("/api/sponsors/{userId}", a.handleGetSponsor)
-	r.Put("/api/sponsors/{userId}", a.handleUpdateSponsor)
-	r.Delete("/api/sponsors/{userId}", a.handleDeleteSponsor)
-	r.Get("/api/sponsors/{userId}/history", a.handleGetSponsorHistory)
-
-	// --- Payments (literal path before parameterized) ---
-	r.Post("/api/payments", a.handleCreatePayment)
-	r.Post("/api/payments/bulk", a.handleCreateBulkPayment)
-	r.Get("/api/payments/user/{userId}", a.handleGetUserPayments)
-	r.Get("/api/payments/{transactionId}", a.handleGetPayment)
-	r.Post("/api/payments/{transactionId}/refund", a.handleRefundPayment)
-
-	// --- Webhooks (literal paths before parameterized) ---
-	r.Get("/api/webhooks", a.handleListWebhooks)
-	r.Post("/api/webhooks", a.handleCreateWebhook)
-	r.Get("/api/webhooks/received", a.handleGetReceivedWebhooks)
-	r.Delete("/api/webhooks/received", a.handleClearReceivedWebhooks)
-	r.Post("/api/webhooks/simulate", a.handleSimulateWebhook)
-	r.Get("/api/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
-		// Not a standard endpoint, but route exists for completeness.
-		writeError(w, http.StatusNotFound, "use /api/webhooks/:id/logs or /api/webhooks/:id/test")
-	})
-	r.Put("/api/webhooks/{id}", a.handleUpdateWebhook)
-	r.Delete("/api/webhooks/{id}", a.handleDeleteWebhook)
-	r.Get("/api/webhooks/{id}/logs", a.handleGetWebhookLogs)
-	r.Post("/api/webhooks/{id}/test", a.handleTestWebhook)
-
-	// --- WebView ---
-	r.Post("/webview/token", a.handleWebviewToken)
-
-	// --- Webhook Receiver ---
-	r.Post("/webhooks/playcamp", a.handleWebhookReceiver)
-
-	// --- Static files ---
-	fileServer := http.FileServer(http.Dir("public"))
-	r.Handle("/*", fileServer)
+		receivedWebhooks: newWebhookStore(cfg.Webhook.StoreSize),
+		captures:         newCaptureStore(cfg.Webhook.StoreSize),
+		sponsorState:     newSponsorView(cfg.SponsorState.MaxUsers),
+		revenue:          revenue,
+		quarantine:       newQuarantineStore(cfg.Receipts.QuarantineSize),
+		webhookLog:       logs.logger(logWebhook),
+		health:           newHealthChecker(),
+		lifecycle:        newLifecycle(appLog),
+		configFile:       configFile,
+		logs:             logs,
+		sdkTransport:     transport,
+		limiter:          newRateLimiter(),
+		couponGuard:      newCouponGuard(appLog),
+		routes:           newRouteTable(),
+		startedAt:        time.Now(),
+	}
+	a.fx.Store(rates)
+	a.receipts.Store(verifiers)
+	a.applyConfig(cfg)
+	a.registerReadinessChecks()
+	a.router = a.newRouter()
+	return a, nil
+}
 
-	// Print startup banner.
-	effectiveAPIURL := os.Getenv("SDK_API_URL")
-	if effectiveAPIURL == "" {
-		effectiveAPIURL = playcamp.EnvironmentURL(playcamp.Environment(env))
-		if effectiveAPIURL == "" {
-			effectiveAPIURL = playcamp.EnvironmentURL(playcamp.EnvironmentLive)
-		}
+// shutdown drains the server: readiness fails first so load balancers stop
+// routing here, then the listener closes and in-flight handlers finish, and
+// finally background workers stop and the shutdown hooks run. Everything
+// after the drain delay shares one deadline.
+func (a *app) shutdown(srv *http.Server, drainDelay, timeout time.Duration) {
+	log := a.lifecycle.log
+
+	a.lifecycle.beginDrain()
+	if drainDelay > 0 {
+		log.Info("draining before shutdown", "delay", drainDelay.String())
+		time.Sleep(drainDelay)
 	}
 
-	envInfo := fmt.Sprintf("Environment: %s", env)
-	if os.Getenv("SDK_API_URL") != "" {
-		envInfo = fmt.Sprintf("Custom: %s", effectiveAPIURL)
+	log.Info("shutting down", "timeout", timeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Error("http server did not drain", "error", err.Error())
 	}
-	if env == "" {
-		envInfo = "Environment: live"
+	if err := a.lifecycle.shutdown(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		log.Error("background work did not drain", "error", err.Error())
 	}
+	log.Info("shutdown complete")
+}
 
-	debugStatus := "Debug: OFF"
-	if strings.EqualFold(os.Getenv("SDK_DEBUG"), "true") {
-		debugStatus = "Debug: ON"
+// logDiscardedState logs the work that only lives in memory and is lost on
+// exit: quarantined payments, enough of each to follow it up but never its
+// receipt, and revenue transactions still waiting to be looked up.
+func (a *app) logDiscardedState(context.Context) error {
+	log := a.lifecycle.log
+	for _, isTest := range []bool{false, true} {
+		for _, q := range a.quarantine.list(isTest) {
+			log.Warn("discarding quarantined payment", "transactionId", q.TransactionID, "userId", q.Payment.UserID,
+				"amount", q.Payment.Amount, "currency", q.Payment.Currency, "isTest", q.IsTest, "reason", q.Reason)
+		}
+	}
+	if n := a.revenue.backlog(); n > 0 {
+		log.Warn("discarding unresolved revenue transactions", "count", n)
 	}
+	return nil
+}
 
-	fmt.Printf(`
-╔═══════════════════════════════════════════════════╗
-║     PlayCamp SDK Example Server (Go)              ║
-╠═══════════════════════════════════════════════════╣
-║  Server: http://localhost:%s
-║  SDK API: %s
-║  %s
-║  %s
-╚═══════════════════════════════════════════════════╝
+// watchReloadSignal reloads the configuration on SIGHUP.
+func (a *app) watchReloadSignal() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	a.lifecycle.goBackground("config-reload", func(ctx context.Context) {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				a.reloadConfig()
+			}
+		}
+	})
+}
 
-API Endpoints:
-
-[Campaigns]
-   GET  /api/campaigns              - List campaigns
-   GET  /api/campaigns/:id          - Get campaign
-   GET  /api/campaigns/:id/creators - Get campaign creators
-
-[Creators]
-   GET  /api/creators/search        - Search creators
-   GET  /api/creators/:key          - Get creator
-   GET  /api/creators/:key/coupons  - Get creator coupons
-
-[Coupons]
-   POST /api/coupons/validate       - Validate coupon
-   POST /api/coupons/redeem         - Redeem coupon
-   GET  /api/coupons/user/:userId   - Get user coupon history
-
-[Sponsors]
-   GET  /api/sponsors/:userId          - Get sponsor
-   POST /api/sponsors                  - Create sponsor
-   PUT  /api/sponsors/:userId          - Update sponsor
-   DELETE /api/sponsors/:userId        - Delete sponsor
-   GET  /api/sponsors/:userId/history  - Get sponsor history
-
-[Payments]
-   POST /api/payments                    - Create payment
-   POST /api/payments/bulk               - Create bulk payments
-   GET  /api/payments/:txnId             - Get payment
-   GET  /api/payments/user/:userId       - Get user payments
-   POST /api/payments/:txnId/refund      - Refund payment
-
-[Webhooks]
-   GET  /api/webhooks             - List webhooks
-   POST /api/webhooks             - Create webhook
-   PUT  /api/webhooks/:id         - Update webhook
-   DELETE /api/webhooks/:id       - Delete webhook
-   GET  /api/webhooks/:id/logs    - Get webhook logs
-   POST /api/webhooks/:id/test    - Test webhook
-
-[WebView]
-   POST /webview/token             - Create WebView OTT token
-
-[Webhook Receiver]
-   POST /webhooks/playcamp        - Receive webhooks
-   GET  /api/webhooks/received    - Get received webhooks
-   DELETE /api/webhooks/received  - Clear received webhooks
-   POST /api/webhooks/simulate    - Simulate webhook
-`, port, effectiveAPIURL, envInfo, debugStatus)
-
-	log.Fatal(http.ListenAndServe(":"+port, r))
+// fatal logs msg at error level and exits.
+func fatal(log *slog.Logger, msg string, err error) {
+	var cfgErrs configErrors
+	switch {
+	case errors.As(err, &cfgErrs):
+		log.Error(msg, "errors", []string(cfgErrs))
+	case err != nil:
+		log.Error(msg, "error", err.Error())
+	default:
+		log.Error(msg)
+	}
+	os.Exit(1)
 }
 
 // corsMiddleware adds CORS headers for the Web UI.
-func corsMiddleware(next http.Handler) http.Handler {
+func (a *app) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		cors := a.config().CORS
+		if origin := allowedOrigin(cors.AllowedOrigins, r.Header.Get("Origin")); origin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			if origin != "*" {
+				w.Header().Add("Vary", "Origin")
+			}
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-Id")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -244,3 +355,17 @@ func corsMiddleware(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for origin, or
+// "" if it is not allowed.
+func allowedOrigin(allowed []string, origin string) string {
+	for _, o := range allowed {
+		if o == "*" {
+			return "*"
+		}
+		if origin != "" && strings.EqualFold(o, origin) {
+			return origin
+		}
+	}
+	return ""
+}
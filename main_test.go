@@ -0,0 +1,241 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// The handler tests run the real router and SDK against fakePlayCamp, an
+// httptest server that the SDK reaches through SDK_API_URL.
+
+const (
+	testAPIKey        = "ak_test_key:test_secret"
+	testWebhookSecret = "whsec_test_secret"
+)
+
+// fakeRoute is a canned PlayCamp response.
+type fakeRoute struct {
+	status int
+	body   string
+}
+
+// fakeRequest is a request the fake received.
+type fakeRequest struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// fakePlayCamp serves canned responses keyed by "METHOD /path" and records
+// every request. Unknown routes answer 404 like the real API.
+type fakePlayCamp struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	routes   map[string]fakeRoute
+	requests []fakeRequest
+}
+
+func newFakePlayCamp(t *testing.T) *fakePlayCamp {
+	t.Helper()
+	f := &fakePlayCamp{routes: map[string]fakeRoute{}}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakePlayCamp) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	f.mu.Lock()
+	f.requests = append(f.requests, fakeRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	route, ok := f.routes[r.Method+" "+r.URL.Path]
+	f.mu.Unlock()
+
+	if !ok {
+		route = fakeRoute{http.StatusNotFound, `{"code":"NOT_FOUND","message":"no fake for ` + r.Method + " " + r.URL.Path + `"}`}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(route.status)
+	io.WriteString(w, route.body)
+}
+
+// on sets the response for method and path.
+func (f *fakePlayCamp) on(method, path string, status int, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.routes[method+" "+path] = fakeRoute{status, body}
+}
+
+// received returns the requests recorded so far.
+func (f *fakePlayCamp) received() []fakeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeRequest(nil), f.requests...)
+}
+
+// newTestApp builds the app the way main does, configured through the
+// environment to talk to fake. Rate limiting is off so cases don't interact.
+func newTestApp(t *testing.T, fake *fakePlayCamp, env map[string]string) *app {
+	t.Helper()
+	vars := map[string]string{
+		"SERVER_API_KEY":     testAPIKey,
+		"SDK_API_URL":        fake.URL,
+		"WEBHOOK_SECRET":     testWebhookSecret,
+		"RATE_LIMIT_ENABLED": "false",
+	}
+	for k, v := range env {
+		vars[k] = v
+	}
+
+	cfg := defaultConfig()
+	err := cfg.applyEnv(func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	})
+	if err == nil {
+		err = cfg.validate()
+	}
+	if err != nil {
+		t.Fatalf("config: %v", err)
+	}
+
+	a, err := newApp(cfg, "", newLogRegistry(io.Discard))
+	if err != nil {
+		t.Fatalf("newApp: %v", err)
+	}
+	return a
+}
+
+// serve sends a request through the app's router.
+func serve(a *app, method, target, body string) *httptest.ResponseRecorder {
+	var rd io.Reader
+	if body != "" {
+		rd = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, rd)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	rec := httptest.NewRecorder()
+	a.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeData decodes the {"data": ...} envelope of rec into v.
+func decodeData(t *testing.T, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	var env struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+		t.Fatalf("decode envelope: %v: %s", err, rec.Body)
+	}
+	if err := json.Unmarshal(env.Data, v); err != nil {
+		t.Fatalf("decode data: %v: %s", err, env.Data)
+	}
+}
+
+// decodeProblem decodes a problem+json response.
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) problem {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Fatalf("Content-Type = %q, want application/problem+json; body %s", ct, rec.Body)
+	}
+	var p problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatalf("decode problem: %v: %s", err, rec.Body)
+	}
+	return p
+}
+
+// handlerCase is one request against the app and the PlayCamp call it
+// should make.
+type handlerCase struct {
+	name   string
+	method string
+	target string
+	body   string
+
+	// upstream is the PlayCamp request the handler should make, as
+	// "METHOD /path", or "" if it should make none.
+	upstream string
+	status   int    // fake response status; 0 means 200
+	response string // fake response body
+
+	wantStatus int
+	// wantProblem is the problem type suffix expected on errors.
+	wantProblem string
+	// check inspects the response and the request PlayCamp received.
+	check func(t *testing.T, rec *httptest.ResponseRecorder, got fakeRequest)
+}
+
+func runHandlerCases(t *testing.T, cases []handlerCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newFakePlayCamp(t)
+			a := newTestApp(t, fake, nil)
+			if tc.upstream != "" {
+				method, path, _ := strings.Cut(tc.upstream, " ")
+				status := tc.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				fake.on(method, path, status, tc.response)
+			}
+
+			rec := serve(a, tc.method, tc.target, tc.body)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tc.wantStatus, rec.Body)
+			}
+
+			reqs := fake.received()
+			var got fakeRequest
+			switch {
+			case tc.upstream == "" && len(reqs) > 0:
+				t.Fatalf("unexpected PlayCamp call %s %s", reqs[0].Method, reqs[0].Path)
+			case tc.upstream != "" && len(reqs) != 1:
+				t.Fatalf("PlayCamp calls = %d, want 1 (%s)", len(reqs), tc.upstream)
+			case tc.upstream != "":
+				got = reqs[0]
+				if g := got.Method + " " + got.Path; g != tc.upstream {
+					t.Fatalf("PlayCamp call = %s, want %s", g, tc.upstream)
+				}
+			}
+
+			if tc.wantProblem != "" {
+				if p := decodeProblem(t, rec); p.Type != problemTypePrefix+tc.wantProblem {
+					t.Errorf("problem type = %q, want %q", p.Type, problemTypePrefix+tc.wantProblem)
+				}
+			}
+			if tc.check != nil {
+				tc.check(t, rec, got)
+			}
+		})
+	}
+}
+
+// jsonBody decodes a recorded request body.
+func jsonBody(t *testing.T, got fakeRequest) map[string]any {
+	t.Helper()
+	var m map[string]any
+	if err := json.Unmarshal(got.Body, &m); err != nil {
+		t.Fatalf("decode upstream body: %v: %s", err, got.Body)
+	}
+	return m
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/go-chi/chi/v5"
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// The OpenAPI document is generated from the live chi router: every mounted
+// route is described by the routeDoc attached when it was registered, and
+// request and response schemas are reflected from the DTOs and SDK types.
+
+//go:embed redoc.html
+var redocPage []byte
+
+// webhookEventData maps each webhook event to the type of its data payload.
+var webhookEventData = []struct {
+	Event   playcamp.WebhookEventType
+	Summary string
+	Data    any
+}{
+	{playcamp.WebhookEventCouponRedeemed, "A coupon was redeemed", playcamp.CouponRedeemedData{}},
+	{playcamp.WebhookEventPaymentCreated, "A payment was recorded", playcamp.PaymentCreatedData{}},
+	{playcamp.WebhookEventPaymentRefunded, "A payment was refunded", playcamp.PaymentRefundedData{}},
+	{playcamp.WebhookEventPaymentBulkCreated, "A bulk payment request finished", playcamp.PaymentBulkCreatedData{}},
+	{playcamp.WebhookEventSponsorCreated, "A user started sponsoring a creator", playcamp.SponsorCreatedData{}},
+	{playcamp.WebhookEventSponsorChanged, "A user switched the creator they sponsor", playcamp.SponsorChangedData{}},
+	{playcamp.WebhookEventSponsorEnded, "A user stopped sponsoring a creator", playcamp.SponsorEndedData{}},
+}
+
+// typeEnums ties named string types to an enums entry, so every field of
+// that type gets the enum in its schema.
+var typeEnums = map[reflect.Type]string{
+	reflect.TypeOf(playcamp.WebhookEventType("")): "webhookEventType",
+	reflect.TypeOf(playcamp.PaymentPlatform("")):  "paymentPlatform",
+	reflect.TypeOf(playcamp.DistributionType("")): "distributionType",
+}
+
+// schemaNames overrides component names that would otherwise collide.
+var schemaNames = map[reflect.Type]string{
+	reflect.TypeOf(webhookEvent{}): "ReceivedWebhookEvent",
+}
+
+// jsonSchema is the subset of JSON Schema 2020-12 the generator emits.
+type jsonSchema struct {
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 any                    `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Const                string                 `json:"const,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	ExclusiveMinimum     *float64               `json:"exclusiveMinimum,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	AdditionalProperties *jsonSchema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*jsonSchema          `json:"anyOf,omitempty"`
+}
+
+// schemaRegistry collects named component schemas while types are reflected.
+type schemaRegistry struct {
+	schemas map[string]*jsonSchema
+	types   map[reflect.Type]string
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{schemas: map[string]*jsonSchema{}, types: map[reflect.Type]string{}}
+}
+
+// schemaFor returns the schema of t, registering named structs as components
+// and returning a $ref to them.
+func (reg *schemaRegistry) schemaFor(t reflect.Type) *jsonSchema {
+	if t == reflect.TypeOf(json.RawMessage(nil)) {
+		return &jsonSchema{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return reg.schemaFor(t.Elem())
+	case reflect.Interface:
+		return &jsonSchema{}
+	case reflect.String:
+		s := &jsonSchema{Type: "string"}
+		if name, ok := typeEnums[t]; ok {
+			s.Enum = enums[name]
+		}
+		return s
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: reg.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: reg.schemaFor(t.Elem())}
+	case reflect.Struct:
+		name := reg.componentName(t)
+		if _, ok := reg.types[t]; !ok {
+			reg.types[t] = name
+			// Register before recursing so self-referencing types terminate.
+			reg.schemas[name] = &jsonSchema{}
+			*reg.schemas[name] = *reg.structSchema(t)
+		}
+		return &jsonSchema{Ref: "#/components/schemas/" + name}
+	}
+	return &jsonSchema{}
+}
+
+// componentName turns a Go type name into a component name, e.g.
+// PageResult[github.com/.../playcamp.Campaign] becomes PageResultCampaign.
+func (reg *schemaRegistry) componentName(t reflect.Type) string {
+	if name, ok := schemaNames[t]; ok {
+		return name
+	}
+	name := t.Name()
+	if base, args, ok := strings.Cut(name, "["); ok {
+		name = base
+		for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+			name += arg[strings.LastIndex(arg, ".")+1:]
+		}
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// structSchema describes the JSON object form of struct type t.
+//
+// Request DTOs (structs with validate tags) mark the fields with a required
+// rule as required; other structs mark every field that is always encoded.
+func (reg *schemaRegistry) structSchema(t reflect.Type) *jsonSchema {
+	s := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+	isRequest := hasValidateTags(t)
+	reg.addFields(s, t, isRequest)
+	return s
+}
+
+func (reg *schemaRegistry) addFields(s *jsonSchema, t reflect.Type, isRequest bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		_, hasName := f.Tag.Lookup("json")
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && !hasName {
+			// Embedded structs are flattened in JSON.
+			reg.addFields(s, f.Type, isRequest)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := jsonFieldName(f)
+		if name == "-" {
+			continue
+		}
+
+		_, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		omitEmpty := strings.Contains(opts, "omitempty")
+		rules := splitRules(f.Tag.Get("validate"))
+
+		prop := reg.schemaFor(f.Type)
+		applyRules(prop, f.Type, rules)
+		if f.Type.Kind() == reflect.Pointer && !omitEmpty && !isRequest {
+			prop = nullable(prop)
+		}
+		s.Properties[name] = prop
+
+		_, required := rules["required"]
+		if !isRequest {
+			required = !omitEmpty
+		}
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// hasValidateTags reports whether struct t, or a struct embedded in it,
+// declares validation rules.
+func hasValidateTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := f.Tag.Lookup("validate"); ok {
+			return true
+		}
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && hasValidateTags(f.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRules adds the constraints of validate rules to s.
+func applyRules(s *jsonSchema, t reflect.Type, rules map[string]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for name, arg := range rules {
+		switch name {
+		case "max", "min":
+			n, _ := strconv.Atoi(arg)
+			switch {
+			case t.Kind() == reflect.String && name == "max":
+				s.MaxLength = &n
+			case t.Kind() == reflect.String:
+				s.MinLength = &n
+			case name == "max":
+				s.MaxItems = &n
+			default:
+				s.MinItems = &n
+			}
+		case "positive":
+			zero := 0.0
+			s.ExclusiveMinimum = &zero
+		case "gte":
+			n, _ := strconv.ParseFloat(arg, 64)
+			s.Minimum = &n
+		case "currency":
+			s.Pattern = "^[A-Z]{3}$"
+			s.Description = "ISO 4217 currency code"
+		case "enum":
+			s.Enum = enums[arg]
+		case "rfc3339":
+			s.Format = "date-time"
+		case "url":
+			s.Format = "uri"
+		}
+	}
+}
+
+// nullable allows null in addition to s.
+func nullable(s *jsonSchema) *jsonSchema {
+	if typ, ok := s.Type.(string); ok && s.Ref == "" {
+		s.Type = []string{typ, "null"}
+		return s
+	}
+	return &jsonSchema{AnyOf: []*jsonSchema{s, {Type: "null"}}}
+}
+
+// envelope wraps s in the {"data": ...} object every handler responds with.
+func envelope(s *jsonSchema) *jsonSchema {
+	return &jsonSchema{
+		Type:       "object",
+		Properties: map[string]*jsonSchema{"data": s},
+		Required:   []string{"data"},
+	}
+}
+
+// pathParamPattern matches chi URL parameters such as {id} or {id:[0-9]+}.
+var pathParamPattern = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// buildOpenAPI generates the OpenAPI document for router.
+func buildOpenAPI(router chi.Routes, routes *routeTable) (map[string]any, error) {
+	reg := newSchemaRegistry()
+	reg.schemaFor(reflect.TypeOf(problem{}))
+
+	paths := map[string]map[string]any{}
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		doc, ok := routes.lookup(method, route)
+		if !ok {
+			// Catch-alls like the static file server are not part of the API.
+			return nil
+		}
+		path := pathParamPattern.ReplaceAllString(route, "{$1}")
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(method)] = reg.operation(doc, route)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []map[string]string
+	seen := map[string]bool{}
+	for _, key := range routes.order {
+		if tag := routes.docs[key].Tag; !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, map[string]string{"name": tag})
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "PlayCamp SDK Example Server",
+			"version":     buildVersion().Version,
+			"description": "Example server exposing the PlayCamp Go SDK over HTTP. Every successful response wraps its payload in {\"data\": ...}; errors are RFC 9457 problem details.",
+		},
+		"tags":     tags,
+		"paths":    paths,
+		"webhooks": reg.webhooks(),
+		"components": map[string]any{
+			"schemas": reg.schemas,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]string{"type": "http", "scheme": "bearer"},
+				"apiKeyAuth": map[string]string{"type": "apiKey", "in": "header", "name": "X-API-Key"},
+			},
+		},
+	}, nil
+}
+
+// operation describes one route.
+func (reg *schemaRegistry) operation(doc routeDoc, route string) map[string]any {
+	op := map[string]any{
+		"summary":     doc.Summary,
+		"operationId": operationID(doc.Summary),
+		"tags":        []string{doc.Tag},
+	}
+
+	var params []map[string]any
+	for _, m := range pathParamPattern.FindAllStringSubmatch(route, -1) {
+		params = append(params, map[string]any{
+			"name": m[1], "in": "path", "required": true,
+			"schema": &jsonSchema{Type: "string"},
+		})
+	}
+	for _, q := range doc.Query {
+		params = append(params, map[string]any{
+			"name": q.Name, "in": "query", "description": q.Description,
+			"schema": &jsonSchema{Type: q.Type},
+		})
+	}
+	if doc.Header != "" {
+		params = append(params, map[string]any{
+			"name": doc.Header, "in": "header", "required": true,
+			"schema": &jsonSchema{Type: "string"},
+		})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if doc.Request != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content": map[string]any{
+				"application/json": map[string]any{"schema": reg.schemaFor(reflect.TypeOf(doc.Request))},
+			},
+		}
+	}
+
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	if doc.Response != nil {
+		success["content"] = map[string]any{
+			"application/json": map[string]any{"schema": envelope(reg.schemaFor(reflect.TypeOf(doc.Response)))},
+		}
+	}
+	op["responses"] = map[string]any{
+		strconv.Itoa(status): success,
+		"default": map[string]any{
+			"description": "Error",
+			"content": map[string]any{
+				"application/problem+json": map[string]any{"schema": reg.schemaFor(reflect.TypeOf(problem{}))},
+			},
+		},
+	}
+
+	if doc.Secured {
+		op["security"] = []map[string][]string{{"bearerAuth": {}}, {"apiKeyAuth": {}}}
+	}
+	return op
+}
+
+// webhooks describes the deliveries PlayCamp sends, one per event type.
+func (reg *schemaRegistry) webhooks() map[string]any {
+	out := map[string]any{}
+	for _, e := range webhookEventData {
+		name := reg.componentName(reflect.TypeOf(e.Data))
+		event := &jsonSchema{
+			Type: "object",
+			Properties: map[string]*jsonSchema{
+				"event":      {Type: "string", Const: string(e.Event)},
+				"timestamp":  {Type: "string", Format: "date-time"},
+				"callbackId": {Type: "string"},
+				"isTest":     {Type: "boolean"},
+				"data":       reg.schemaFor(reflect.TypeOf(e.Data)),
+			},
+			Required: []string{"event", "timestamp", "data"},
+		}
+		eventName := strings.TrimSuffix(name, "Data") + "Event"
+		reg.schemas[eventName] = event
+
+		out[string(e.Event)] = map[string]any{
+			"post": map[string]any{
+				"summary":     e.Summary,
+				"operationId": operationID(eventName),
+				"tags":        []string{"Webhook Events"},
+				"parameters": []map[string]any{{
+					"name": "X-Webhook-Signature", "in": "header", "required": true,
+					"description": "t=<unix seconds>,v1=<hex HMAC-SHA256 of \"t.body\">",
+					"schema":      &jsonSchema{Type: "string"},
+				}},
+				"requestBody": map[string]any{
+					"required": true,
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": &jsonSchema{
+							Type: "object",
+							Properties: map[string]*jsonSchema{
+								"events": {Type: "array", Items: &jsonSchema{Ref: "#/components/schemas/" + eventName}},
+							},
+							Required: []string{"events"},
+						}},
+					},
+				},
+				"responses": map[string]any{
+					"200": map[string]any{"description": "Any 2xx acknowledges the delivery"},
+				},
+			},
+		}
+	}
+	return out
+}
+
+// operationID turns a summary into a camelCase identifier, e.g.
+// "Get campaign creators" becomes getCampaignCreators.
+func operationID(summary string) string {
+	words := strings.FieldsFunc(summary, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var b strings.Builder
+	for i, w := range words {
+		r := []rune(w)
+		if i == 0 {
+			r[0] = unicode.ToLower(r[0])
+		} else {
+			r[0] = unicode.ToUpper(r[0])
+		}
+		b.WriteString(string(r))
+	}
+	return b.String()
+}
+
+// --- Handlers ---
+
+// openAPICache builds the document on first request; routes never change
+// after startup.
+type openAPICache struct {
+	once sync.Once
+	doc  []byte
+	err  error
+}
+
+// handleOpenAPI handles GET /openapi.json
+func (a *app) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	c := &a.openAPI
+	c.once.Do(func() {
+		var doc map[string]any
+		if doc, c.err = buildOpenAPI(a.router, a.routes); c.err == nil {
+			c.doc, c.err = json.MarshalIndent(doc, "", "  ")
+		}
+	})
+	if c.err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to build OpenAPI document")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(c.doc)
+}
+
+// handleAPIDocs handles GET /docs
+func handleAPIDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(redocPage)
+}
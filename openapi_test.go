@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestOpenAPIDocument(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	rec := serve(a, http.MethodGet, "/openapi.json", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	var doc struct {
+		OpenAPI    string                    `json:"openapi"`
+		Paths      map[string]map[string]any `json:"paths"`
+		Webhooks   map[string]any            `json:"webhooks"`
+		Components struct {
+			Schemas map[string]any `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	if _, ok := doc.Paths["/api/payments/{transactionId}/refund"]["post"]; !ok {
+		t.Error("refund operation missing")
+	}
+	if len(doc.Webhooks) != len(enums["webhookEventType"]) {
+		t.Errorf("webhooks = %d, want one per event type", len(doc.Webhooks))
+	}
+	for _, name := range []string{"CreatePaymentRequest", "Problem", "PageResultCampaign", "CouponRedeemedEvent"} {
+		if _, ok := doc.Components.Schemas[name]; !ok {
+			t.Errorf("schema %s missing", name)
+		}
+	}
+}
+
+// openAPIDocument builds the document for the test app's router and returns
+// it as decoded JSON, the form clients see.
+func openAPIDocument(t *testing.T, a *app) map[string]any {
+	t.Helper()
+	doc, err := buildOpenAPI(a.newRouter(), a.routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(raw, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestOpenAPIOperationIDs(t *testing.T) {
+	doc := openAPIDocument(t, newTestApp(t, newFakePlayCamp(t), nil))
+
+	seen := map[string]string{}
+	for _, section := range []string{"paths", "webhooks"} {
+		for path, item := range doc[section].(map[string]any) {
+			for method, op := range item.(map[string]any) {
+				where := section + " " + strings.ToUpper(method) + " " + path
+				id, _ := op.(map[string]any)["operationId"].(string)
+				if id == "" {
+					t.Errorf("%s has no operationId", where)
+					continue
+				}
+				if prev, dup := seen[id]; dup {
+					t.Errorf("%s and %s share operationId %s", where, prev, id)
+				}
+				seen[id] = where
+			}
+		}
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	doc := openAPIDocument(t, newTestApp(t, newFakePlayCamp(t), nil))
+	schemas := doc["components"].(map[string]any)["schemas"].(map[string]any)
+
+	var refs []string
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				refs = append(refs, ref)
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+
+	if len(refs) == 0 {
+		t.Fatal("document has no $ref")
+	}
+	sort.Strings(refs)
+	for _, ref := range refs {
+		name, ok := strings.CutPrefix(ref, "#/components/schemas/")
+		if !ok {
+			t.Errorf("$ref %s does not point into components.schemas", ref)
+			continue
+		}
+		if _, ok := schemas[name]; !ok {
+			t.Errorf("$ref %s does not resolve", ref)
+		}
+	}
+}
+
+func TestOpenAPICoversRouter(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	paths := openAPIDocument(t, a)["paths"].(map[string]any)
+
+	err := chi.Walk(a.newRouter(), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route == "/*" {
+			return nil
+		}
+		path := pathParamPattern.ReplaceAllString(route, "{$1}")
+		item, _ := paths[path].(map[string]any)
+		if _, ok := item[strings.ToLower(method)]; !ok {
+			t.Errorf("%s %s is missing from the document", method, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// rateLimitSweepInterval is how often idle buckets are dropped.
+const rateLimitSweepInterval = time.Minute
+
+// maxPeekBody bounds how much of a request body is read to find a userId.
+const maxPeekBody = 1 << 20
+
+// priorityRoutes may use the upstream reserve. Losing a payment or refund
+// costs more than a failed lookup.
+var priorityRoutes = map[string]bool{
+	"POST /api/payments":                        true,
+	"POST /api/payments/bulk":                   true,
+	"POST /api/payments/{transactionId}/refund": true,
+}
+
+// tokenBucket refills at rate tokens per second up to burst.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), last: now}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// take removes one token if more than floor would remain. floor lets callers
+// hold back a reserve.
+func (b *tokenBucket) take(now time.Time, floor float64) bool {
+	b.refill(now)
+	if b.tokens-1 < floor {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// wait returns how long until a token above floor is available.
+func (b *tokenBucket) wait(floor float64) time.Duration {
+	missing := floor + 1 - b.tokens
+	if missing <= 0 {
+		return 0
+	}
+	return time.Duration(missing / b.rate * float64(time.Second))
+}
+
+// untilFull returns how long until the bucket is back to burst.
+func (b *tokenBucket) untilFull() time.Duration {
+	return time.Duration((b.burst - b.tokens) / b.rate * float64(time.Second))
+}
+
+// rateLimiter holds per-key buckets and the shared upstream budget.
+type rateLimiter struct {
+	mu       sync.Mutex
+	buckets  map[string]*tokenBucket
+	upstream *tokenBucket
+	now      func() time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: map[string]*tokenBucket{}, now: time.Now}
+}
+
+// reset drops all buckets so changed limits apply from a clean state.
+func (l *rateLimiter) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buckets = map[string]*tokenBucket{}
+	l.upstream = nil
+}
+
+// allow takes a token from the bucket for key, creating it from rule.
+func (l *rateLimiter) allow(key string, rule rateLimitRule) (bool, tokenBucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = newTokenBucket(rule.Rate, rule.Burst, now)
+		l.buckets[key] = b
+	}
+	ok = b.take(now, 0)
+	return ok, *b
+}
+
+// allowUpstream takes a token from the upstream budget. Non-priority calls
+// must leave budget.Reserve tokens behind.
+func (l *rateLimiter) allowUpstream(budget upstreamBudget, priority bool) (bool, tokenBucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if l.upstream == nil {
+		l.upstream = newTokenBucket(budget.Rate, budget.Burst, now)
+	}
+	floor := float64(budget.Reserve)
+	if priority {
+		floor = 0
+	}
+	ok := l.upstream.take(now, floor)
+	return ok, *l.upstream
+}
+
+// sweep drops buckets that have refilled completely; they would be recreated
+// identically on the next request.
+func (l *rateLimiter) sweep() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= b.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// runSweeper sweeps idle buckets until ctx is done.
+func (l *rateLimiter) runSweeper(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.sweep()
+		}
+	}
+}
+
+// rateLimit enforces the per-route limits and the upstream budget. It must
+// run after authenticate so requests can be keyed by client.
+func (a *app) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := a.config().RateLimit
+		if !cfg.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		pattern := chi.RouteContext(r.Context()).RoutePattern()
+		route := r.Method + " " + pattern
+
+		// The default bucket always applies; a route rule is layered on top
+		// and the headers report whichever bucket is closer to empty.
+		ok, b := a.limiter.allow(route+"|"+rateLimitKey(r, cfg.Default.Key), cfg.Default)
+		if ok {
+			if rule, found := cfg.routeRule(route); found {
+				var rb tokenBucket
+				ok, rb = a.limiter.allow(route+"|route|"+rateLimitKey(r, rule.Key), rule)
+				if !ok || rb.tokens < b.tokens {
+					b = rb
+				}
+			}
+		}
+		setRateLimitHeaders(w, b)
+		if !ok {
+			rejectRateLimited(w, r, b.wait(0), "rate limit exceeded for "+route)
+			return
+		}
+
+		// Routes documented as local don't call PlayCamp; a local route that
+		// sometimes does spends the budget itself with spendUpstream.
+		if doc, _ := a.routes.lookup(r.Method, pattern); !doc.Local && !a.spendUpstream(w, r, priorityRoutes[route]) {
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// spendUpstream takes a token from the upstream budget for a request about to
+// call PlayCamp. When none is left it answers 429 and returns false.
+func (a *app) spendUpstream(w http.ResponseWriter, r *http.Request, priority bool) bool {
+	cfg := a.config().RateLimit
+	if !cfg.Enabled || cfg.Upstream.Rate <= 0 {
+		return true
+	}
+	ok, ub := a.limiter.allowUpstream(cfg.Upstream, priority)
+	if ok {
+		return true
+	}
+	floor := float64(cfg.Upstream.Reserve)
+	if priority {
+		floor = 0
+	}
+	rejectRateLimited(w, r, ub.wait(floor), "upstream PlayCamp budget exhausted; remaining capacity is reserved for payments and refunds")
+	return false
+}
+
+// allowBackgroundUpstream takes a token from the upstream budget for work
+// not tied to a request, leaving the reserve for payments and refunds. It
+// always allows when no budget is configured.
+func (a *app) allowBackgroundUpstream() bool {
+	cfg := a.config().RateLimit
+	if !cfg.Enabled || cfg.Upstream.Rate <= 0 {
+		return true
+	}
+	ok, _ := a.limiter.allowUpstream(cfg.Upstream, false)
+	return ok
+}
+
+// setRateLimitHeaders writes the RateLimit-* fields from the IETF
+// httpapi-ratelimit-headers draft.
+func setRateLimitHeaders(w http.ResponseWriter, b tokenBucket) {
+	window := int(math.Ceil(b.burst / b.rate))
+	w.Header().Set("RateLimit-Policy", strconv.Itoa(int(b.burst))+";w="+strconv.Itoa(window))
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(int(b.burst)))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(int(math.Floor(b.tokens))))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(b.untilFull())))
+}
+
+func rejectRateLimited(w http.ResponseWriter, r *http.Request, retryAfter time.Duration, detail string) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(retryAfter))))
+	writeError(w, r, http.StatusTooManyRequests, detail)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// rateLimitKey identifies who a request is counted against. "user" falls
+// back to the client and "client" falls back to the remote IP.
+func rateLimitKey(r *http.Request, by string) string {
+	switch by {
+	case rateLimitByUser:
+		if id := requestUserID(r); id != "" {
+			return "user:" + id
+		}
+		fallthrough
+	case rateLimitByClient:
+		if name := clientFromContext(r.Context()); name != "" {
+			return "client:" + name
+		}
+	}
+	return "ip:" + remoteIP(r)
+}
+
+// requestUserID finds the userId a request acts on: the {userId} URL
+// parameter, the userId query parameter, or a userId field in a JSON body.
+// The body is restored for the handler.
+func requestUserID(r *http.Request) string {
+	if id := chi.URLParam(r, "userId"); id != "" {
+		return id
+	}
+	if id := r.URL.Query().Get("userId"); id != "" {
+		return id
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBody))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(raw), r.Body))
+	if err != nil {
+		return ""
+	}
+	var body struct {
+		UserID string `json:"userId"`
+	}
+	if json.Unmarshal(raw, &body) != nil {
+		return ""
+	}
+	return body.UserID
+}
+
+// remoteIP returns the host part of RemoteAddr.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := newTokenBucket(2, 3, start)
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		floor float64
+		want  bool
+	}{
+		{"first", 0, 0, true},
+		{"second", 0, 0, true},
+		{"reserve held back", 0, 1, false},
+		{"last", 0, 0, true},
+		{"empty", 0, 0, false},
+		{"refilled half a second later", 500 * time.Millisecond, 0, true},
+		{"refill capped at burst", time.Hour, 2, true},
+	}
+	now := start
+	for _, tc := range tests {
+		now = now.Add(tc.after)
+		if got := b.take(now, tc.floor); got != tc.want {
+			t.Errorf("%s: take = %v, want %v (tokens %.2f)", tc.name, got, tc.want, b.tokens)
+		}
+	}
+	if b.tokens != 2 || b.wait(0) != 0 || b.wait(2) != 500*time.Millisecond || b.untilFull() != 500*time.Millisecond {
+		t.Errorf("tokens %.2f, wait %v/%v, untilFull %v", b.tokens, b.wait(0), b.wait(2), b.untilFull())
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		param  string
+		client string
+		by     string
+		want   string
+	}{
+		{"user from path", "/api/sponsors/user/u1", "", "u1", "game", rateLimitByUser, "user:u1"},
+		{"user from query", "/api/sponsors?userId=u2", "", "", "game", rateLimitByUser, "user:u2"},
+		{"user from body", "/api/coupons/validate", `{"userId":"u3"}`, "", "game", rateLimitByUser, "user:u3"},
+		{"user falls back to client", "/api/coupons/validate", `{"couponCode":"X"}`, "", "game", rateLimitByUser, "client:game"},
+		{"client falls back to ip", "/api/campaigns", "", "", "", rateLimitByClient, "ip:192.0.2.1"},
+		{"ip", "/api/campaigns", "", "", "game", rateLimitByIP, "ip:192.0.2.1"},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body))
+		rctx := chi.NewRouteContext()
+		if tc.param != "" {
+			rctx.URLParams.Add("userId", tc.param)
+		}
+		ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+		if tc.client != "" {
+			ctx = context.WithValue(ctx, clientKey{}, tc.client)
+		}
+		req = req.WithContext(ctx)
+		if got := rateLimitKey(req, tc.by); got != tc.want {
+			t.Errorf("%s: key = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodPost, "/v1/server/coupons/validate", http.StatusOK, `{"data":{"valid":true,"couponCode":"NEO-1","creatorKey":"neo"}}`)
+	fake.on(http.MethodGet, "/v1/server/campaigns", http.StatusOK, `{"data":[],"pagination":{"page":1,"limit":20,"total":0,"totalPages":0}}`)
+	a := newTestApp(t, fake, nil)
+	now := time.Unix(1700000000, 0)
+	a.limiter.now = func() time.Time { return now }
+
+	cfg := *a.config()
+	cfg.CouponGuard.Enabled = false
+	cfg.Auth.Clients = []apiClient{{Name: "game", Key: "game-key-0123456789"}, {Name: "admin", Key: "admin-key-0123456789"}}
+	cfg.RateLimit = rateLimitConfig{
+		Enabled: true,
+		Default: rateLimitRule{Rate: 1, Burst: 3, Key: rateLimitByClient},
+		Routes: []rateLimitRoute{
+			{Route: "POST /api/coupons/validate", rateLimitRule: rateLimitRule{Rate: 1, Burst: 2, Key: rateLimitByUser}},
+		},
+		Upstream: upstreamBudget{Rate: 1, Burst: 6, Reserve: 1},
+	}
+	a.cfg.Store(&cfg)
+
+	send := func(key, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		a.router.ServeHTTP(rec, req)
+		return rec
+	}
+	validate := func(userID string) string {
+		return `{"couponCode":"NEO-1","userId":"` + userID + `"}`
+	}
+
+	tests := []struct {
+		name          string
+		key           string
+		method        string
+		target        string
+		body          string
+		wantStatus    int
+		wantRemaining string
+	}{
+		{"user limit", "game-key-0123456789", http.MethodPost, "/api/coupons/validate", validate("u1"), http.StatusOK, "1"},
+		{"user limit drained", "game-key-0123456789", http.MethodPost, "/api/coupons/validate", validate("u1"), http.StatusOK, "0"},
+		{"user limit exceeded", "game-key-0123456789", http.MethodPost, "/api/coupons/validate", validate("u1"), http.StatusTooManyRequests, "0"},
+		// Another userId gets a fresh user bucket but not a fresh client bucket.
+		{"client limit exceeded", "game-key-0123456789", http.MethodPost, "/api/coupons/validate", validate("u2"), http.StatusTooManyRequests, "0"},
+		{"other client", "admin-key-0123456789", http.MethodPost, "/api/coupons/validate", validate("u2"), http.StatusOK, "1"},
+		{"other route", "game-key-0123456789", http.MethodGet, "/api/campaigns", "", http.StatusOK, "2"},
+		{"local route skips upstream", "game-key-0123456789", http.MethodGet, "/api/coupons/lockouts", "", http.StatusOK, "2"},
+		{"upstream reserve held back", "admin-key-0123456789", http.MethodGet, "/api/campaigns", "", http.StatusOK, "2"},
+		{"upstream exhausted", "admin-key-0123456789", http.MethodGet, "/api/campaigns", "", http.StatusTooManyRequests, "1"},
+		{"local report", "admin-key-0123456789", http.MethodGet, "/api/reports/creators", "", http.StatusOK, "2"},
+		{"local capture buckets", "admin-key-0123456789", http.MethodGet, "/api/hooks", "", http.StatusOK, "2"},
+		{"sponsor state seed spends upstream", "admin-key-0123456789", http.MethodGet, "/api/sponsors/u9/state", "", http.StatusTooManyRequests, "2"},
+	}
+	for _, tc := range tests {
+		rec := send(tc.key, tc.method, tc.target, tc.body)
+		if rec.Code != tc.wantStatus || rec.Header().Get("RateLimit-Remaining") != tc.wantRemaining {
+			t.Errorf("%s: status %d, remaining %q; want %d, %q; body %s", tc.name, rec.Code, rec.Header().Get("RateLimit-Remaining"), tc.wantStatus, tc.wantRemaining, rec.Body)
+		}
+		if rec.Code == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: no Retry-After", tc.name)
+		}
+	}
+
+	// A second later each bucket has a token again.
+	now = now.Add(time.Second)
+	if rec := send("game-key-0123456789", http.MethodPost, "/api/coupons/validate", validate("u2")); rec.Code != http.StatusOK {
+		t.Errorf("after refill: status = %d", rec.Code)
+	}
+}
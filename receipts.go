@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// Receipt verification checks an in-app purchase receipt with the store that
+// issued it before the payment is reported to PlayCamp, so a forged or reused
+// receipt cannot credit a creator. Each payment platform can be given one
+// verifier in config. A payment that fails verification is not reported; it
+// is kept in the quarantine until it is retried or discarded.
+
+// Receipt verifier names, as used in receipts.verifiers.
+const (
+	receiptVerifierApple  = "apple"
+	receiptVerifierGoogle = "google"
+	receiptVerifierSigned = "signed"
+)
+
+// receiptVerifier checks the receipt of one payment.
+type receiptVerifier interface {
+	// verify returns nil when p's receipt proves the purchase. A
+	// *receiptError means the receipt is invalid; any other error means it
+	// could not be checked and may pass later.
+	verify(ctx context.Context, p paymentItem, isTest bool) error
+}
+
+// receiptError is a receipt that was checked and rejected.
+type receiptError struct {
+	reason string
+}
+
+func (e *receiptError) Error() string {
+	return e.reason
+}
+
+func rejectReceipt(format string, args ...any) error {
+	return &receiptError{reason: fmt.Sprintf(format, args...)}
+}
+
+// httpDoer sends HTTP requests. Verifiers that call a store take one so that
+// tests can stand in for the store.
+type httpDoer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// receiptHTTPClient is the client store lookups use.
+var receiptHTTPClient httpDoer = &http.Client{Timeout: 15 * time.Second}
+
+// receiptBatchTimeout bounds the receipt checks of one bulk request as a
+// whole, so a slow store cannot hold it for the client timeout once per
+// payment. The checks run up to receiptCheckConcurrency at a time.
+var receiptBatchTimeout = 20 * time.Second
+
+const receiptCheckConcurrency = 8
+
+// receiptVerifiers holds the verifier for each payment platform. It is
+// rebuilt when the config is reloaded.
+type receiptVerifiers struct {
+	names  map[playcamp.PaymentPlatform]string
+	byName map[string]receiptVerifier
+}
+
+// newReceiptVerifiers builds the verifiers cfg names, reading their key
+// and certificate files.
+func newReceiptVerifiers(cfg receiptsConfig, client httpDoer) (*receiptVerifiers, error) {
+	rv := &receiptVerifiers{names: map[playcamp.PaymentPlatform]string{}, byName: map[string]receiptVerifier{}}
+	for platform, name := range cfg.Verifiers {
+		rv.names[playcamp.PaymentPlatform(platform)] = name
+		if rv.byName[name] != nil {
+			continue
+		}
+		var (
+			v   receiptVerifier
+			err error
+		)
+		switch name {
+		case receiptVerifierApple:
+			v, err = newAppleVerifier(cfg.Apple)
+		case receiptVerifierGoogle:
+			v, err = newGoogleVerifier(cfg.Google, client)
+		case receiptVerifierSigned:
+			v = newSignedVerifier(cfg.Signed)
+		default:
+			err = fmt.Errorf("unknown verifier %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("receipts.%s: %w", name, err)
+		}
+		rv.byName[name] = v
+	}
+	return rv, nil
+}
+
+// forPlatform returns the verifier for platform, or nil if its payments are
+// not checked.
+func (rv *receiptVerifiers) forPlatform(platform playcamp.PaymentPlatform) (string, receiptVerifier) {
+	name := rv.names[platform]
+	return name, rv.byName[name]
+}
+
+// validate checks the receipts section of the config.
+func (c receiptsConfig) validate(add func(format string, args ...any)) {
+	used := map[string]bool{}
+	for platform, name := range c.Verifiers {
+		if !slices.Contains(enums["paymentPlatform"], platform) {
+			add("receipts.verifiers: unknown payment platform %q", platform)
+		}
+		switch name {
+		case receiptVerifierApple, receiptVerifierGoogle, receiptVerifierSigned:
+			used[name] = true
+		default:
+			add("receipts.verifiers.%s: must be %q, %q or %q, got %q", platform, receiptVerifierApple, receiptVerifierGoogle, receiptVerifierSigned, name)
+		}
+	}
+	if c.QuarantineSize < 1 {
+		add("receipts.quarantineSize: must be at least 1, got %d", c.QuarantineSize)
+	}
+
+	if used[receiptVerifierApple] {
+		if c.Apple.BundleID == "" {
+			add("receipts.apple.bundleId: required by the apple verifier")
+		}
+		if c.Apple.RootCAFile == "" {
+			add("receipts.apple.rootCAFile: required by the apple verifier")
+		}
+	}
+	if used[receiptVerifierGoogle] {
+		if c.Google.PackageName == "" {
+			add("receipts.google.packageName: required by the google verifier")
+		}
+		if c.Google.ServiceAccountFile == "" {
+			add("receipts.google.serviceAccountFile: required by the google verifier")
+		}
+	}
+	if c.Google.APIURL != "" {
+		if u, err := url.Parse(c.Google.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("receipts.google.apiUrl: must be an absolute http or https URL, got %q", c.Google.APIURL)
+		}
+	}
+	if used[receiptVerifierSigned] && len(c.Signed.Secrets) == 0 {
+		add("receipts.signed.secrets: required by the signed verifier")
+	}
+	for i, s := range c.Signed.Secrets {
+		if len(s) < 16 {
+			add("receipts.signed.secrets[%d]: must be at least 16 characters", i)
+		}
+	}
+}
+
+// --- Quarantine ---
+
+type quarantineKey struct {
+	isTest        bool
+	transactionID string
+}
+
+// quarantinedPayment is a payment held back because its receipt failed.
+type quarantinedPayment struct {
+	TransactionID string                   `json:"transactionId"`
+	Platform      playcamp.PaymentPlatform `json:"platform"`
+	Verifier      string                   `json:"verifier"`
+	Reason        string                   `json:"reason"`
+	// Retryable is set when the receipt could not be checked, for example
+	// because the store was unreachable or the purchase is still pending.
+	Retryable     bool        `json:"retryable"`
+	Attempts      int         `json:"attempts"`
+	QuarantinedAt string      `json:"quarantinedAt"`
+	LastAttemptAt string      `json:"lastAttemptAt"`
+	IsTest        bool        `json:"isTest"`
+	CallbackID    string      `json:"callbackId,omitempty"`
+	Payment       paymentItem `json:"payment"`
+}
+
+// quarantineStore keeps the newest maxSize quarantined payments, one per
+// transaction.
+type quarantineStore struct {
+	mu      sync.Mutex
+	maxSize int
+	entries map[quarantineKey]*quarantinedPayment
+	order   []quarantineKey // oldest first
+}
+
+func newQuarantineStore(maxSize int) *quarantineStore {
+	return &quarantineStore{maxSize: maxSize, entries: map[quarantineKey]*quarantinedPayment{}}
+}
+
+// add records a failed attempt for q's transaction and returns the entry.
+func (s *quarantineStore) add(q quarantinedPayment) quarantinedPayment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := quarantineKey{q.IsTest, q.TransactionID}
+	now := time.Now().UTC().Format(time.RFC3339)
+	q.Attempts, q.QuarantinedAt, q.LastAttemptAt = 1, now, now
+	if prev, ok := s.entries[key]; ok {
+		q.Attempts = prev.Attempts + 1
+		q.QuarantinedAt = prev.QuarantinedAt
+	} else {
+		s.order = append(s.order, key)
+	}
+	s.entries[key] = &q
+	s.trim()
+	return q
+}
+
+func (s *quarantineStore) get(key quarantineKey) (quarantinedPayment, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.entries[key]
+	if !ok {
+		return quarantinedPayment{}, false
+	}
+	return *q, true
+}
+
+// remove drops key and reports whether it was quarantined.
+func (s *quarantineStore) remove(key quarantineKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.entries[key]; !ok {
+		return false
+	}
+	delete(s.entries, key)
+	s.order = slices.DeleteFunc(s.order, func(k quarantineKey) bool { return k == key })
+	return true
+}
+
+// list returns the entries for one mode, newest first.
+func (s *quarantineStore) list(isTest bool) []quarantinedPayment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := []quarantinedPayment{}
+	for i := len(s.order) - 1; i >= 0; i-- {
+		if key := s.order[i]; key.isTest == isTest {
+			out = append(out, *s.entries[key])
+		}
+	}
+	return out
+}
+
+// resize changes how many payments are kept, dropping the oldest.
+func (s *quarantineStore) resize(maxSize int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxSize = maxSize
+	s.trim()
+}
+
+func (s *quarantineStore) trim() {
+	for len(s.order) > s.maxSize {
+		delete(s.entries, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// checkReceipt verifies p's receipt when its platform has a verifier. A
+// payment that fails is quarantined and returned; nil means it may be
+// reported.
+func (a *app) checkReceipt(ctx context.Context, p paymentItem, isTest bool, callbackID string) *quarantinedPayment {
+	name, v := a.receipts.Load().forPlatform(p.Platform)
+	if v == nil {
+		return nil
+	}
+	var err error
+	if p.Receipt == nil || *p.Receipt == "" {
+		err = rejectReceipt("a receipt is required for %s payments", p.Platform)
+	} else {
+		err = v.verify(ctx, p, isTest)
+	}
+	if err == nil {
+		return nil
+	}
+
+	// Keep the purchase time so a later retry reports when the purchase
+	// happened, not when it was retried.
+	if p.PurchasedAt == nil || *p.PurchasedAt == "" {
+		p.PurchasedAt = playcamp.String(time.Now().UTC().Format(time.RFC3339))
+	}
+	var rejected *receiptError
+	q := a.quarantine.add(quarantinedPayment{
+		TransactionID: p.TransactionID,
+		Platform:      p.Platform,
+		Verifier:      name,
+		Reason:        err.Error(),
+		Retryable:     !errors.As(err, &rejected),
+		IsTest:        isTest,
+		CallbackID:    callbackID,
+		Payment:       p,
+	})
+	a.lifecycle.log.Warn("payment quarantined", "transactionId", q.TransactionID, "platform", string(q.Platform), "verifier", name, "reason", q.Reason, "retryable", q.Retryable, "isTest", isTest)
+	return &q
+}
+
+// checkReceipts runs checkReceipt for each payment of a bulk request,
+// concurrently and under receiptBatchTimeout. The result has an entry per
+// payment, nil for those that may be reported.
+func (a *app) checkReceipts(ctx context.Context, items []paymentItem, isTest bool, callbackID string) []*quarantinedPayment {
+	ctx, cancel := context.WithTimeout(ctx, receiptBatchTimeout)
+	defer cancel()
+
+	out := make([]*quarantinedPayment, len(items))
+	sem := make(chan struct{}, receiptCheckConcurrency)
+	var wg sync.WaitGroup
+	for i, p := range items {
+		i, p := i, p
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			out[i] = a.checkReceipt(ctx, p, isTest, callbackID)
+		}()
+	}
+	wg.Wait()
+	return out
+}
+
+// writeQuarantined reports a payment that was quarantined instead of sent.
+func writeQuarantined(w http.ResponseWriter, r *http.Request, q *quarantinedPayment) {
+	typ, status, detail := problemReceipt, http.StatusUnprocessableEntity, "receipt rejected"
+	if q.Retryable {
+		typ, status, detail = problemReceiptCheck, http.StatusServiceUnavailable, "receipt could not be verified"
+	}
+	p := newProblem(r, typ, status, detail+"; the payment was quarantined and not reported")
+	p.Errors = []problemField{{Field: "receipt", Message: q.Reason}}
+	writeProblem(w, p)
+}
+
+// --- Quarantine Endpoints ---
+
+// handleListQuarantine handles GET /api/payments/quarantine
+func (a *app) handleListQuarantine(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.quarantine.list(isTestFromQuery(r)))
+}
+
+// handleRetryQuarantined handles POST /api/payments/quarantine/{transactionId}/retry
+func (a *app) handleRetryQuarantined(w http.ResponseWriter, r *http.Request) {
+	key := quarantineKey{isTestFromQuery(r), chi.URLParam(r, "transactionId")}
+	q, ok := a.quarantine.get(key)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "payment not quarantined")
+		return
+	}
+	if failed := a.checkReceipt(r.Context(), q.Payment, q.IsTest, q.CallbackID); failed != nil {
+		writeQuarantined(w, r, failed)
+		return
+	}
+
+	params := q.Payment.params()
+	params.CallbackID = q.CallbackID
+	if q.IsTest {
+		params.IsTest = playcamp.Bool(true)
+	}
+	payment, err := a.getSDK(r, q.IsTest).Payments.Create(r.Context(), params)
+	if err != nil {
+		handleSDKError(w, r, err)
+		return
+	}
+	a.quarantine.remove(key)
+	writeJSON(w, http.StatusCreated, payment)
+}
+
+// handleDeleteQuarantined handles DELETE /api/payments/quarantine/{transactionId}
+func (a *app) handleDeleteQuarantined(w http.ResponseWriter, r *http.Request) {
+	if !a.quarantine.remove(quarantineKey{isTestFromQuery(r), chi.URLParam(r, "transactionId")}) {
+		writeError(w, r, http.StatusNotFound, "payment not quarantined")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"deleted": true})
+}
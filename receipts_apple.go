@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Apple marks the certificates that sign App Store data with these
+// extensions, so a certificate Apple issued for anything else is refused.
+var (
+	oidAppStoreSigner    = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 11, 1}
+	oidAppleWWDRIntermCA = asn1.ObjectIdentifier{1, 2, 840, 113635, 100, 6, 2, 1}
+)
+
+const appleSandboxEnvironment = "Sandbox"
+
+// appleVerifier checks App Store signed transactions: the JWS StoreKit 2
+// returns for a purchase, or an App Store server notification carrying one.
+type appleVerifier struct {
+	bundleID string
+	roots    *x509.CertPool
+}
+
+func newAppleVerifier(cfg appleReceiptConfig) (*appleVerifier, error) {
+	raw, err := os.ReadFile(cfg.RootCAFile)
+	if err != nil {
+		return nil, err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(raw) {
+		return nil, fmt.Errorf("%s: no PEM certificates found", cfg.RootCAFile)
+	}
+	return &appleVerifier{bundleID: cfg.BundleID, roots: roots}, nil
+}
+
+// appleTransaction is the part of a JWSTransactionDecodedPayload we check.
+type appleTransaction struct {
+	TransactionID  string `json:"transactionId"`
+	BundleID       string `json:"bundleId"`
+	ProductID      string `json:"productId"`
+	Environment    string `json:"environment"`
+	Price          *int64 `json:"price"` // in milliunits
+	Currency       string `json:"currency"`
+	RevocationDate *int64 `json:"revocationDate"`
+}
+
+// appleNotification is the part of a server notification that carries the
+// signed transaction.
+type appleNotification struct {
+	Data *struct {
+		SignedTransactionInfo string `json:"signedTransactionInfo"`
+	} `json:"data"`
+}
+
+func (v *appleVerifier) verify(_ context.Context, p paymentItem, isTest bool) error {
+	payload, err := v.decodeJWS(*p.Receipt)
+	if err != nil {
+		return err
+	}
+	var n appleNotification
+	if json.Unmarshal(payload, &n) == nil && n.Data != nil && n.Data.SignedTransactionInfo != "" {
+		if payload, err = v.decodeJWS(n.Data.SignedTransactionInfo); err != nil {
+			return err
+		}
+	}
+	var tx appleTransaction
+	if err := json.Unmarshal(payload, &tx); err != nil {
+		return rejectReceipt("transaction payload is not JSON: %v", err)
+	}
+
+	switch {
+	case tx.BundleID != v.bundleID:
+		return rejectReceipt("receipt is for app %q", tx.BundleID)
+	case tx.TransactionID != p.TransactionID:
+		return rejectReceipt("receipt is for transaction %q", tx.TransactionID)
+	case tx.ProductID != p.ProductID:
+		return rejectReceipt("receipt is for product %q", tx.ProductID)
+	case tx.RevocationDate != nil:
+		return rejectReceipt("purchase was refunded or revoked")
+	case tx.Environment == appleSandboxEnvironment && !isTest:
+		return rejectReceipt("sandbox receipt on a live payment")
+	}
+	if tx.Price != nil && tx.Currency != "" {
+		if tx.Currency != p.Currency || *tx.Price != int64(math.Round(p.Amount*1000)) {
+			price := strconv.FormatFloat(float64(*tx.Price)/1000, 'f', -1, 64)
+			return rejectReceipt("receipt is for %s %s", price, tx.Currency)
+		}
+	}
+	return nil
+}
+
+// decodeJWS checks a compact ES256 JWS whose x5c chain leads to one of the
+// configured roots and returns its payload.
+func (v *appleVerifier) decodeJWS(s string) ([]byte, error) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, rejectReceipt("receipt is not a JWS")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, rejectReceipt("JWS header is not base64url")
+	}
+	var header struct {
+		Alg string   `json:"alg"`
+		X5C []string `json:"x5c"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, rejectReceipt("JWS header is not JSON")
+	}
+	if header.Alg != "ES256" {
+		return nil, rejectReceipt("JWS algorithm must be ES256, got %q", header.Alg)
+	}
+	if len(header.X5C) < 2 {
+		return nil, rejectReceipt("JWS x5c must hold the signing and intermediate certificates")
+	}
+
+	certs := make([]*x509.Certificate, len(header.X5C))
+	for i, c := range header.X5C {
+		der, err := base64.StdEncoding.DecodeString(c)
+		if err == nil {
+			certs[i], err = x509.ParseCertificate(der)
+		}
+		if err != nil {
+			return nil, rejectReceipt("x5c[%d] is not a certificate", i)
+		}
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range certs[1:] {
+		intermediates.AddCert(c)
+	}
+	leaf := certs[0]
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: v.roots, Intermediates: intermediates, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		return nil, rejectReceipt("certificate chain: %v", err)
+	}
+	if !hasExtension(leaf, oidAppStoreSigner) || !hasExtension(certs[1], oidAppleWWDRIntermCA) {
+		return nil, rejectReceipt("certificates are not App Store signing certificates")
+	}
+
+	key, ok := leaf.PublicKey.(*ecdsa.PublicKey)
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if !ok || err != nil || len(sig) != 64 {
+		return nil, rejectReceipt("JWS signature is malformed")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		return nil, rejectReceipt("JWS signature does not match")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, rejectReceipt("JWS payload is not base64url")
+	}
+	return payload, nil
+}
+
+func hasExtension(c *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range c.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// testAppleSigner stands in for the App Store: a root, an intermediate and
+// a signing certificate carrying Apple's marker extensions.
+type testAppleSigner struct {
+	rootFile string
+	chain    []*x509.Certificate
+	key      *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, name string, ext asn1.ObjectIdentifier, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil || ext.Equal(oidAppleWWDRIntermCA),
+	}
+	if ext != nil {
+		tmpl.ExtraExtensions = []pkix.Extension{{Id: ext, Value: []byte{0x05, 0x00}}}
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func newTestAppleSigner(t *testing.T) testAppleSigner {
+	t.Helper()
+	root, rootKey := newTestCert(t, "Test Root CA", nil, nil, nil)
+	inter, interKey := newTestCert(t, "Test WWDR", oidAppleWWDRIntermCA, root, rootKey)
+	leaf, leafKey := newTestCert(t, "Test App Store Signer", oidAppStoreSigner, inter, interKey)
+
+	rootFile := filepath.Join(t.TempDir(), "root.pem")
+	if err := os.WriteFile(rootFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return testAppleSigner{rootFile: rootFile, chain: []*x509.Certificate{leaf, inter, root}, key: leafKey}
+}
+
+// sign returns payload as a compact ES256 JWS.
+func (s testAppleSigner) sign(t *testing.T, payload any) string {
+	t.Helper()
+	var x5c []string
+	for _, c := range s.chain {
+		x5c = append(x5c, base64.StdEncoding.EncodeToString(c.Raw))
+	}
+	header, _ := json.Marshal(map[string]any{"alg": "ES256", "x5c": x5c})
+	body, _ := json.Marshal(payload)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signed))
+	r, ss, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	ss.FillBytes(sig[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestAppleReceiptVerifier(t *testing.T) {
+	signer := newTestAppleSigner(t)
+	v, err := newAppleVerifier(appleReceiptConfig{BundleID: testPackageName, RootCAFile: signer.rootFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := func(changes map[string]any) map[string]any {
+		m := map[string]any{
+			"transactionId": "2000000123", "bundleId": testPackageName, "productId": "gems_100",
+			"environment": "Production", "price": 4990, "currency": "USD",
+		}
+		for k, v := range changes {
+			m[k] = v
+		}
+		return m
+	}
+	valid := signer.sign(t, tx(nil))
+	forged := newTestAppleSigner(t).sign(t, tx(nil))
+	parts := strings.Split(valid, ".")
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"transactionId":"2000000123","bundleId":"com.example.game","productId":"gems_100"}`)) + "." + parts[2]
+
+	tests := []struct {
+		name    string
+		receipt string
+		isTest  bool
+		wantErr bool
+	}{
+		{"valid", valid, false, false},
+		{"server notification", signer.sign(t, map[string]any{"notificationType": "ONE_TIME_CHARGE", "data": map[string]any{"signedTransactionInfo": valid}}), false, false},
+		{"sandbox on test payment", signer.sign(t, tx(map[string]any{"environment": "Sandbox"})), true, false},
+		{"sandbox on live payment", signer.sign(t, tx(map[string]any{"environment": "Sandbox"})), false, true},
+		{"other product", signer.sign(t, tx(map[string]any{"productId": "gems_500"})), false, true},
+		{"other app", signer.sign(t, tx(map[string]any{"bundleId": "com.example.other"})), false, true},
+		{"other price", signer.sign(t, tx(map[string]any{"price": 990})), false, true},
+		{"revoked", signer.sign(t, tx(map[string]any{"revocationDate": 1700000000000})), false, true},
+		{"untrusted root", forged, false, true},
+		{"tampered payload", tampered, false, true},
+		{"not a JWS", "MIIT...", false, true},
+	}
+	for _, tc := range tests {
+		p := paymentItem{TransactionID: "2000000123", ProductID: "gems_100", Amount: 4.99, Currency: "USD", Platform: playcamp.PaymentPlatformIOS, Receipt: playcamp.String(tc.receipt)}
+		err := v.verify(context.Background(), p, tc.isTest)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tc.name, err, tc.wantErr)
+		}
+		if _, ok := err.(*receiptError); err != nil && !ok {
+			t.Errorf("%s: err = %T, want *receiptError", tc.name, err)
+		}
+	}
+}
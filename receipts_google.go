@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	googlePlayAPIURL   = "https://androidpublisher.googleapis.com"
+	googleTokenURL     = "https://oauth2.googleapis.com/token"
+	googlePlayScope    = "https://www.googleapis.com/auth/androidpublisher"
+	googleJWTGrantType = "urn:ietf:params:oauth:grant-type:jwt-bearer"
+)
+
+// googleVerifier looks up Google Play purchase tokens with the Android
+// Publisher API. The receipt is the purchase token.
+type googleVerifier struct {
+	packageName string
+	apiURL      string
+	client      httpDoer
+	account     googleServiceAccount
+	key         *rsa.PrivateKey
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// googleServiceAccount is the part of a service account key file we use.
+type googleServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+func newGoogleVerifier(cfg googleReceiptConfig, client httpDoer) (*googleVerifier, error) {
+	raw, err := os.ReadFile(cfg.ServiceAccountFile)
+	if err != nil {
+		return nil, err
+	}
+	var account googleServiceAccount
+	if err := json.Unmarshal(raw, &account); err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.ServiceAccountFile, err)
+	}
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if account.ClientEmail == "" || block == nil {
+		return nil, fmt.Errorf("%s: client_email and a PEM private_key are required", cfg.ServiceAccountFile)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	key, ok := parsed.(*rsa.PrivateKey)
+	if err != nil || !ok {
+		return nil, fmt.Errorf("%s: private_key must be a PKCS #8 RSA key", cfg.ServiceAccountFile)
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = googleTokenURL
+	}
+	apiURL := cfg.APIURL
+	if apiURL == "" {
+		apiURL = googlePlayAPIURL
+	}
+	return &googleVerifier{
+		packageName: cfg.PackageName,
+		apiURL:      strings.TrimSuffix(apiURL, "/"),
+		client:      client,
+		account:     account,
+		key:         key,
+	}, nil
+}
+
+// googleProductPurchase is the part of a ProductPurchase we check.
+type googleProductPurchase struct {
+	// PurchaseState is 0 purchased, 1 canceled or 2 pending.
+	PurchaseState int    `json:"purchaseState"`
+	OrderID       string `json:"orderId"`
+	// PurchaseType is 0 for license testers and absent for real purchases.
+	PurchaseType *int `json:"purchaseType"`
+}
+
+func (v *googleVerifier) verify(ctx context.Context, p paymentItem, isTest bool) error {
+	token, err := v.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%s/androidpublisher/v3/applications/%s/purchases/products/%s/tokens/%s",
+		v.apiURL, url.PathEscape(v.packageName), url.PathEscape(p.ProductID), url.PathEscape(*p.Receipt))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("google play: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusGone:
+		return rejectReceipt("Google Play does not know this purchase token for %s", p.ProductID)
+	case http.StatusUnauthorized:
+		v.mu.Lock()
+		v.token = ""
+		v.mu.Unlock()
+		return fmt.Errorf("google play: %s", resp.Status)
+	default:
+		return fmt.Errorf("google play: %s", resp.Status)
+	}
+	var purchase googleProductPurchase
+	if err := json.NewDecoder(resp.Body).Decode(&purchase); err != nil {
+		return fmt.Errorf("google play: %w", err)
+	}
+
+	switch {
+	case purchase.PurchaseState == 1:
+		return rejectReceipt("purchase was canceled")
+	case purchase.PurchaseState == 2:
+		return fmt.Errorf("purchase is still pending")
+	case purchase.OrderID != "" && purchase.OrderID != p.TransactionID:
+		return rejectReceipt("purchase token is for order %q", purchase.OrderID)
+	case purchase.PurchaseType != nil && *purchase.PurchaseType == 0 && !isTest:
+		return rejectReceipt("test purchase on a live payment")
+	}
+	return nil
+}
+
+// accessToken returns an OAuth access token for the service account,
+// fetching a new one shortly before the cached one expires.
+func (v *googleVerifier) accessToken(ctx context.Context) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.token != "" && time.Until(v.tokenExpiry) > time.Minute {
+		return v.token, nil
+	}
+	assertion, err := v.signAssertion(time.Now())
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{"grant_type": {googleJWTGrantType}, "assertion": {assertion}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("google token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("google token: %s", resp.Status)
+	}
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.AccessToken == "" {
+		return "", fmt.Errorf("google token: no access_token in response")
+	}
+	v.token = body.AccessToken
+	v.tokenExpiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	return v.token, nil
+}
+
+// signAssertion signs the JWT exchanged for an access token.
+func (v *googleVerifier) signAssertion(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	claims, err := json.Marshal(map[string]any{
+		"iss":   v.account.ClientEmail,
+		"scope": googlePlayScope,
+		"aud":   v.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	signed := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`)) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, v.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+const testPackageName = "com.example.game"
+
+// fakeGooglePlay serves the OAuth token endpoint and purchase lookups.
+// Purchases maps a purchase token to its ProductPurchase JSON; unknown
+// tokens are 404.
+type fakeGooglePlay struct {
+	*httptest.Server
+	accountFile string
+
+	mu        sync.Mutex
+	purchases map[string]string
+	tokens    int
+}
+
+func newFakeGooglePlay(t *testing.T) *fakeGooglePlay {
+	t.Helper()
+	f := &fakeGooglePlay{purchases: map[string]string{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != googleJWTGrantType || strings.Count(r.FormValue("assertion"), ".") != 2 {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.tokens++
+		f.mu.Unlock()
+		w.Write([]byte(`{"access_token":"at-1","expires_in":3600,"token_type":"Bearer"}`))
+	})
+	prefix := "/androidpublisher/v3/applications/" + testPackageName + "/purchases/products/"
+	mux.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, token, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, prefix), "/tokens/")
+		f.mu.Lock()
+		body, ok := f.purchases[token]
+		f.mu.Unlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(body))
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	account, _ := json.Marshal(googleServiceAccount{
+		ClientEmail: "verifier@example.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    f.URL + "/token",
+	})
+	f.accountFile = filepath.Join(t.TempDir(), "service-account.json")
+	if err := os.WriteFile(f.accountFile, account, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *fakeGooglePlay) setPurchase(token, body string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.purchases[token] = body
+}
+
+func TestGoogleReceiptVerifier(t *testing.T) {
+	fake := newFakeGooglePlay(t)
+	fake.setPurchase("good", `{"purchaseState":0,"orderId":"GPA.1"}`)
+	fake.setPurchase("canceled", `{"purchaseState":1,"orderId":"GPA.1"}`)
+	fake.setPurchase("pending", `{"purchaseState":2,"orderId":"GPA.1"}`)
+	fake.setPurchase("tester", `{"purchaseState":0,"orderId":"GPA.1","purchaseType":0}`)
+	fake.setPurchase("other", `{"purchaseState":0,"orderId":"GPA.2"}`)
+
+	v, err := newGoogleVerifier(googleReceiptConfig{PackageName: testPackageName, ServiceAccountFile: fake.accountFile, APIURL: fake.URL}, fake.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		token     string
+		isTest    bool
+		wantErr   bool
+		retryable bool
+	}{
+		{"good", false, false, false},
+		{"tester", true, false, false},
+		{"tester", false, true, false},
+		{"canceled", false, true, false},
+		{"other", false, true, false},
+		{"unknown", false, true, false},
+		{"pending", false, true, true},
+	}
+	for _, tc := range tests {
+		p := paymentItem{TransactionID: "GPA.1", ProductID: "gems_100", Platform: playcamp.PaymentPlatformAndroid, Receipt: playcamp.String(tc.token)}
+		err := v.verify(context.Background(), p, tc.isTest)
+		var rejected *receiptError
+		if (err != nil) != tc.wantErr || (err != nil && errors.As(err, &rejected) == tc.retryable) {
+			t.Errorf("%s (isTest %v): err = %v, want error %v, retryable %v", tc.token, tc.isTest, err, tc.wantErr, tc.retryable)
+		}
+	}
+	if fake.tokens != 1 {
+		t.Errorf("access tokens fetched = %d, want 1", fake.tokens)
+	}
+}
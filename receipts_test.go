@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+const testReceiptSecret = "receipt-secret-0123456789"
+
+func testSignedReceipt(secret string, r signedReceipt) string {
+	raw, _ := json.Marshal(r)
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return payload + "." + signReceipt(secret, payload)
+}
+
+func TestSignedReceiptVerifier(t *testing.T) {
+	v := newSignedVerifier(signedReceiptConfig{Secrets: []string{"old-secret-0123456789", testReceiptSecret}})
+	receipt := signedReceipt{TransactionID: "tx-1", UserID: "u1", ProductID: "gems_100", Amount: "1200", Currency: "KRW"}
+	change := func(f func(*signedReceipt)) signedReceipt {
+		r := receipt
+		f(&r)
+		return r
+	}
+
+	tests := []struct {
+		name    string
+		receipt string
+		wantErr bool
+	}{
+		{"valid", testSignedReceipt(testReceiptSecret, receipt), false},
+		{"rotated secret", testSignedReceipt("old-secret-0123456789", receipt), false},
+		{"unknown secret", testSignedReceipt("other-secret-0123456789", receipt), true},
+		{"other transaction", testSignedReceipt(testReceiptSecret, change(func(r *signedReceipt) { r.TransactionID = "tx-2" })), true},
+		{"other user", testSignedReceipt(testReceiptSecret, change(func(r *signedReceipt) { r.UserID = "u2" })), true},
+		{"other amount", testSignedReceipt(testReceiptSecret, change(func(r *signedReceipt) { r.Amount = "1100" })), true},
+		{"other currency", testSignedReceipt(testReceiptSecret, change(func(r *signedReceipt) { r.Currency = "JPY" })), true},
+		{"unsigned", "tx-1", true},
+	}
+	for _, tc := range tests {
+		p := paymentItem{UserID: "u1", TransactionID: "tx-1", ProductID: "gems_100", Amount: 1200, Currency: "KRW", Platform: playcamp.PaymentPlatformWeb, Receipt: playcamp.String(tc.receipt)}
+		if err := v.verify(context.Background(), p, false); (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestPaymentReceiptQuarantine(t *testing.T) {
+	play := newFakeGooglePlay(t)
+	play.setPurchase("pending-token", `{"purchaseState":2,"orderId":"GPA.7"}`)
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodPost, "/v1/server/payments", http.StatusCreated, `{"data":{"transactionId":"tx"}}`)
+	fake.on(http.MethodPost, "/v1/server/payments/bulk", http.StatusCreated, `{"data":{"totalRequested":1,"successful":1,"results":[]}}`)
+	a := newTestApp(t, fake, map[string]string{
+		"RECEIPT_VERIFIERS":                "Web=signed,Android=google",
+		"RECEIPT_SIGNING_SECRETS":          testReceiptSecret,
+		"GOOGLE_PLAY_PACKAGE_NAME":         testPackageName,
+		"GOOGLE_PLAY_SERVICE_ACCOUNT_FILE": play.accountFile,
+		"GOOGLE_PLAY_API_URL":              play.URL,
+	})
+	web := func(txID, receipt string) string {
+		return `{"userId":"u1","transactionId":"` + txID + `","productId":"gems_100","amount":4.99,"currency":"USD","platform":"Web","receipt":"` + receipt + `"}`
+	}
+	good := testSignedReceipt(testReceiptSecret, signedReceipt{TransactionID: "tx-ok", UserID: "u1", ProductID: "gems_100", Amount: "4.99", Currency: "USD"})
+
+	if rec := serve(a, http.MethodPost, "/api/payments", web("tx-ok", good)); rec.Code != http.StatusCreated {
+		t.Fatalf("valid receipt: status = %d; body %s", rec.Code, rec.Body)
+	}
+	rec := serve(a, http.MethodPost, "/api/payments", web("tx-forged", good))
+	if p := decodeProblem(t, rec); rec.Code != http.StatusUnprocessableEntity || p.Type != problemReceipt || len(p.Errors) != 1 || p.Errors[0].Field != "receipt" {
+		t.Fatalf("forged receipt: status = %d, problem %+v", rec.Code, p)
+	}
+	rec = serve(a, http.MethodPost, "/api/payments", `{"userId":"u1","transactionId":"GPA.7","productId":"gems_100","amount":4.99,"currency":"USD","platform":"Android","receipt":"pending-token"}`)
+	if p := decodeProblem(t, rec); rec.Code != http.StatusServiceUnavailable || p.Type != problemReceiptCheck {
+		t.Fatalf("pending purchase: status = %d, problem %+v", rec.Code, p)
+	}
+	// iOS has no verifier, so its payments are reported unchecked.
+	if rec := serve(a, http.MethodPost, "/api/payments", strings.Replace(testPayment, `"Android"`, `"iOS"`, 1)); rec.Code != http.StatusCreated {
+		t.Fatalf("unchecked platform: status = %d; body %s", rec.Code, rec.Body)
+	}
+	if got := len(fake.received()); got != 2 {
+		t.Fatalf("upstream requests = %d, want 2", got)
+	}
+
+	var held []quarantinedPayment
+	decodeData(t, serve(a, http.MethodGet, "/api/payments/quarantine", ""), &held)
+	if len(held) != 2 || held[0].TransactionID != "GPA.7" || !held[0].Retryable || held[1].TransactionID != "tx-forged" || held[1].Retryable {
+		t.Fatalf("quarantine = %+v", held)
+	}
+
+	// Once the purchase completes, a retry reports it with its original time.
+	play.setPurchase("pending-token", `{"purchaseState":0,"orderId":"GPA.7"}`)
+	if rec := serve(a, http.MethodPost, "/api/payments/quarantine/GPA.7/retry", ""); rec.Code != http.StatusCreated {
+		t.Fatalf("retry: status = %d; body %s", rec.Code, rec.Body)
+	}
+	sent := fake.received()
+	if body := jsonBody(t, sent[len(sent)-1]); body["transactionId"] != "GPA.7" || body["purchasedAt"] != *held[0].Payment.PurchasedAt {
+		t.Errorf("retried payment = %v", body)
+	}
+	if rec := serve(a, http.MethodPost, "/api/payments/quarantine/tx-forged/retry", ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("retry forged: status = %d", rec.Code)
+	}
+	if rec := serve(a, http.MethodDelete, "/api/payments/quarantine/tx-forged", ""); rec.Code != http.StatusOK {
+		t.Errorf("delete: status = %d", rec.Code)
+	}
+	if rec := serve(a, http.MethodDelete, "/api/payments/quarantine/tx-forged", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("delete again: status = %d", rec.Code)
+	}
+
+	// A bulk request reports the payments that pass and quarantines the rest.
+	goodBulk := testSignedReceipt(testReceiptSecret, signedReceipt{TransactionID: "tx-b1", UserID: "u1", ProductID: "gems_100", Amount: "4.99", Currency: "USD"})
+	var bulk bulkPaymentResult
+	decodeData(t, serve(a, http.MethodPost, "/api/payments/bulk", `{"payments":[`+web("tx-b1", goodBulk)+`,`+strings.Replace(web("tx-b2", ""), `,"receipt":""`, "", 1)+`]}`), &bulk)
+	if bulk.TotalRequested != 1 || len(bulk.Quarantined) != 1 || bulk.Quarantined[0].Index != 1 || bulk.Quarantined[0].TransactionID != "tx-b2" {
+		t.Errorf("bulk = %+v", bulk)
+	}
+	sent = fake.received()
+	if payments, _ := jsonBody(t, sent[len(sent)-1])["payments"].([]any); len(payments) != 1 {
+		t.Errorf("upstream bulk payments = %v", payments)
+	}
+	if rec := serve(a, http.MethodPost, "/api/payments/bulk", `{"payments":[`+web("tx-b3", "x.y")+`]}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("bulk all rejected: status = %d; body %s", rec.Code, rec.Body)
+	}
+}
+
+// verifierFunc adapts a function to receiptVerifier.
+type verifierFunc func(ctx context.Context, p paymentItem, isTest bool) error
+
+func (f verifierFunc) verify(ctx context.Context, p paymentItem, isTest bool) error {
+	return f(ctx, p, isTest)
+}
+
+func TestCheckReceiptsBatch(t *testing.T) {
+	defer func(d time.Duration) { receiptBatchTimeout = d }(receiptBatchTimeout)
+	receiptBatchTimeout = 200 * time.Millisecond
+
+	// Payments tx-1 to tx-3 pass only once all three are being checked at
+	// the same time; tx-stuck waits until the batch deadline.
+	var mu sync.Mutex
+	arrived := 0
+	all := make(chan struct{})
+	stub := verifierFunc(func(ctx context.Context, p paymentItem, isTest bool) error {
+		if p.TransactionID == "tx-stuck" {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		mu.Lock()
+		if arrived++; arrived == 3 {
+			close(all)
+		}
+		mu.Unlock()
+		select {
+		case <-all:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	a.receipts.Store(&receiptVerifiers{
+		names:  map[playcamp.PaymentPlatform]string{playcamp.PaymentPlatformWeb: "stub"},
+		byName: map[string]receiptVerifier{"stub": stub},
+	})
+
+	var items []paymentItem
+	for _, id := range []string{"tx-1", "tx-stuck", "tx-2", "tx-3"} {
+		items = append(items, paymentItem{TransactionID: id, Platform: playcamp.PaymentPlatformWeb, Receipt: playcamp.String("r")})
+	}
+	start := time.Now()
+	got := a.checkReceipts(context.Background(), items, true, "")
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("batch took %v", elapsed)
+	}
+	for i, q := range got {
+		if wantHeld := items[i].TransactionID == "tx-stuck"; (q != nil) != wantHeld || (q != nil && !q.Retryable) {
+			t.Errorf("%s: quarantined %+v", items[i].TransactionID, q)
+		}
+	}
+}
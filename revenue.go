@@ -0,0 +1,779 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// Creator revenue is accrued from payment webhooks into daily rows per
+// creator, campaign and currency. Amounts are kept as money, in integer
+// minor units, so sums are exact. payment.bulk_created and refunds of
+// payments the ledger has not seen carry no amounts; their transactions are
+// looked up with Payments.Get in the background.
+//
+// Days up to closedThrough are closed: reports for them have gone out. A
+// refund of a payment in a closed day is booked on the day of the refund as a
+// late refund, and a payment for a closed day that arrives only now is booked
+// on the day it arrived as a late payment, leaving the closed figures as they
+// were reported. Closing drops the payments booked on closed days, so the
+// ledger only holds open days; closedThrough itself is saved to
+// revenue.stateFile when one is configured.
+
+const (
+	// revenueResolveInterval is how often unresolved transactions are
+	// looked up.
+	revenueResolveInterval = 30 * time.Second
+	// maxResolveAttempts bounds lookups of a transaction PlayCamp does not
+	// return.
+	maxResolveAttempts = 5
+
+	dayLayout = "2006-01-02"
+)
+
+type revenueTxKey struct {
+	isTest        bool
+	transactionID string
+}
+
+type revenueRowKey struct {
+	isTest     bool
+	day        string
+	creatorKey string
+	campaignID string
+	currency   string
+}
+
+// accruedPayment is a payment the ledger has booked.
+type accruedPayment struct {
+	creatorKey string
+	campaignID string
+	currency   string
+	amount     int64
+	// day is the day the payment is booked on: its own, or the day it
+	// arrived if its own was already closed.
+	day      string
+	refunded bool
+}
+
+// pendingTransaction is a transaction waiting to be looked up.
+type pendingTransaction struct {
+	// refundDay is set when a refund arrived before its payment.
+	refundDay string
+	attempts  int
+}
+
+// revenueRow is the revenue of one creator, campaign and currency on a day.
+type revenueRow struct {
+	payments, refunds, latePayments, lateRefunds int
+	gross, refunded                              int64
+	// convertedGross and convertedRefunded are in the reporting currency,
+	// filled in only for reports.
+	convertedGross, convertedRefunded int64
+}
+
+// revenueLedger accumulates creator revenue. It is safe for concurrent use.
+type revenueLedger struct {
+	now func() time.Time
+
+	mu            sync.Mutex
+	payments      map[revenueTxKey]*accruedPayment
+	pending       map[revenueTxKey]*pendingTransaction
+	rows          map[revenueRowKey]*revenueRow
+	closedThrough string
+}
+
+func newRevenueLedger() *revenueLedger {
+	return &revenueLedger{
+		now:      time.Now,
+		payments: map[revenueTxKey]*accruedPayment{},
+		pending:  map[revenueTxKey]*pendingTransaction{},
+		rows:     map[revenueRowKey]*revenueRow{},
+	}
+}
+
+// eventDay returns the UTC day of an event timestamp, or today.
+func eventDay(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		t = time.Now()
+	}
+	return t.UTC().Format(dayLayout)
+}
+
+// row returns the row for key, creating it if needed. The caller holds the
+// lock.
+func (l *revenueLedger) row(key revenueRowKey) *revenueRow {
+	r, ok := l.rows[key]
+	if !ok {
+		r = &revenueRow{}
+		l.rows[key] = r
+	}
+	return r
+}
+
+// openDay returns the first day after closedThrough that is not before
+// today, where late payments and refunds are booked. The caller holds the
+// lock.
+func (l *revenueLedger) openDay() string {
+	day := l.now().UTC().Format(dayLayout)
+	if day <= l.closedThrough {
+		t, _ := time.Parse(dayLayout, l.closedThrough)
+		day = t.AddDate(0, 0, 1).Format(dayLayout)
+	}
+	return day
+}
+
+// addPayment books a payment. A payment already booked is ignored, so
+// retried deliveries and lookups do not count twice.
+func (l *revenueLedger) addPayment(tx revenueTxKey, p accruedPayment) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.payments[tx]; ok {
+		return
+	}
+	if pend, ok := l.pending[tx]; ok && pend.refundDay != "" && p.day <= l.closedThrough {
+		// A refund looked up a payment of a closed day. The ledger dropped
+		// it on close, or never saw it before a restart; either way it was
+		// reported with that day, so only the refund is booked.
+		delete(l.pending, tx)
+		l.payments[tx] = &p
+		l.refundLocked(tx, pend.refundDay)
+		return
+	}
+	late := p.day <= l.closedThrough
+	if late {
+		p.day = l.openDay()
+	}
+	l.payments[tx] = &p
+	r := l.row(revenueRowKey{tx.isTest, p.day, p.creatorKey, p.campaignID, p.currency})
+	r.payments++
+	r.gross += p.amount
+	if late {
+		r.latePayments++
+	}
+
+	if pend, ok := l.pending[tx]; ok {
+		delete(l.pending, tx)
+		if pend.refundDay != "" {
+			l.refundLocked(tx, pend.refundDay)
+		}
+	}
+}
+
+// addRefund books the refund of a payment on refundDay, or waits for the
+// payment to be looked up if the ledger has not seen it.
+func (l *revenueLedger) addRefund(tx revenueTxKey, refundDay string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.payments[tx]; !ok {
+		pend := l.pendingLocked(tx)
+		pend.refundDay = refundDay
+		return
+	}
+	l.refundLocked(tx, refundDay)
+}
+
+func (l *revenueLedger) refundLocked(tx revenueTxKey, refundDay string) {
+	p := l.payments[tx]
+	if p.refunded {
+		return
+	}
+	p.refunded = true
+
+	day := p.day
+	late := day <= l.closedThrough
+	if late {
+		day = refundDay
+		if day <= l.closedThrough {
+			day = l.openDay()
+		}
+	}
+	r := l.row(revenueRowKey{tx.isTest, day, p.creatorKey, p.campaignID, p.currency})
+	r.refunds++
+	r.refunded += p.amount
+	if late {
+		r.lateRefunds++
+	}
+}
+
+// addUnresolved queues a transaction seen without its details.
+func (l *revenueLedger) addUnresolved(tx revenueTxKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.payments[tx]; !ok {
+		l.pendingLocked(tx)
+	}
+}
+
+func (l *revenueLedger) pendingLocked(tx revenueTxKey) *pendingTransaction {
+	pend, ok := l.pending[tx]
+	if !ok {
+		pend = &pendingTransaction{}
+		l.pending[tx] = pend
+	}
+	return pend
+}
+
+// backlog returns how many transactions are waiting to be looked up.
+func (l *revenueLedger) backlog() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.pending)
+}
+
+// unresolved returns the transactions waiting to be looked up.
+func (l *revenueLedger) unresolved() []revenueTxKey {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	keys := make([]revenueTxKey, 0, len(l.pending))
+	for k := range l.pending {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].transactionID < keys[j].transactionID })
+	return keys
+}
+
+// resolveFailed counts a failed lookup and reports whether tx was dropped.
+func (l *revenueLedger) resolveFailed(tx revenueTxKey) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pend, ok := l.pending[tx]
+	if !ok {
+		return false
+	}
+	pend.attempts++
+	if pend.attempts >= maxResolveAttempts {
+		delete(l.pending, tx)
+		return true
+	}
+	return false
+}
+
+// close marks every day through day as closed and drops the payments
+// booked on them. Closed days cannot reopen.
+func (l *revenueLedger) close(day string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if day < l.closedThrough {
+		return fmt.Errorf("days through %s are already closed", l.closedThrough)
+	}
+	l.closedThrough = day
+	for tx, p := range l.payments {
+		if p.day <= day {
+			delete(l.payments, tx)
+		}
+	}
+	return nil
+}
+
+// revenueState is what revenue.stateFile holds.
+type revenueState struct {
+	ClosedThrough string `json:"closedThrough"`
+}
+
+// loadState restores closedThrough from path. A missing file is a ledger
+// that has never been closed.
+func (l *revenueLedger) loadState(path string) error {
+	if path == "" {
+		return nil
+	}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var state revenueState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if _, err := time.Parse(dayLayout, state.ClosedThrough); state.ClosedThrough != "" && err != nil {
+		return fmt.Errorf("%s: closedThrough must be a date such as 2026-01-31", path)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closedThrough = state.ClosedThrough
+	return nil
+}
+
+// saveState writes closedThrough to path atomically.
+func (l *revenueLedger) saveState(path string) error {
+	l.mu.Lock()
+	raw, err := json.Marshal(revenueState{ClosedThrough: l.closedThrough})
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// --- Webhook Consumption ---
+
+// applyRevenueWebhook books the payment events of a received webhook.
+// Invalid webhooks and duplicate deliveries are skipped, and so is every
+// delivery while no webhook secret is configured.
+func (a *app) applyRevenueWebhook(ctx context.Context, wh receivedWebhook) {
+	if !wh.Valid || wh.DuplicateOf != "" {
+		return
+	}
+	if !a.webhookSecretConfigured() {
+		if wh.hasEvent(playcamp.WebhookEventPaymentCreated, playcamp.WebhookEventPaymentRefunded, playcamp.WebhookEventPaymentBulkCreated) {
+			a.webhookLog.WarnContext(ctx, "payment events not booked: no webhook secret is configured", "id", wh.ID)
+		}
+		return
+	}
+	for _, evt := range wh.Events {
+		isTest := evt.IsTest != nil && *evt.IsTest
+		switch playcamp.WebhookEventType(evt.Event) {
+		case playcamp.WebhookEventPaymentCreated:
+			var data playcamp.PaymentCreatedData
+			if err := json.Unmarshal(evt.Data, &data); err != nil || data.TransactionID == "" {
+				a.webhookLog.WarnContext(ctx, "payment event not booked", "id", wh.ID, "event", evt.Event)
+				continue
+			}
+			a.revenue.addPayment(revenueTxKey{isTest, data.TransactionID}, accruedPayment{
+				creatorKey: deref(data.CreatorKey),
+				campaignID: deref(data.CampaignID),
+				currency:   data.Currency,
+				amount:     moneyFromFloat(data.Amount, data.Currency).Minor,
+				day:        eventDay(evt.Timestamp),
+			})
+		case playcamp.WebhookEventPaymentRefunded:
+			var data playcamp.PaymentRefundedData
+			if err := json.Unmarshal(evt.Data, &data); err != nil || data.TransactionID == "" {
+				a.webhookLog.WarnContext(ctx, "refund event not booked", "id", wh.ID, "event", evt.Event)
+				continue
+			}
+			a.revenue.addRefund(revenueTxKey{isTest, data.TransactionID}, eventDay(evt.Timestamp))
+		case playcamp.WebhookEventPaymentBulkCreated:
+			var data playcamp.PaymentBulkCreatedData
+			if err := json.Unmarshal(evt.Data, &data); err != nil {
+				a.webhookLog.WarnContext(ctx, "bulk payment event not booked", "id", wh.ID, "event", evt.Event)
+				continue
+			}
+			for _, id := range data.TransactionIDs {
+				a.revenue.addUnresolved(revenueTxKey{isTest, id})
+			}
+		}
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// resolveRevenueTransactions looks up every unresolved transaction once,
+// stopping early when the upstream budget runs out; the rest wait for the
+// next tick.
+func (a *app) resolveRevenueTransactions(ctx context.Context) {
+	txs := a.revenue.unresolved()
+	for i, tx := range txs {
+		if ctx.Err() != nil {
+			return
+		}
+		if !a.allowBackgroundUpstream() {
+			a.webhookLog.Info("revenue lookups paused, upstream budget exhausted", "remaining", len(txs)-i)
+			return
+		}
+		sdk := a.server
+		if tx.isTest {
+			sdk = a.testServer
+		}
+		p, err := sdk.Payments.Get(ctx, tx.transactionID)
+		if err != nil {
+			if a.revenue.resolveFailed(tx) {
+				a.webhookLog.Warn("gave up looking up payment for revenue", "transactionId", tx.transactionID, "error", err.Error())
+			}
+			continue
+		}
+		day := eventDay(p.PurchasedAt)
+		if p.PurchasedAt == "" {
+			day = eventDay(p.CreatedAt)
+		}
+		a.revenue.addPayment(tx, accruedPayment{
+			creatorKey: deref(p.CreatorKey),
+			campaignID: deref(p.CampaignID),
+			currency:   p.Currency,
+			amount:     moneyFromFloat(p.Amount, p.Currency).Minor,
+			day:        day,
+		})
+	}
+}
+
+// runRevenueResolver looks up unresolved transactions until ctx is done.
+func (a *app) runRevenueResolver(ctx context.Context) {
+	ticker := time.NewTicker(revenueResolveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.resolveRevenueTransactions(ctx)
+		}
+	}
+}
+
+// --- Creator Reports ---
+
+// creatorReportRow is the revenue of one creator, campaign and currency in a
+// period. Amounts are decimal strings in the currency's major unit; the
+// reporting fields are the same amounts converted to the reporting currency.
+type creatorReportRow struct {
+	Period            string `json:"period"`
+	CreatorKey        string `json:"creatorKey"`
+	CampaignID        string `json:"campaignId"`
+	Currency          string `json:"currency"`
+	Payments          int    `json:"payments"`
+	Refunds           int    `json:"refunds"`
+	LatePayments      int    `json:"latePayments"`
+	LateRefunds       int    `json:"lateRefunds"`
+	Gross             string `json:"gross"`
+	Refunded          string `json:"refunded"`
+	Net               string `json:"net"`
+	ReportingGross    string `json:"reportingGross,omitempty"`
+	ReportingRefunded string `json:"reportingRefunded,omitempty"`
+	ReportingNet      string `json:"reportingNet,omitempty"`
+}
+
+// creatorReportTotal sums report rows in one currency: the converted rows in
+// the reporting currency, or the unconverted rows in their own.
+type creatorReportTotal struct {
+	Currency string `json:"currency"`
+	Payments int    `json:"payments"`
+	Refunds  int    `json:"refunds"`
+	Gross    string `json:"gross"`
+	Refunded string `json:"refunded"`
+	Net      string `json:"net"`
+}
+
+type creatorReport struct {
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	GroupBy string `json:"groupBy"`
+	// ClosedThrough is the last closed day. Payments dated up to it, and
+	// refunds of them, that arrive later are booked as late payments and
+	// late refunds on the day they arrive.
+	ClosedThrough string `json:"closedThrough,omitempty"`
+	// Unresolved counts transactions still waiting to be looked up.
+	Unresolved        int                 `json:"unresolved"`
+	ReportingCurrency string              `json:"reportingCurrency,omitempty"`
+	RatesFile         string              `json:"ratesFile,omitempty"`
+	Total             *creatorReportTotal `json:"total,omitempty"`
+	// Unconverted totals, per currency, the rows that needed a rate the
+	// rates file does not have; MissingRates says which.
+	Unconverted  []creatorReportTotal `json:"unconverted,omitempty"`
+	MissingRates []string             `json:"missingRates,omitempty"`
+	Rows         []creatorReportRow   `json:"rows"`
+}
+
+// creatorReportFilter selects and groups report rows. Empty fields match all.
+type creatorReportFilter struct {
+	isTest     bool
+	from, to   string
+	groupBy    string
+	creatorKey string
+	campaignID string
+	currency   string
+
+	// reportingCurrency, when set, converts each day's amounts at that
+	// day's rates.
+	reportingCurrency string
+	rates             *fxRates
+}
+
+var reportGroupings = []string{"day", "week", "month"}
+
+// periodStart returns the first day of the period containing day. Weeks
+// start on Monday.
+func periodStart(day, groupBy string) string {
+	t, _ := time.Parse(dayLayout, day)
+	switch groupBy {
+	case "week":
+		t = t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	case "month":
+		t = t.AddDate(0, 0, 1-t.Day())
+	}
+	return t.Format(dayLayout)
+}
+
+// reportSum is a report row being summed. unconverted is set once any of
+// its days cannot be converted to the reporting currency.
+type reportSum struct {
+	revenueRow
+	unconverted bool
+}
+
+// report sums the ledger rows that match f into periods. A row with a day
+// that cannot be converted to the reporting currency is left unconverted
+// rather than failing the report.
+func (l *revenueLedger) report(f creatorReportFilter) creatorReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sums := map[revenueRowKey]*reportSum{}
+	missing := map[string]bool{}
+	for k, r := range l.rows {
+		switch {
+		case k.isTest != f.isTest,
+			f.from != "" && k.day < f.from,
+			f.to != "" && k.day > f.to,
+			f.creatorKey != "" && k.creatorKey != f.creatorKey,
+			f.campaignID != "" && k.campaignID != f.campaignID,
+			f.currency != "" && k.currency != f.currency:
+			continue
+		}
+		var gross, refunded fxConversion
+		var convErr error
+		if f.reportingCurrency != "" {
+			gross, convErr = f.rates.convert(money{k.currency, r.gross}, f.reportingCurrency, k.day)
+			if convErr == nil {
+				refunded, convErr = f.rates.convert(money{k.currency, r.refunded}, f.reportingCurrency, k.day)
+			}
+			if convErr != nil {
+				missing[convErr.Error()] = true
+			}
+		}
+
+		k.day = periodStart(k.day, f.groupBy)
+		sum, ok := sums[k]
+		if !ok {
+			sum = &reportSum{}
+			sums[k] = sum
+		}
+		sum.unconverted = sum.unconverted || convErr != nil
+		sum.payments += r.payments
+		sum.refunds += r.refunds
+		sum.latePayments += r.latePayments
+		sum.lateRefunds += r.lateRefunds
+		sum.gross += r.gross
+		sum.refunded += r.refunded
+		sum.convertedGross += gross.Money.Minor
+		sum.convertedRefunded += refunded.Money.Minor
+	}
+
+	report := creatorReport{
+		From:          f.from,
+		To:            f.to,
+		GroupBy:       f.groupBy,
+		ClosedThrough: l.closedThrough,
+		Rows:          []creatorReportRow{},
+	}
+	for tx := range l.pending {
+		if tx.isTest == f.isTest {
+			report.Unresolved++
+		}
+	}
+
+	var total revenueRow
+	unconverted := map[string]*revenueRow{}
+	for k, r := range sums {
+		row := creatorReportRow{
+			Period:       k.day,
+			CreatorKey:   k.creatorKey,
+			CampaignID:   k.campaignID,
+			Currency:     k.currency,
+			Payments:     r.payments,
+			Refunds:      r.refunds,
+			LatePayments: r.latePayments,
+			LateRefunds:  r.lateRefunds,
+			Gross:        money{k.currency, r.gross}.String(),
+			Refunded:     money{k.currency, r.refunded}.String(),
+			Net:          money{k.currency, r.gross - r.refunded}.String(),
+		}
+		switch {
+		case f.reportingCurrency == "":
+		case r.unconverted:
+			u, ok := unconverted[k.currency]
+			if !ok {
+				u = &revenueRow{}
+				unconverted[k.currency] = u
+			}
+			u.payments += r.payments
+			u.refunds += r.refunds
+			u.gross += r.gross
+			u.refunded += r.refunded
+		default:
+			row.ReportingGross = money{f.reportingCurrency, r.convertedGross}.String()
+			row.ReportingRefunded = money{f.reportingCurrency, r.convertedRefunded}.String()
+			row.ReportingNet = money{f.reportingCurrency, r.convertedGross - r.convertedRefunded}.String()
+			total.payments += r.payments
+			total.refunds += r.refunds
+			total.convertedGross += r.convertedGross
+			total.convertedRefunded += r.convertedRefunded
+		}
+		report.Rows = append(report.Rows, row)
+	}
+	if f.reportingCurrency != "" {
+		report.ReportingCurrency = f.reportingCurrency
+		report.RatesFile = f.rates.File
+		report.Total = &creatorReportTotal{
+			Currency: f.reportingCurrency,
+			Payments: total.payments,
+			Refunds:  total.refunds,
+			Gross:    money{f.reportingCurrency, total.convertedGross}.String(),
+			Refunded: money{f.reportingCurrency, total.convertedRefunded}.String(),
+			Net:      money{f.reportingCurrency, total.convertedGross - total.convertedRefunded}.String(),
+		}
+		for currency, u := range unconverted {
+			report.Unconverted = append(report.Unconverted, creatorReportTotal{
+				Currency: currency,
+				Payments: u.payments,
+				Refunds:  u.refunds,
+				Gross:    money{currency, u.gross}.String(),
+				Refunded: money{currency, u.refunded}.String(),
+				Net:      money{currency, u.gross - u.refunded}.String(),
+			})
+		}
+		sort.Slice(report.Unconverted, func(i, j int) bool {
+			return report.Unconverted[i].Currency < report.Unconverted[j].Currency
+		})
+		for msg := range missing {
+			report.MissingRates = append(report.MissingRates, msg)
+		}
+		sort.Strings(report.MissingRates)
+	}
+
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.CreatorKey != b.CreatorKey {
+			return a.CreatorKey < b.CreatorKey
+		}
+		if a.CampaignID != b.CampaignID {
+			return a.CampaignID < b.CampaignID
+		}
+		return a.Currency < b.Currency
+	})
+	return report
+}
+
+// writeCreatorReportCSV writes the report rows as a CSV attachment.
+func writeCreatorReportCSV(w http.ResponseWriter, report creatorReport) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="creator-revenue.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	header := []string{"period", "creatorKey", "campaignId", "currency", "payments", "refunds", "latePayments", "lateRefunds", "gross", "refunded", "net"}
+	if rc := report.ReportingCurrency; rc != "" {
+		header = append(header, "gross"+rc, "refunded"+rc, "net"+rc)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	for _, r := range report.Rows {
+		record := []string{
+			r.Period, r.CreatorKey, r.CampaignID, r.Currency,
+			strconv.Itoa(r.Payments), strconv.Itoa(r.Refunds),
+			strconv.Itoa(r.LatePayments), strconv.Itoa(r.LateRefunds),
+			r.Gross, r.Refunded, r.Net,
+		}
+		if report.ReportingCurrency != "" {
+			record = append(record, r.ReportingGross, r.ReportingRefunded, r.ReportingNet)
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+}
+
+type closeRevenueRequest struct {
+	// Through is the last day to close, inclusive.
+	Through string `json:"through" validate:"required,date"`
+}
+
+// handleCreatorReport handles GET /api/reports/creators
+func (a *app) handleCreatorReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := creatorReportFilter{
+		isTest:            isTestFromQuery(r),
+		from:              q.Get("from"),
+		to:                q.Get("to"),
+		groupBy:           q.Get("groupBy"),
+		creatorKey:        q.Get("creatorKey"),
+		campaignID:        q.Get("campaignId"),
+		currency:          q.Get("currency"),
+		reportingCurrency: a.config().Currency.ReportingCurrency,
+		rates:             a.fxRates(),
+	}
+	for _, d := range []string{f.from, f.to} {
+		if _, err := time.Parse(dayLayout, d); d != "" && err != nil {
+			writeError(w, r, http.StatusBadRequest, "from and to must be dates such as 2026-01-31")
+			return
+		}
+	}
+	if f.groupBy == "" {
+		f.groupBy = "day"
+	}
+	if !slices.Contains(reportGroupings, f.groupBy) {
+		writeError(w, r, http.StatusBadRequest, "groupBy must be day, week or month")
+		return
+	}
+	if q.Has("reportingCurrency") {
+		f.reportingCurrency = q.Get("reportingCurrency")
+	}
+	if f.reportingCurrency != "" && !isCurrencyCode(f.reportingCurrency) {
+		writeError(w, r, http.StatusBadRequest, "reportingCurrency must be an ISO 4217 code")
+		return
+	}
+
+	report := a.revenue.report(f)
+	switch q.Get("format") {
+	case "", "json":
+		writeJSON(w, http.StatusOK, report)
+	case "csv":
+		writeCreatorReportCSV(w, report)
+	default:
+		writeError(w, r, http.StatusBadRequest, "format must be json or csv")
+	}
+}
+
+// handleCloseCreatorReport handles POST /api/reports/creators/close
+func (a *app) handleCloseCreatorReport(w http.ResponseWriter, r *http.Request) {
+	var body closeRevenueRequest
+	if !a.bind(w, r, &body) {
+		return
+	}
+	if body.Through >= time.Now().UTC().Format(dayLayout) {
+		writeError(w, r, http.StatusBadRequest, "only days before today can be closed")
+		return
+	}
+	if err := a.revenue.close(body.Through); err != nil {
+		writeError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if path := a.config().Revenue.StateFile; path != "" {
+		if err := a.revenue.saveState(path); err != nil {
+			a.lifecycle.log.Error("failed to save revenue state", "file", path, "error", err.Error())
+			writeError(w, r, http.StatusInternalServerError, "days closed, but the state file could not be written; they reopen after a restart")
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"closedThrough": body.Through})
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/playcamp/playcamp-go-sdk/webhookutil"
+)
+
+func TestRevenueLedger(t *testing.T) {
+	l := newRevenueLedger()
+	l.now = func() time.Time { return time.Date(2026, 10, 9, 12, 0, 0, 0, time.UTC) }
+	pay := func(id, day, currency string, amount float64) {
+		l.addPayment(revenueTxKey{transactionID: id}, accruedPayment{creatorKey: "neo", campaignID: "c1", currency: currency, amount: moneyFromFloat(amount, currency).Minor, day: day})
+	}
+	pay("t1", "2026-10-05", "USD", 0.1)
+	pay("t2", "2026-10-05", "USD", 0.2)
+	pay("t2", "2026-10-05", "USD", 0.2) // retried delivery
+	pay("t3", "2026-10-06", "KRW", 5900)
+	pay("t4", "2026-10-06", "USD", 9.99)
+	l.addRefund(revenueTxKey{transactionID: "t4"}, "2026-10-07")
+
+	if err := l.close("2026-10-06"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(l.payments); n != 0 {
+		t.Errorf("payments kept after close = %d", n)
+	}
+	// Closing dropped t1, so its refund waits for a lookup, which finds the
+	// closed day: only the refund is booked.
+	l.addRefund(revenueTxKey{transactionID: "t1"}, "2026-10-09")
+	pay("t1", "2026-10-05", "USD", 0.1)
+	// A refund that arrives before its payment waits for it.
+	l.addRefund(revenueTxKey{transactionID: "t5"}, "2026-10-09")
+	pay("t5", "2026-10-08", "USD", 4.99)
+	// A payment for a closed day that arrives after the close is booked today.
+	pay("t6", "2026-10-06", "USD", 1.5)
+	l.addRefund(revenueTxKey{transactionID: "t6"}, "2026-10-09")
+
+	report := l.report(creatorReportFilter{groupBy: "day"})
+	var got []string
+	for _, r := range report.Rows {
+		got = append(got, fmt.Sprintf("%s %s p=%d r=%d late=%d/%d %s-%s=%s", r.Period, r.Currency, r.Payments, r.Refunds, r.LatePayments, r.LateRefunds, r.Gross, r.Refunded, r.Net))
+	}
+	want := []string{
+		"2026-10-05 USD p=2 r=0 late=0/0 0.30-0.00=0.30",
+		"2026-10-06 KRW p=1 r=0 late=0/0 5900-0=5900",
+		"2026-10-06 USD p=1 r=1 late=0/0 9.99-9.99=0.00",
+		"2026-10-08 USD p=1 r=1 late=0/0 4.99-4.99=0.00",
+		"2026-10-09 USD p=1 r=2 late=1/1 1.50-1.60=-0.10",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("rows:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	report = l.report(creatorReportFilter{groupBy: "week", currency: "USD"})
+	week := report.Rows
+	if len(week) != 1 || week[0].Period != "2026-10-05" || week[0].Net != "0.20" || week[0].Payments != 5 {
+		t.Errorf("week = %+v", week)
+	}
+	if err := l.close("2026-10-01"); err == nil {
+		t.Error("reopening closed days succeeded")
+	}
+}
+
+func TestCreatorReportFromWebhooks(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	a := newTestApp(t, fake, nil)
+
+	simulate := func(body string) {
+		t.Helper()
+		if rec := serve(a, http.MethodPost, "/api/webhooks/simulate", body); rec.Code != http.StatusOK {
+			t.Fatalf("simulate: status = %d; body %s", rec.Code, rec.Body)
+		}
+	}
+	simulate(`{"event":"payment.created","data":{"transactionId":"t1","userId":"u1","amount":4.99,"currency":"USD","creatorKey":"neo","campaignId":"c1"}}`)
+	simulate(`{"event":"payment.created","data":{"transactionId":"t1","userId":"u1","amount":4.99,"currency":"USD","creatorKey":"neo","campaignId":"c1"},"duplicate":true}`)
+	simulate(`{"event":"payment.bulk_created","data":{"totalRequested":1,"successful":1,"failed":0,"skipped":0,"transactionIds":["t2"]}}`)
+	simulate(`{"event":"payment.refunded","data":{"transactionId":"t1","userId":"u1"}}`)
+
+	var report creatorReport
+	decodeData(t, serve(a, http.MethodGet, "/api/reports/creators?isTest=true", ""), &report)
+	if report.Unresolved != 1 || len(report.Rows) != 1 || report.Rows[0].Payments != 1 || report.Rows[0].Net != "0.00" {
+		t.Fatalf("report = %+v", report)
+	}
+
+	fake.on(http.MethodGet, "/v1/server/payments/t2", http.StatusOK, `{"data":{"transactionId":"t2","amount":1200,"currency":"KRW","creatorKey":"trinity","campaignId":"c1","purchasedAt":"2026-10-01T09:00:00Z"}}`)
+	a.resolveRevenueTransactions(context.Background())
+
+	rec := serve(a, http.MethodGet, "/api/reports/creators?isTest=true&creatorKey=trinity&format=csv", "")
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+		t.Fatalf("Content-Type = %q; body %s", ct, rec.Body)
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || strings.Join(records[1], ",") != "2026-10-01,trinity,c1,KRW,1,0,0,0,1200,0,1200" {
+		t.Errorf("csv = %q", records)
+	}
+
+	for query, want := range map[string]int{
+		"?groupBy=year":    http.StatusBadRequest,
+		"?from=yesterday":  http.StatusBadRequest,
+		"?format=xml":      http.StatusBadRequest,
+		"?isTest=true":     http.StatusOK,
+		"?groupBy=month":   http.StatusOK,
+		"?to=2026-10-31&x": http.StatusOK,
+	} {
+		if rec := serve(a, http.MethodGet, "/api/reports/creators"+query, ""); rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", query, rec.Code, want)
+		}
+	}
+}
+
+func TestResolveRevenueWithinBudget(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	a := newTestApp(t, fake, nil)
+	for _, id := range []string{"t1", "t2", "t3", "t4"} {
+		fake.on(http.MethodGet, "/v1/server/payments/"+id, http.StatusOK, `{"data":{"transactionId":"`+id+`","amount":1200,"currency":"KRW","creatorKey":"neo","purchasedAt":"2026-10-01T09:00:00Z"}}`)
+		a.revenue.addUnresolved(revenueTxKey{transactionID: id})
+	}
+	cfg := *a.config()
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Upstream = upstreamBudget{Rate: 0.001, Burst: 3, Reserve: 1}
+	a.cfg.Store(&cfg)
+
+	// Two tokens are spendable; the reserve is kept for payments and refunds.
+	a.resolveRevenueTransactions(context.Background())
+	if got, backlog := len(fake.received()), a.revenue.backlog(); got != 2 || backlog != 2 {
+		t.Errorf("lookups = %d, backlog = %d; want 2, 2", got, backlog)
+	}
+}
+
+func TestCreatorReportReportingCurrency(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), map[string]string{
+		"REPORTING_CURRENCY": "USD",
+		"FX_RATES_FILE":      writeTestRates(t),
+	})
+	simulate := func(body string) {
+		t.Helper()
+		if rec := serve(a, http.MethodPost, "/api/webhooks/simulate", body); rec.Code != http.StatusOK {
+			t.Fatalf("simulate: status = %d; body %s", rec.Code, rec.Body)
+		}
+	}
+	simulate(`{"event":"payment.created","data":{"transactionId":"t1","userId":"u1","amount":4.99,"currency":"USD","creatorKey":"neo","campaignId":"c1"}}`)
+	simulate(`{"event":"payment.created","data":{"transactionId":"t2","userId":"u1","amount":7000,"currency":"KRW","creatorKey":"neo","campaignId":"c1"}}`)
+
+	var report creatorReport
+	decodeData(t, serve(a, http.MethodGet, "/api/reports/creators?isTest=true", ""), &report)
+	if report.ReportingCurrency != "USD" || report.Total == nil || report.Total.Payments != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	// Today is past the last rates date, so KRW converts at 1350.5.
+	if report.Total.Gross != "10.17" {
+		t.Errorf("total gross = %s, want 10.17", report.Total.Gross)
+	}
+
+	var plain creatorReport
+	decodeData(t, serve(a, http.MethodGet, "/api/reports/creators?isTest=true&reportingCurrency=", ""), &plain)
+	if plain.Total != nil || plain.Rows[0].ReportingGross != "" {
+		t.Errorf("conversion not disabled: %+v", plain)
+	}
+
+	// Without JPY rates every row stays in its own currency.
+	var jpy creatorReport
+	decodeData(t, serve(a, http.MethodGet, "/api/reports/creators?isTest=true&reportingCurrency=JPY", ""), &jpy)
+	if jpy.Total == nil || jpy.Total.Payments != 0 || len(jpy.Unconverted) != 2 || len(jpy.MissingRates) != 1 || jpy.Rows[0].ReportingGross != "" {
+		t.Errorf("JPY report = %+v", jpy)
+	}
+}
+
+func TestCreatorReportMissingRates(t *testing.T) {
+	rates, err := loadFXRates(writeTestRates(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := newRevenueLedger()
+	pay := func(id, day, currency string, amount float64) {
+		l.addPayment(revenueTxKey{transactionID: id}, accruedPayment{creatorKey: "neo", campaignID: "c1", currency: currency, amount: moneyFromFloat(amount, currency).Minor, day: day})
+	}
+	pay("t1", "2026-09-30", "KRW", 1400) // before the first rates
+	pay("t2", "2026-10-05", "KRW", 2800)
+	pay("t3", "2026-10-05", "USD", 5)
+	pay("t4", "2026-10-09", "USD", 2) // the rates of 2026-10-08 have no EUR
+	pay("t5", "2026-10-06", "USD", 1) // same week as t4
+
+	tests := []struct {
+		name            string
+		groupBy         string
+		wantTotal       string
+		wantUnconverted []string
+		wantMissing     []string
+	}{
+		{"day", "day", "EUR 3 7.20", []string{"KRW 1 1400", "USD 1 2.00"}, []string{
+			"no EUR rate in the rates of 2026-10-08",
+			"no exchange rates on or before 2026-09-30",
+		}},
+		// A week with one unconverted day is left unconverted as a whole.
+		{"week", "week", "EUR 1 1.80", []string{"KRW 1 1400", "USD 3 8.00"}, []string{
+			"no EUR rate in the rates of 2026-10-08",
+			"no exchange rates on or before 2026-09-30",
+		}},
+	}
+	for _, tc := range tests {
+		report := l.report(creatorReportFilter{groupBy: tc.groupBy, reportingCurrency: "EUR", rates: rates})
+		total := fmt.Sprintf("%s %d %s", report.Total.Currency, report.Total.Payments, report.Total.Net)
+		var unconverted []string
+		for _, u := range report.Unconverted {
+			unconverted = append(unconverted, fmt.Sprintf("%s %d %s", u.Currency, u.Payments, u.Net))
+		}
+		if total != tc.wantTotal || !slices.Equal(unconverted, tc.wantUnconverted) || !slices.Equal(report.MissingRates, tc.wantMissing) {
+			t.Errorf("%s: total %q, unconverted %q, missing %q", tc.name, total, unconverted, report.MissingRates)
+		}
+	}
+}
+
+func TestCloseCreatorReport(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"through":"2026-10-01"}`, http.StatusOK},
+		{`{"through":"2026-09-01"}`, http.StatusConflict},
+		{`{"through":"2999-01-01"}`, http.StatusBadRequest},
+		{`{"through":"last week"}`, http.StatusBadRequest},
+	} {
+		if rec := serve(a, http.MethodPost, "/api/reports/creators/close", tc.body); rec.Code != tc.want {
+			t.Errorf("%s: status = %d, want %d; body %s", tc.body, rec.Code, tc.want, rec.Body)
+		}
+	}
+}
+
+func TestRevenueStateFile(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	path := filepath.Join(t.TempDir(), "revenue-state.json")
+	a := newTestApp(t, fake, map[string]string{"REVENUE_STATE_FILE": path})
+	if rec := serve(a, http.MethodPost, "/api/reports/creators/close", `{"through":"2026-10-01"}`); rec.Code != http.StatusOK {
+		t.Fatalf("close: status = %d; body %s", rec.Code, rec.Body)
+	}
+
+	// After a restart the day is still closed, so a payment for it is late.
+	restarted := newTestApp(t, fake, map[string]string{"REVENUE_STATE_FILE": path})
+	restarted.revenue.addPayment(revenueTxKey{isTest: true, transactionID: "t1"}, accruedPayment{creatorKey: "neo", currency: "USD", amount: 499, day: "2026-10-01"})
+	report := restarted.revenue.report(creatorReportFilter{isTest: true, groupBy: "day"})
+	if report.ClosedThrough != "2026-10-01" || len(report.Rows) != 1 || report.Rows[0].LatePayments != 1 {
+		t.Errorf("report = %+v", report)
+	}
+
+	os.WriteFile(path, []byte(`{"closedThrough":"October"}`), 0o644)
+	cfg := *restarted.config()
+	if _, err := newApp(&cfg, "", newLogRegistry(io.Discard)); err == nil || !strings.Contains(err.Error(), "load revenue state") {
+		t.Errorf("newApp = %v, want state file error", err)
+	}
+}
+
+func TestRevenueWebhooksWithoutSecret(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), map[string]string{"WEBHOOK_SECRET": ""})
+	payload := []byte(`{"events":[{"event":"payment.created","timestamp":"2026-10-05T09:00:00Z","isTest":true,"data":{"transactionId":"t1","userId":"u1","amount":4.99,"currency":"USD","creatorKey":"neo","campaignId":"c1"}}]}`)
+
+	// An empty key is as good as no key: anyone can compute the signature.
+	for name, signature := range map[string]string{
+		"unsigned":  "",
+		"empty key": webhookutil.ConstructSignature(payload, "", nil),
+	} {
+		req := httptest.NewRequest(http.MethodPost, receiverPath, bytes.NewReader(payload))
+		if signature != "" {
+			req.Header.Set("X-Webhook-Signature", signature)
+		}
+		rec := httptest.NewRecorder()
+		a.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", name, rec.Code)
+		}
+	}
+	if n := len(a.receivedWebhooks.list()); n != 2 {
+		t.Errorf("stored = %d webhooks, want 2", n)
+	}
+	if report := a.revenue.report(creatorReportFilter{isTest: true, groupBy: "day"}); len(report.Rows) != 0 {
+		t.Errorf("rows = %+v", report.Rows)
+	}
+}
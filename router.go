@@ -0,0 +1,254 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// routeDoc describes a route. It is attached when the route is registered
+// and drives the OpenAPI document.
+type routeDoc struct {
+	Method  string
+	Pattern string
+	Summary string
+	Tag     string
+	// Secured routes sit behind client certificate and API key checks.
+	Secured bool
+
+	Query []queryParam
+	// Request is a zero value of the JSON body type, or nil.
+	Request any
+	// Response is a zero value of the type in the "data" envelope, or nil.
+	Response any
+	// Status is the success status code; 0 means 200.
+	Status int
+	// Header names a required request header, e.g. the webhook signature.
+	Header string
+	// Local routes do not call PlayCamp and so don't spend the upstream
+	// budget.
+	Local bool
+}
+
+type queryParam struct {
+	Name        string
+	Type        string // "string", "integer" or "boolean"
+	Description string
+}
+
+var (
+	testQuery   = queryParam{"isTest", "boolean", "Use the test-mode SDK instance"}
+	pageQueries = []queryParam{
+		{"page", "integer", "Page number (default 1)"},
+		{"limit", "integer", "Page size (default 20)"},
+		testQuery,
+	}
+)
+
+// routeTable holds the docs of every registered route, keyed by
+// "METHOD pattern", and remembers the order routes were registered in.
+type routeTable struct {
+	docs  map[string]routeDoc
+	order []string
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{docs: map[string]routeDoc{}}
+}
+
+func (t *routeTable) lookup(method, pattern string) (routeDoc, bool) {
+	doc, ok := t.docs[method+" "+pattern]
+	return doc, ok
+}
+
+// docRouter registers routes on a chi router and records their docs.
+type docRouter struct {
+	chi.Router
+	table   *routeTable
+	secured bool
+}
+
+func (d docRouter) handle(method, pattern string, h http.HandlerFunc, doc routeDoc) {
+	doc.Method, doc.Pattern, doc.Secured = method, pattern, d.secured
+	d.Method(method, pattern, h)
+	d.table.docs[method+" "+pattern] = doc
+	d.table.order = append(d.table.order, method+" "+pattern)
+}
+
+func (d docRouter) get(pattern string, h http.HandlerFunc, doc routeDoc) {
+	d.handle(http.MethodGet, pattern, h, doc)
+}
+
+func (d docRouter) post(pattern string, h http.HandlerFunc, doc routeDoc) {
+	d.handle(http.MethodPost, pattern, h, doc)
+}
+
+func (d docRouter) put(pattern string, h http.HandlerFunc, doc routeDoc) {
+	d.handle(http.MethodPut, pattern, h, doc)
+}
+
+func (d docRouter) delete(pattern string, h http.HandlerFunc, doc routeDoc) {
+	d.handle(http.MethodDelete, pattern, h, doc)
+}
+
+// newRouter builds the HTTP handler with every route and its docs.
+func (a *app) newRouter() chi.Router {
+	httpLog := a.logs.logger(logHTTP)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(requestIDHeader)
+	r.Use(trackRequestState)
+	r.Use(requestLogger(httpLog))
+	r.Use(recoverer(httpLog))
+	r.Use(a.corsMiddleware)
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, http.StatusNotFound, "no route for "+r.Method+" "+r.URL.Path)
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, http.StatusMethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+	})
+
+	public := docRouter{Router: r, table: a.routes}
+
+	// --- Health ---
+	public.get("/healthz", a.handleHealthz, routeDoc{Tag: "Health", Summary: "Liveness probe", Response: map[string]string{}})
+	public.get("/readyz", a.handleReadyz, routeDoc{Tag: "Health", Summary: "Readiness probe", Response: map[string]any{}})
+	public.get("/status", a.handleStatus, routeDoc{Tag: "Health", Summary: "Effective configuration and version", Response: map[string]any{}})
+
+	// --- API description ---
+	public.get("/openapi.json", a.handleOpenAPI, routeDoc{Tag: "Docs", Summary: "OpenAPI document"})
+	public.get("/docs", handleAPIDocs, routeDoc{Tag: "Docs", Summary: "API reference viewer"})
+
+	// API routes require a client certificate when tls.clientCAFile is set,
+	// and an API client key when auth.clients is configured.
+	r.Group(func(r chi.Router) {
+		r.Use(a.requireClientCert)
+		r.Use(a.authenticate)
+		r.Use(a.rateLimit)
+		api := docRouter{Router: r, table: a.routes, secured: true}
+
+		api.get("/api/routes", a.handleListRoutes, routeDoc{Local: true, Tag: "Docs", Summary: "List routes", Response: []routeInfo{}})
+
+		// --- Campaigns ---
+		api.get("/api/campaigns", a.handleListCampaigns, routeDoc{Tag: "Campaigns", Summary: "List campaigns", Query: pageQueries, Response: playcamp.PageResult[playcamp.Campaign]{}})
+		api.get("/api/campaigns/{id}", a.handleGetCampaign, routeDoc{Tag: "Campaigns", Summary: "Get campaign", Query: []queryParam{testQuery}, Response: playcamp.Campaign{}})
+		api.get("/api/campaigns/{id}/creators", a.handleGetCampaignCreators, routeDoc{Tag: "Campaigns", Summary: "Get campaign creators", Query: []queryParam{testQuery}, Response: []playcamp.Creator{}})
+
+		// --- Creators (literal path before parameterized) ---
+		api.get("/api/creators/search", a.handleSearchCreators, routeDoc{Tag: "Creators", Summary: "Search creators", Query: []queryParam{
+			{"keyword", "string", "Search keyword"},
+			{"campaignId", "string", "Restrict to a campaign"},
+			{"limit", "integer", "Maximum number of results"},
+			testQuery,
+		}, Response: []playcamp.Creator{}})
+		api.get("/api/creators/{key}", a.handleGetCreator, routeDoc{Tag: "Creators", Summary: "Get creator", Query: []queryParam{testQuery}, Response: playcamp.Creator{}})
+		api.get("/api/creators/{key}/coupons", a.handleGetCreatorCoupons, routeDoc{Tag: "Creators", Summary: "Get creator coupons", Query: []queryParam{testQuery}, Response: []playcamp.CreatorCoupon{}})
+
+		// --- Coupons ---
+		api.post("/api/coupons/validate", a.handleValidateCoupon, routeDoc{Tag: "Coupons", Summary: "Validate coupon", Request: validateCouponRequest{}, Response: playcamp.CouponValidation{}})
+		api.post("/api/coupons/redeem", a.handleRedeemCoupon, routeDoc{Tag: "Coupons", Summary: "Redeem coupon", Request: redeemCouponRequest{}, Response: playcamp.RedeemResult{}})
+		api.get("/api/coupons/user/{userId}", a.handleGetCouponHistory, routeDoc{Tag: "Coupons", Summary: "Get coupon history", Query: pageQueries, Response: playcamp.PageResult[playcamp.CouponUsage]{}})
+		api.get("/api/coupons/lockouts", a.handleListCouponLockouts, routeDoc{Local: true, Tag: "Coupons", Summary: "List coupon lockouts", Query: []queryParam{{"locked", "boolean", "Only keys that are currently locked out"}}, Response: []lockoutView{}})
+		api.delete("/api/coupons/lockouts", a.handleClearCouponLockouts, routeDoc{Local: true, Tag: "Coupons", Summary: "Clear all coupon lockouts", Response: map[string]bool{}})
+		api.get("/api/coupons/lockouts/{key}", a.handleGetCouponLockout, routeDoc{Local: true, Tag: "Coupons", Summary: "Get coupon lockout", Response: lockoutView{}})
+		api.delete("/api/coupons/lockouts/{key}", a.handleClearCouponLockout, routeDoc{Local: true, Tag: "Coupons", Summary: "Clear coupon lockout", Response: map[string]bool{}})
+
+		// --- Sponsors ---
+		api.post("/api/sponsors", a.handleCreateSponsor, routeDoc{Tag: "Sponsors", Summary: "Create sponsor", Request: createSponsorRequest{}, Response: playcamp.Sponsor{}, Status: http.StatusCreated})
+		api.get("/api/sponsors/{userId}", a.handleGetSponsor, routeDoc{Tag: "Sponsors", Summary: "Get sponsor", Query: []queryParam{testQuery}, Response: []playcamp.Sponsor{}})
+		api.put("/api/sponsors/{userId}", a.handleUpdateSponsor, routeDoc{Tag: "Sponsors", Summary: "Update sponsor", Request: updateSponsorRequest{}, Response: playcamp.Sponsor{}})
+		api.delete("/api/sponsors/{userId}", a.handleDeleteSponsor, routeDoc{Tag: "Sponsors", Summary: "Delete sponsor", Query: []queryParam{
+			{"campaignId", "string", "Campaign to end the sponsorship in"},
+			{"callbackId", "string", "Callback ID echoed in webhooks"},
+			testQuery,
+		}, Response: map[string]bool{}})
+		api.get("/api/sponsors/{userId}/history", a.handleGetSponsorHistory, routeDoc{Tag: "Sponsors", Summary: "Get sponsor history", Query: append([]queryParam{{"campaignId", "string", "Restrict to a campaign"}}, pageQueries...), Response: playcamp.PageResult[playcamp.SponsorHistory]{}})
+		api.get("/api/sponsors/{userId}/state", a.handleGetSponsorState, routeDoc{Local: true, Tag: "Sponsors", Summary: "Get sponsor state from the local view", Query: []queryParam{
+			{"campaignId", "string", "Restrict to a campaign"}, testQuery,
+		}, Response: userSponsorState{}})
+		api.post("/api/sponsors/{userId}/state/reconcile", a.handleReconcileSponsorState, routeDoc{Tag: "Sponsors", Summary: "Reconcile sponsor state with PlayCamp", Query: []queryParam{testQuery}, Response: sponsorReconcileResult{}})
+
+		// --- Payments (literal path before parameterized) ---
+		api.post("/api/payments", a.handleCreatePayment, routeDoc{Tag: "Payments", Summary: "Create payment", Request: createPaymentRequest{}, Response: playcamp.Payment{}, Status: http.StatusCreated})
+		api.post("/api/payments/bulk", a.handleCreateBulkPayment, routeDoc{Tag: "Payments", Summary: "Create bulk payments", Request: createBulkPaymentRequest{}, Response: bulkPaymentResult{}, Status: http.StatusCreated})
+		api.get("/api/payments/quarantine", a.handleListQuarantine, routeDoc{Local: true, Tag: "Payments", Summary: "List payments quarantined by receipt verification", Query: []queryParam{testQuery}, Response: []quarantinedPayment{}})
+		api.post("/api/payments/quarantine/{transactionId}/retry", a.handleRetryQuarantined, routeDoc{Tag: "Payments", Summary: "Verify a quarantined payment again and report it", Query: []queryParam{testQuery}, Response: playcamp.Payment{}, Status: http.StatusCreated})
+		api.delete("/api/payments/quarantine/{transactionId}", a.handleDeleteQuarantined, routeDoc{Local: true, Tag: "Payments", Summary: "Discard a quarantined payment", Query: []queryParam{testQuery}, Response: map[string]bool{}})
+		api.get("/api/payments/user/{userId}", a.handleGetUserPayments, routeDoc{Tag: "Payments", Summary: "Get user payments", Query: pageQueries, Response: playcamp.PageResult[playcamp.Payment]{}})
+		api.get("/api/payments/{transactionId}", a.handleGetPayment, routeDoc{Tag: "Payments", Summary: "Get payment", Query: []queryParam{testQuery}, Response: playcamp.Payment{}})
+		api.post("/api/payments/{transactionId}/refund", a.handleRefundPayment, routeDoc{Tag: "Payments", Summary: "Refund payment", Request: refundPaymentRequest{}, Response: playcamp.Payment{}})
+
+		// --- Webhooks (literal paths before parameterized) ---
+		api.get("/api/webhooks", a.handleListWebhooks, routeDoc{Tag: "Webhooks", Summary: "List webhooks", Response: []playcamp.Webhook{}})
+		api.post("/api/webhooks", a.handleCreateWebhook, routeDoc{Tag: "Webhooks", Summary: "Create webhook", Request: createWebhookRequest{}, Response: playcamp.WebhookWithSecret{}, Status: http.StatusCreated})
+		api.get("/api/webhooks/received", a.handleGetReceivedWebhooks, routeDoc{Local: true, Tag: "Webhook Receiver", Summary: "Get received webhooks", Response: []receivedWebhook{}})
+		api.delete("/api/webhooks/received", a.handleClearReceivedWebhooks, routeDoc{Local: true, Tag: "Webhook Receiver", Summary: "Clear received webhooks", Response: map[string]bool{}})
+		api.get("/api/webhooks/analytics", a.handleWebhookAnalytics, routeDoc{Tag: "Webhooks", Summary: "Delivery analytics across subscriptions", Query: []queryParam{
+			{"since", "string", "RFC 3339 start time (default: 24 hours ago)"},
+			{"bucket", "string", "Time bucket size, e.g. 15m (default: 1h)"},
+		}, Response: webhookAnalytics{}})
+		api.post("/api/webhooks/sync", a.handleSyncWebhooks, routeDoc{Tag: "Webhooks", Summary: "Sync webhooks to a desired state", Query: []queryParam{
+			{"dryRun", "boolean", "Return the plan without applying it"},
+		}, Request: webhookSyncRequest{}, Response: webhookSyncResult{}})
+		api.post("/api/webhooks/simulate", a.handleSimulateWebhook, routeDoc{Local: true, Tag: "Webhook Receiver", Summary: "Simulate a signed webhook delivery", Request: simulateWebhookRequest{}, Response: simulateWebhookResult{}})
+		api.get("/api/webhooks/templates", a.handleListWebhookTemplates, routeDoc{Local: true, Tag: "Webhook Receiver", Summary: "List webhook payload templates", Query: []queryParam{
+			{"seed", "integer", "Seed for the generated examples"},
+		}, Response: []webhookTemplateInfo{}})
+		api.get("/api/webhooks/scenarios", a.handleListScenarios, routeDoc{Local: true, Tag: "Webhook Receiver", Summary: "List built-in webhook scenarios", Response: []webhookScenario{}})
+		api.post("/api/webhooks/scenarios/run", a.handleRunScenario, routeDoc{Local: true, Tag: "Webhook Receiver", Summary: "Run a webhook scenario", Request: runScenarioRequest{}, Response: scenarioRunResult{}})
+		api.get("/api/webhooks/{id}", func(w http.ResponseWriter, r *http.Request) {
+			// Not a standard endpoint, but route exists for completeness.
+			writeError(w, r, http.StatusNotFound, "use /api/webhooks/:id/logs or /api/webhooks/:id/test")
+		}, routeDoc{Local: true, Tag: "Webhooks", Summary: "Not supported; use logs or test"})
+		api.put("/api/webhooks/{id}", a.handleUpdateWebhook, routeDoc{Tag: "Webhooks", Summary: "Update webhook", Request: updateWebhookRequest{}, Response: playcamp.Webhook{}})
+		api.delete("/api/webhooks/{id}", a.handleDeleteWebhook, routeDoc{Tag: "Webhooks", Summary: "Delete webhook", Response: map[string]bool{}})
+		api.get("/api/webhooks/{id}/logs", a.handleGetWebhookLogs, routeDoc{Tag: "Webhooks", Summary: "Get webhook logs", Response: []playcamp.WebhookLog{}})
+		api.post("/api/webhooks/{id}/test", a.handleTestWebhook, routeDoc{Tag: "Webhooks", Summary: "Test webhook", Response: playcamp.WebhookTestResult{}})
+
+		// --- Request Capture ---
+		api.get("/api/hooks", a.handleListCaptureBuckets, routeDoc{Local: true, Tag: "Request Capture", Summary: "List capture buckets", Response: []captureBucketInfo{}})
+		api.get("/api/hooks/{bucket}", a.handleGetCaptures, routeDoc{Local: true, Tag: "Request Capture", Summary: "Get captured requests", Response: []capturedRequest{}})
+		api.delete("/api/hooks/{bucket}", a.handleClearCaptures, routeDoc{Local: true, Tag: "Request Capture", Summary: "Clear capture bucket", Response: map[string]bool{}})
+
+		// --- Reports ---
+		api.get("/api/reports/creators", a.handleCreatorReport, routeDoc{Local: true, Tag: "Reports", Summary: "Creator revenue report", Query: []queryParam{
+			{"from", "string", "First day, e.g. 2026-01-01"},
+			{"to", "string", "Last day, inclusive"},
+			{"groupBy", "string", "day, week or month (default: day)"},
+			{"creatorKey", "string", "Restrict to a creator"},
+			{"campaignId", "string", "Restrict to a campaign"},
+			{"currency", "string", "Restrict to a currency"},
+			{"format", "string", "json or csv (default: json)"},
+			{"reportingCurrency", "string", "Also convert to this currency (default: configured; empty disables)"},
+			testQuery,
+		}, Response: creatorReport{}})
+		api.post("/api/reports/creators/close", a.handleCloseCreatorReport, routeDoc{Local: true, Tag: "Reports", Summary: "Close creator revenue through a day", Request: closeRevenueRequest{}, Response: map[string]string{}})
+		api.get("/api/fx/convert", a.handleFXConvert, routeDoc{Local: true, Tag: "Reports", Summary: "Convert an amount at the configured exchange rates", Query: []queryParam{
+			{"amount", "string", "Decimal amount, e.g. 4.99"},
+			{"from", "string", "ISO 4217 currency of amount"},
+			{"to", "string", "Target currency (default: the reporting currency)"},
+			{"date", "string", "Day whose rates apply (default: today)"},
+		}, Response: fxConvertResult{}})
+	})
+
+	// --- WebView ---
+	webview := docRouter{Router: r.With(a.authenticate, a.rateLimit), table: a.routes, secured: true}
+	webview.post("/webview/token", a.handleWebviewToken, routeDoc{Tag: "WebView", Summary: "Create WebView OTT token", Request: webviewTokenRequest{}, Response: playcamp.WebviewOttResult{}})
+
+	// --- Webhook Receiver ---
+	public.post(receiverPath, a.handleWebhookReceiver, routeDoc{Tag: "Webhook Receiver", Summary: "Receive webhooks", Request: playcamp.WebhookPayload{}, Response: map[string]any{}, Header: "X-Webhook-Signature"})
+
+	// --- Request Capture ---
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		public.handle(method, "/hooks/{bucket}", a.handleCapture, routeDoc{Tag: "Request Capture", Summary: "Capture " + method + " request into a bucket", Response: map[string]string{}, Header: "X-Webhook-Signature"})
+	}
+
+	// --- Static files ---
+	fileServer := http.FileServer(http.Dir("public"))
+	r.Handle("/*", fileServer)
+
+	return r
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// The startup banner, the README endpoint table and GET /api/routes are all
+// rendered from the mounted chi routes and the routeDoc attached to each, so
+// the listings cannot drift from what the server actually serves.
+
+// README markers around the generated endpoint table.
+const (
+	readmeRoutesBegin = "<!-- routes:begin -->"
+	readmeRoutesEnd   = "<!-- routes:end -->"
+)
+
+// routeInfo is the JSON form of a mounted route.
+type routeInfo struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Tag     string `json:"tag"`
+	Summary string `json:"summary"`
+	Secured bool   `json:"secured"`
+}
+
+// listRoutes walks router and returns the docs of every mounted route in
+// registration order. Routes registered without docs are listed last; the
+// static file catch-all is skipped.
+func listRoutes(router chi.Routes, table *routeTable) ([]routeDoc, error) {
+	position := make(map[string]int, len(table.order))
+	for i, key := range table.order {
+		position[key] = i
+	}
+
+	var docs []routeDoc
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route == "/*" {
+			return nil
+		}
+		doc, ok := table.lookup(method, route)
+		if !ok {
+			doc = routeDoc{Method: method, Pattern: route, Tag: "Other"}
+		}
+		docs = append(docs, doc)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(docs, func(i, j int) bool {
+		pi, iok := position[docs[i].Method+" "+docs[i].Pattern]
+		pj, jok := position[docs[j].Method+" "+docs[j].Pattern]
+		if iok != jok {
+			return iok
+		}
+		if !iok {
+			return docs[i].Pattern < docs[j].Pattern
+		}
+		return pi < pj
+	})
+	return docs, nil
+}
+
+// displayPath writes chi parameters the way the docs do, e.g. {id} as :id.
+func displayPath(pattern string) string {
+	return pathParamPattern.ReplaceAllString(pattern, ":$1")
+}
+
+// groupByTag splits docs by tag, keeping the order tags first appear in.
+func groupByTag(docs []routeDoc) (tags []string, groups map[string][]routeDoc) {
+	groups = map[string][]routeDoc{}
+	for _, d := range docs {
+		if _, ok := groups[d.Tag]; !ok {
+			tags = append(tags, d.Tag)
+		}
+		groups[d.Tag] = append(groups[d.Tag], d)
+	}
+	return tags, groups
+}
+
+// writeRouteBanner writes the endpoint listing shown at startup.
+func writeRouteBanner(w io.Writer, docs []routeDoc) {
+	width := 0
+	for _, d := range docs {
+		width = max(width, len(displayPath(d.Pattern)))
+	}
+
+	fmt.Fprintln(w, "API Endpoints:")
+	tags, groups := groupByTag(docs)
+	for _, tag := range tags {
+		fmt.Fprintf(w, "\n[%s]\n", tag)
+		for _, d := range groups[tag] {
+			fmt.Fprintf(w, "   %-6s %-*s - %s\n", d.Method, width, displayPath(d.Pattern), d.Summary)
+		}
+	}
+}
+
+// writeRouteTable writes the README endpoint table.
+func writeRouteTable(w io.Writer, docs []routeDoc) {
+	fmt.Fprintln(w, "| Method | Path | Description |")
+	fmt.Fprintln(w, "|--------|------|-------------|")
+	for _, d := range docs {
+		fmt.Fprintf(w, "| %s | %s | %s |\n", d.Method, displayPath(d.Pattern), d.Summary)
+	}
+}
+
+// replaceBetween replaces the text between the begin and end markers in doc.
+func replaceBetween(doc []byte, begin, end string, content []byte) ([]byte, error) {
+	i := bytes.Index(doc, []byte(begin))
+	j := bytes.Index(doc, []byte(end))
+	if i < 0 || j < i {
+		return nil, fmt.Errorf("markers %s and %s not found", begin, end)
+	}
+	var out bytes.Buffer
+	out.Write(doc[:i+len(begin)])
+	out.WriteByte('\n')
+	out.Write(content)
+	out.Write(doc[j:])
+	return out.Bytes(), nil
+}
+
+// docsApp returns an app that can build the router without any SDK or
+// configuration, for rendering route listings offline.
+func docsApp() *app {
+	return &app{logs: newLogRegistry(io.Discard), routes: newRouteTable()}
+}
+
+// runRoutesCommand implements `go run . routes [-readme FILE]`: it prints the
+// endpoint table, or rewrites it in FILE between the routes markers.
+func runRoutesCommand(args []string) int {
+	fs := flag.NewFlagSet("routes", flag.ContinueOnError)
+	readme := fs.String("readme", "", "rewrite the endpoint table in this Markdown file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	a := docsApp()
+	docs, err := listRoutes(a.newRouter(), a.routes)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "routes:", err)
+		return 1
+	}
+
+	var table bytes.Buffer
+	writeRouteTable(&table, docs)
+	if *readme == "" {
+		os.Stdout.Write(table.Bytes())
+		return 0
+	}
+
+	doc, err := os.ReadFile(*readme)
+	if err == nil {
+		doc, err = replaceBetween(doc, readmeRoutesBegin, readmeRoutesEnd, table.Bytes())
+	}
+	if err == nil {
+		err = os.WriteFile(*readme, doc, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "routes:", err)
+		return 1
+	}
+	return 0
+}
+
+// --- Handlers ---
+
+// handleListRoutes handles GET /api/routes
+func (a *app) handleListRoutes(w http.ResponseWriter, r *http.Request) {
+	docs, err := listRoutes(a.router, a.routes)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "failed to list routes")
+		return
+	}
+	routes := make([]routeInfo, 0, len(docs))
+	for _, d := range docs {
+		routes = append(routes, routeInfo{
+			Method:  d.Method,
+			Path:    d.Pattern,
+			Tag:     d.Tag,
+			Summary: d.Summary,
+			Secured: d.Secured,
+		})
+	}
+	writeJSON(w, http.StatusOK, routes)
+}
+
+// routeBanner renders the startup endpoint listing.
+func (a *app) routeBanner() string {
+	docs, err := listRoutes(a.router, a.routes)
+	if err != nil {
+		return "API Endpoints: unavailable (" + err.Error() + ")\n"
+	}
+	var b strings.Builder
+	writeRouteBanner(&b, docs)
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// TestReadmeRoutes fails when the README endpoint table is out of date.
+func TestReadmeRoutes(t *testing.T) {
+	a := docsApp()
+	docs, err := listRoutes(a.newRouter(), a.routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var table bytes.Buffer
+	writeRouteTable(&table, docs)
+
+	readme, err := os.ReadFile("README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := replaceBetween(readme, readmeRoutesBegin, readmeRoutesEnd, table.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(readme, want) {
+		t.Error("README endpoint table is stale; run: go run . routes -readme README.md")
+	}
+}
+
+func TestEveryRouteDocumented(t *testing.T) {
+	a := docsApp()
+	docs, err := listRoutes(a.newRouter(), a.routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range docs {
+		if d.Summary == "" || d.Tag == "Other" {
+			t.Errorf("%s %s is registered without a routeDoc", d.Method, d.Pattern)
+		}
+	}
+}
+
+// TestRouteOperationIDs fails when two routes would share an OpenAPI
+// operationId, which makes the document invalid.
+func TestRouteOperationIDs(t *testing.T) {
+	a := docsApp()
+	docs, err := listRoutes(a.newRouter(), a.routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]string{}
+	for _, d := range docs {
+		id := operationID(d.Summary)
+		if prev, ok := seen[id]; ok {
+			t.Errorf("%s %s and %s share operationId %s", d.Method, d.Pattern, prev, id)
+		}
+		seen[id] = d.Method + " " + d.Pattern
+	}
+}
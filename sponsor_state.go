@@ -0,0 +1,519 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// The sponsor state view answers "who does this user sponsor?" without an
+// upstream call. It is built from sponsor.created, sponsor.changed and
+// sponsor.ended webhooks and periodically reconciled with Sponsors.GetByUser,
+// which corrects anything a missed or reordered webhook got wrong. Live and
+// test mode are tracked separately, as PlayCamp keeps them apart. The view
+// is bounded: the least recently used users are evicted beyond
+// sponsorState.maxUsers, and idle users expire.
+
+const (
+	sponsorSourceWebhook   = "webhook"
+	sponsorSourceReconcile = "reconcile"
+
+	// sponsorActionReconciled records a correction made by reconciliation.
+	sponsorActionReconciled playcamp.SponsorAction = "RECONCILED"
+
+	// maxSponsorHistory bounds the changes kept per user and campaign.
+	maxSponsorHistory = 50
+)
+
+// sponsorState is a user's sponsorship in one campaign.
+type sponsorState struct {
+	UserID      string  `json:"userId"`
+	CampaignID  string  `json:"campaignId"`
+	CreatorKey  string  `json:"creatorKey"`
+	IsActive    bool    `json:"isActive"`
+	SponsoredAt string  `json:"sponsoredAt,omitempty"`
+	EndedAt     *string `json:"endedAt,omitempty"`
+	// UpdatedAt is the time of the latest event or reconciliation applied.
+	UpdatedAt string          `json:"updatedAt"`
+	Source    string          `json:"source"`
+	History   []sponsorChange `json:"history"`
+
+	asOf time.Time
+}
+
+// sponsorChange is one entry in a sponsorship's change history.
+type sponsorChange struct {
+	Action             playcamp.SponsorAction `json:"action"`
+	CreatorKey         string                 `json:"creatorKey"`
+	PreviousCreatorKey string                 `json:"previousCreatorKey,omitempty"`
+	At                 string                 `json:"at"`
+	Source             string                 `json:"source"`
+	WebhookID          string                 `json:"webhookId,omitempty"`
+}
+
+// userSponsorState is everything the view knows about one user.
+type userSponsorState struct {
+	UserID   string         `json:"userId"`
+	IsTest   bool           `json:"isTest"`
+	Sponsors []sponsorState `json:"sponsors"`
+	// ReconciledAt is when the user was last checked against PlayCamp.
+	ReconciledAt *string `json:"reconciledAt,omitempty"`
+}
+
+// sponsorReconcileResult is the outcome of reconciling one user.
+type sponsorReconcileResult struct {
+	State userSponsorState `json:"state"`
+	Drift []sponsorDrift   `json:"drift"`
+}
+
+// sponsorDrift is a difference reconciliation found and corrected.
+type sponsorDrift struct {
+	CampaignID string        `json:"campaignId"`
+	Local      *sponsorState `json:"local"`
+	Upstream   *sponsorState `json:"upstream"`
+}
+
+type sponsorUserKey struct {
+	isTest bool
+	userID string
+}
+
+type sponsorUser struct {
+	campaigns    map[string]*sponsorState
+	reconciledAt time.Time
+	// usedAt is the last lookup or event; reconciliation does not count.
+	usedAt time.Time
+	elem   *list.Element
+}
+
+// sponsorView is the thread-safe sponsor state of up to maxUsers users.
+type sponsorView struct {
+	now func() time.Time
+
+	mu       sync.Mutex
+	users    map[sponsorUserKey]*sponsorUser
+	lru      *list.List // of sponsorUserKey, most recently used first
+	maxUsers int
+}
+
+func newSponsorView(maxUsers int) *sponsorView {
+	return &sponsorView{now: time.Now, users: map[sponsorUserKey]*sponsorUser{}, lru: list.New(), maxUsers: maxUsers}
+}
+
+// user returns the record for key, creating it and evicting the least
+// recently used user if needed. The caller holds the lock.
+func (v *sponsorView) user(key sponsorUserKey) *sponsorUser {
+	u, ok := v.users[key]
+	if !ok {
+		u = &sponsorUser{campaigns: map[string]*sponsorState{}, usedAt: v.now(), elem: v.lru.PushFront(key)}
+		v.users[key] = u
+		v.evict()
+	}
+	return u
+}
+
+// touch marks u as just used. The caller holds the lock.
+func (v *sponsorView) touch(u *sponsorUser) {
+	u.usedAt = v.now()
+	v.lru.MoveToFront(u.elem)
+}
+
+// evict drops least recently used users beyond maxUsers. The caller holds
+// the lock.
+func (v *sponsorView) evict() {
+	for v.lru.Len() > v.maxUsers {
+		v.remove(v.lru.Back().Value.(sponsorUserKey))
+	}
+}
+
+// remove forgets key. The caller holds the lock.
+func (v *sponsorView) remove(key sponsorUserKey) {
+	if u, ok := v.users[key]; ok {
+		v.lru.Remove(u.elem)
+		delete(v.users, key)
+	}
+}
+
+// resize changes how many users are kept, evicting any excess.
+func (v *sponsorView) resize(maxUsers int) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.maxUsers = maxUsers
+	v.evict()
+}
+
+// expire drops users not used since before and returns how many.
+func (v *sponsorView) expire(before time.Time) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	n := 0
+	for e := v.lru.Back(); e != nil; {
+		key := e.Value.(sponsorUserKey)
+		e = e.Prev()
+		if !v.users[key].usedAt.Before(before) {
+			break
+		}
+		v.remove(key)
+		n++
+	}
+	return n
+}
+
+// len returns the number of tracked users.
+func (v *sponsorView) len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.users)
+}
+
+// applyEvent updates the view from one sponsor event. Events older than what
+// the view already reflects are ignored, so a late delivery cannot undo a
+// newer change. It reports whether the event was applied.
+func (v *sponsorView) applyEvent(evt webhookEvent, webhookID string) bool {
+	var data struct {
+		UserID        string `json:"userId"`
+		CampaignID    string `json:"campaignId"`
+		CreatorKey    string `json:"creatorKey"`
+		OldCreatorKey string `json:"oldCreatorKey"`
+		NewCreatorKey string `json:"newCreatorKey"`
+	}
+	if err := json.Unmarshal(evt.Data, &data); err != nil || data.UserID == "" {
+		return false
+	}
+	at, err := time.Parse(time.RFC3339, evt.Timestamp)
+	if err != nil {
+		at = time.Now()
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	u := v.user(sponsorUserKey{isTest: evt.IsTest != nil && *evt.IsTest, userID: data.UserID})
+	v.touch(u)
+	s, ok := u.campaigns[data.CampaignID]
+	if !ok {
+		s = &sponsorState{UserID: data.UserID, CampaignID: data.CampaignID}
+		u.campaigns[data.CampaignID] = s
+	} else if at.Before(s.asOf) {
+		return false
+	}
+
+	change := sponsorChange{At: at.UTC().Format(time.RFC3339), Source: sponsorSourceWebhook, WebhookID: webhookID}
+	switch playcamp.WebhookEventType(evt.Event) {
+	case playcamp.WebhookEventSponsorCreated:
+		change.Action = playcamp.SponsorActionCreated
+		s.CreatorKey, s.IsActive, s.SponsoredAt, s.EndedAt = data.CreatorKey, true, change.At, nil
+	case playcamp.WebhookEventSponsorChanged:
+		change.Action = playcamp.SponsorActionChanged
+		change.PreviousCreatorKey = data.OldCreatorKey
+		s.CreatorKey, s.IsActive, s.EndedAt = data.NewCreatorKey, true, nil
+		if s.SponsoredAt == "" {
+			s.SponsoredAt = change.At
+		}
+	case playcamp.WebhookEventSponsorEnded:
+		change.Action = playcamp.SponsorActionEnded
+		s.CreatorKey, s.IsActive = data.CreatorKey, false
+		s.EndedAt = playcamp.String(change.At)
+	default:
+		return false
+	}
+	change.CreatorKey = s.CreatorKey
+	s.record(change, at)
+	return true
+}
+
+// record appends change to the history and marks s as updated at at.
+func (s *sponsorState) record(change sponsorChange, at time.Time) {
+	s.History = append(s.History, change)
+	if len(s.History) > maxSponsorHistory {
+		s.History = s.History[len(s.History)-maxSponsorHistory:]
+	}
+	s.Source = change.Source
+	s.asOf = at
+	s.UpdatedAt = at.UTC().Format(time.RFC3339)
+}
+
+// reconcile replaces the user's state with what PlayCamp reports and returns
+// the campaigns that differed. A user the view does not know is added when
+// seed is set, which is not drift, and skipped otherwise, so a background
+// pass does not bring back users that expired meanwhile.
+func (v *sponsorView) reconcile(key sponsorUserKey, upstream []playcamp.Sponsor, now time.Time, seed bool) []sponsorDrift {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	_, known := v.users[key]
+	if !known && !seed {
+		return []sponsorDrift{}
+	}
+	u := v.user(key)
+	u.reconciledAt = now
+	drift := []sponsorDrift{}
+
+	seen := map[string]bool{}
+	for _, sp := range upstream {
+		seen[sp.CampaignID] = true
+		s, ok := u.campaigns[sp.CampaignID]
+		if ok && s.CreatorKey == sp.CreatorKey && s.IsActive == sp.IsActive {
+			s.asOf = now
+			continue
+		}
+		want := sponsorState{
+			UserID:      key.userID,
+			CampaignID:  sp.CampaignID,
+			CreatorKey:  sp.CreatorKey,
+			IsActive:    sp.IsActive,
+			SponsoredAt: sp.SponsoredAt,
+			EndedAt:     sp.EndedAt,
+		}
+		d := sponsorDrift{CampaignID: sp.CampaignID, Upstream: &want}
+		if !ok {
+			s = &sponsorState{UserID: key.userID, CampaignID: sp.CampaignID}
+			u.campaigns[sp.CampaignID] = s
+		} else {
+			local := s.snapshot()
+			d.Local = &local
+		}
+		previous := s.CreatorKey
+		s.CreatorKey, s.IsActive, s.SponsoredAt, s.EndedAt = want.CreatorKey, want.IsActive, want.SponsoredAt, want.EndedAt
+		s.record(sponsorChange{
+			Action:             sponsorActionReconciled,
+			CreatorKey:         s.CreatorKey,
+			PreviousCreatorKey: previous,
+			At:                 now.UTC().Format(time.RFC3339),
+			Source:             sponsorSourceReconcile,
+		}, now)
+		if known {
+			drift = append(drift, d)
+		}
+	}
+
+	// Active sponsorships PlayCamp no longer reports have ended.
+	for campaignID, s := range u.campaigns {
+		if seen[campaignID] || !s.IsActive {
+			continue
+		}
+		local := s.snapshot()
+		s.IsActive = false
+		s.EndedAt = playcamp.String(now.UTC().Format(time.RFC3339))
+		s.record(sponsorChange{
+			Action:     sponsorActionReconciled,
+			CreatorKey: s.CreatorKey,
+			At:         *s.EndedAt,
+			Source:     sponsorSourceReconcile,
+		}, now)
+		drift = append(drift, sponsorDrift{CampaignID: campaignID, Local: &local})
+	}
+
+	sort.Slice(drift, func(i, j int) bool { return drift[i].CampaignID < drift[j].CampaignID })
+	return drift
+}
+
+// snapshot returns a copy of s that is safe to use without the lock.
+func (s *sponsorState) snapshot() sponsorState {
+	c := *s
+	c.History = append([]sponsorChange(nil), s.History...)
+	return c
+}
+
+// get returns the state of a user, and false if the user is not tracked.
+// A lookup counts as use.
+func (v *sponsorView) get(key sponsorUserKey) (userSponsorState, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	u, ok := v.users[key]
+	if !ok {
+		return userSponsorState{}, false
+	}
+	v.touch(u)
+	state := userSponsorState{UserID: key.userID, IsTest: key.isTest, Sponsors: []sponsorState{}}
+	for _, s := range u.campaigns {
+		state.Sponsors = append(state.Sponsors, s.snapshot())
+	}
+	sort.Slice(state.Sponsors, func(i, j int) bool { return state.Sponsors[i].CampaignID < state.Sponsors[j].CampaignID })
+	if !u.reconciledAt.IsZero() {
+		state.ReconciledAt = playcamp.String(u.reconciledAt.UTC().Format(time.RFC3339))
+	}
+	return state, true
+}
+
+// stalest returns up to n tracked users, least recently reconciled first.
+func (v *sponsorView) stalest(n int) []sponsorUserKey {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := make([]sponsorUserKey, 0, len(v.users))
+	for k := range v.users {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return v.users[keys[i]].reconciledAt.Before(v.users[keys[j]].reconciledAt)
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// applySponsorWebhook feeds the sponsor events of a received webhook into the
+// view. Invalid webhooks and duplicate deliveries are skipped, and so is every
+// delivery while no webhook secret is configured.
+func (a *app) applySponsorWebhook(ctx context.Context, wh receivedWebhook) {
+	if !wh.Valid || wh.DuplicateOf != "" {
+		return
+	}
+	if !a.webhookSecretConfigured() {
+		if wh.hasEvent(playcamp.WebhookEventSponsorCreated, playcamp.WebhookEventSponsorChanged, playcamp.WebhookEventSponsorEnded) {
+			a.webhookLog.WarnContext(ctx, "sponsor events not applied: no webhook secret is configured", "id", wh.ID)
+		}
+		return
+	}
+	for _, evt := range wh.Events {
+		switch playcamp.WebhookEventType(evt.Event) {
+		case playcamp.WebhookEventSponsorCreated, playcamp.WebhookEventSponsorChanged, playcamp.WebhookEventSponsorEnded:
+			if !a.sponsorState.applyEvent(evt, wh.ID) {
+				a.webhookLog.DebugContext(ctx, "sponsor event not applied", "id", wh.ID, "event", evt.Event)
+			}
+		}
+	}
+}
+
+// reconcileSponsorUser checks one user against PlayCamp, adding them to the
+// view if seed is set.
+func (a *app) reconcileSponsorUser(ctx context.Context, key sponsorUserKey, seed bool) ([]sponsorDrift, error) {
+	sdk := a.server
+	if key.isTest {
+		sdk = a.testServer
+	}
+	sponsors, err := sdk.Sponsors.GetByUser(ctx, key.userID)
+	if err != nil {
+		return nil, err
+	}
+	drift := a.sponsorState.reconcile(key, sponsors, time.Now(), seed)
+	if len(drift) > 0 {
+		a.webhookLog.WarnContext(ctx, "sponsor state drifted from PlayCamp", "userId", key.userID, "isTest", key.isTest, "campaigns", len(drift))
+	}
+	return drift, nil
+}
+
+// runSponsorReconciler expires idle users and reconciles a batch of the
+// least recently checked ones each interval until ctx is done. Calls are
+// paced at sponsorState.reconcileRate and draw on the upstream budget, so a
+// pass cannot crowd out requests. The settings are re-read after each pass
+// so a reload applies.
+func (a *app) runSponsorReconciler(ctx context.Context) {
+	for {
+		cfg := a.config().SponsorState
+		wait := cfg.ReconcileInterval
+		if wait == 0 {
+			// Disabled; check again in case a reload enables it.
+			wait = time.Minute
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		if cfg.ExpireAfter > 0 {
+			if n := a.sponsorState.expire(time.Now().Add(-cfg.ExpireAfter)); n > 0 {
+				a.webhookLog.Debug("sponsor state expired idle users", "users", n)
+			}
+		}
+		if cfg.ReconcileInterval == 0 {
+			continue
+		}
+		a.reconcileSponsorBatch(ctx, cfg)
+	}
+}
+
+// reconcileSponsorBatch reconciles up to cfg.ReconcileBatch users. It stops
+// early when ctx is done or the upstream budget runs out; the rest wait for
+// the next pass.
+func (a *app) reconcileSponsorBatch(ctx context.Context, cfg sponsorStateConfig) {
+	pace := time.NewTicker(time.Duration(float64(time.Second) / cfg.ReconcileRate))
+	defer pace.Stop()
+
+	var users, drifted, failed int
+	for i, key := range a.sponsorState.stalest(cfg.ReconcileBatch) {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-pace.C:
+			}
+		}
+		if !a.allowBackgroundUpstream() {
+			a.webhookLog.Info("sponsor reconciliation paused, upstream budget exhausted", "users", users)
+			break
+		}
+		users++
+		drift, err := a.reconcileSponsorUser(ctx, key, false)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			failed++
+			a.webhookLog.Warn("sponsor reconciliation failed", "userId", key.userID, "error", err.Error())
+			continue
+		}
+		if len(drift) > 0 {
+			drifted++
+		}
+	}
+	a.webhookLog.Info("sponsor state reconciled", "users", users, "drifted", drifted, "failed", failed, "tracked", a.sponsorState.len())
+}
+
+// --- Sponsor State Endpoints ---
+
+// handleGetSponsorState handles GET /api/sponsors/{userId}/state
+func (a *app) handleGetSponsorState(w http.ResponseWriter, r *http.Request) {
+	key := sponsorUserKey{isTest: isTestFromQuery(r), userID: chi.URLParam(r, "userId")}
+
+	state, ok := a.sponsorState.get(key)
+	if !ok {
+		// First sight of this user: seed the view once. Later lookups,
+		// including for users with no sponsorship, stay local, so only the
+		// seed spends the upstream budget.
+		if !a.spendUpstream(w, r, false) {
+			return
+		}
+		if _, err := a.reconcileSponsorUser(r.Context(), key, true); err != nil {
+			handleSDKError(w, r, err)
+			return
+		}
+		state, _ = a.sponsorState.get(key)
+	}
+
+	if campaignID := r.URL.Query().Get("campaignId"); campaignID != "" {
+		var filtered []sponsorState
+		for _, s := range state.Sponsors {
+			if s.CampaignID == campaignID {
+				filtered = append(filtered, s)
+			}
+		}
+		state.Sponsors = append([]sponsorState{}, filtered...)
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// handleReconcileSponsorState handles POST /api/sponsors/{userId}/state/reconcile
+func (a *app) handleReconcileSponsorState(w http.ResponseWriter, r *http.Request) {
+	key := sponsorUserKey{isTest: isTestFromQuery(r), userID: chi.URLParam(r, "userId")}
+
+	drift, err := a.reconcileSponsorUser(r.Context(), key, true)
+	if err != nil {
+		handleSDKError(w, r, err)
+		return
+	}
+	state, _ := a.sponsorState.get(key)
+	writeJSON(w, http.StatusOK, sponsorReconcileResult{State: state, Drift: drift})
+}
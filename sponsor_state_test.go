@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+	"github.com/playcamp/playcamp-go-sdk/webhookutil"
+)
+
+func TestSponsorStateFromWebhooks(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	a := newTestApp(t, fake, nil)
+
+	var run scenarioRunResult
+	decodeData(t, serve(a, http.MethodPost, "/api/webhooks/scenarios/run", `{"name":"creator-switch","seed":3,"speed":1000}`), &run)
+	var changed playcamp.SponsorChangedData
+	for _, d := range run.Deliveries {
+		if d.Event == string(playcamp.WebhookEventSponsorChanged) {
+			json.Unmarshal(d.Data, &changed)
+		}
+	}
+
+	var state userSponsorState
+	decodeData(t, serve(a, http.MethodGet, "/api/sponsors/"+changed.UserID+"/state?isTest=true", ""), &state)
+	if len(state.Sponsors) != 1 {
+		t.Fatalf("state = %+v", state)
+	}
+	s := state.Sponsors[0]
+	if !s.IsActive || s.CreatorKey != changed.NewCreatorKey || s.CampaignID != changed.CampaignID || s.Source != sponsorSourceWebhook {
+		t.Errorf("sponsor = %+v, want active with %q", s, changed.NewCreatorKey)
+	}
+	if len(s.History) != 2 || s.History[0].Action != playcamp.SponsorActionCreated || s.History[1].PreviousCreatorKey != changed.OldCreatorKey {
+		t.Errorf("history = %+v", s.History)
+	}
+	if got := len(fake.received()); got != 0 {
+		t.Errorf("upstream requests = %d, want none", got)
+	}
+
+	// Live mode is tracked separately and seeded from PlayCamp on first use.
+	fake.on(http.MethodGet, "/v1/server/sponsors/user/"+changed.UserID, http.StatusOK, `{"data":[]}`)
+	for i := 0; i < 2; i++ {
+		decodeData(t, serve(a, http.MethodGet, "/api/sponsors/"+changed.UserID+"/state", ""), &state)
+	}
+	if len(state.Sponsors) != 0 || state.ReconciledAt == nil {
+		t.Errorf("live state = %+v", state)
+	}
+	if got := len(fake.received()); got != 1 {
+		t.Errorf("upstream requests = %d, want 1", got)
+	}
+}
+
+func TestSponsorViewReconcile(t *testing.T) {
+	v := newSponsorView(10)
+	key := sponsorUserKey{userID: "u1"}
+	event := func(typ playcamp.WebhookEventType, at time.Time, data string) webhookEvent {
+		return webhookEvent{Event: string(typ), Timestamp: at.Format(time.RFC3339), Data: json.RawMessage(data)}
+	}
+	start := time.Now().Add(-time.Hour)
+	v.applyEvent(event(playcamp.WebhookEventSponsorCreated, start, `{"userId":"u1","campaignId":"c1","creatorKey":"neo"}`), "wh_1")
+	v.applyEvent(event(playcamp.WebhookEventSponsorCreated, start, `{"userId":"u1","campaignId":"c2","creatorKey":"neo"}`), "wh_2")
+
+	// PlayCamp says c1 moved to another creator and c2 is gone.
+	now := time.Now()
+	drift := v.reconcile(key, []playcamp.Sponsor{{UserID: "u1", CampaignID: "c1", CreatorKey: "trinity", IsActive: true}}, now, true)
+	if len(drift) != 2 || drift[0].Local.CreatorKey != "neo" || drift[0].Upstream.CreatorKey != "trinity" || drift[1].Upstream != nil {
+		t.Fatalf("drift = %+v", drift)
+	}
+	state, _ := v.get(key)
+	if c1, c2 := state.Sponsors[0], state.Sponsors[1]; c1.CreatorKey != "trinity" || c1.Source != sponsorSourceReconcile || c2.IsActive {
+		t.Errorf("sponsors = %+v", state.Sponsors)
+	}
+
+	// A late delivery from before the reconciliation is ignored; newer ones apply.
+	if v.applyEvent(event(playcamp.WebhookEventSponsorEnded, start.Add(time.Minute), `{"userId":"u1","campaignId":"c1","creatorKey":"neo"}`), "wh_3") {
+		t.Error("stale sponsor.ended was applied")
+	}
+	if !v.applyEvent(event(playcamp.WebhookEventSponsorEnded, now.Add(time.Minute), `{"userId":"u1","campaignId":"c1","creatorKey":"trinity"}`), "wh_4") {
+		t.Error("newer sponsor.ended was not applied")
+	}
+	if state, _ := v.get(key); state.Sponsors[0].IsActive {
+		t.Errorf("c1 still active: %+v", state.Sponsors[0])
+	}
+
+	if drift := v.reconcile(sponsorUserKey{userID: "new"}, []playcamp.Sponsor{{CampaignID: "c1", CreatorKey: "neo", IsActive: true}}, now, true); len(drift) != 0 {
+		t.Errorf("seeding a new user reported drift: %+v", drift)
+	}
+}
+
+func TestSponsorViewBounds(t *testing.T) {
+	v := newSponsorView(3)
+	now := time.Unix(1700000000, 0)
+	v.now = func() time.Time { return now }
+	key := func(id string) sponsorUserKey { return sponsorUserKey{userID: id} }
+	seed := func(id string) {
+		now = now.Add(time.Minute)
+		v.reconcile(key(id), nil, now, true)
+	}
+
+	for _, id := range []string{"u1", "u2", "u3"} {
+		seed(id)
+	}
+	now = now.Add(time.Minute)
+	v.get(key("u1")) // u2 is now least recently used
+	seed("u4")
+
+	tests := []struct {
+		step string
+		run  func()
+		want []string
+	}{
+		{"lru eviction", func() {}, []string{"u1", "u3", "u4"}},
+		{"background pass skips unknown users", func() { v.reconcile(key("u2"), nil, now, false) }, []string{"u1", "u3", "u4"}},
+		{"shrink", func() { v.resize(2) }, []string{"u1", "u4"}},
+		{"expire idle", func() { v.expire(now.Add(-30 * time.Second)) }, []string{"u4"}},
+	}
+	for _, tc := range tests {
+		tc.run()
+		var got []string
+		for _, id := range []string{"u1", "u2", "u3", "u4"} {
+			if _, ok := v.users[key(id)]; ok {
+				got = append(got, id)
+			}
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") || v.lru.Len() != len(got) {
+			t.Errorf("%s: users = %v (lru %d), want %v", tc.step, got, v.lru.Len(), tc.want)
+		}
+	}
+}
+
+func TestReconcileSponsorBatch(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	for _, id := range []string{"u1", "u2", "u3", "u4"} {
+		fake.on(http.MethodGet, "/v1/server/sponsors/user/"+id, http.StatusOK, `{"data":[]}`)
+	}
+	a := newTestApp(t, fake, nil)
+	start := time.Now().Add(-time.Hour)
+	for i, id := range []string{"u1", "u2", "u3", "u4"} {
+		a.sponsorState.reconcile(sponsorUserKey{userID: id}, nil, start.Add(time.Duration(i)*time.Minute), true)
+	}
+	cfg := sponsorStateConfig{ReconcileBatch: 3, ReconcileRate: 1000}
+
+	reconciled := func() []string {
+		var paths []string
+		for _, r := range fake.received() {
+			paths = append(paths, strings.TrimPrefix(r.Path, "/v1/server/sponsors/user/"))
+		}
+		return paths
+	}
+
+	// The batch takes the three least recently checked users.
+	a.reconcileSponsorBatch(context.Background(), cfg)
+	if got := strings.Join(reconciled(), ","); got != "u1,u2,u3" {
+		t.Fatalf("reconciled = %s", got)
+	}
+
+	// With an upstream budget, the pass stops when it runs out, reserve included.
+	c := *a.config()
+	c.RateLimit.Enabled = true
+	c.RateLimit.Upstream = upstreamBudget{Rate: 0.001, Burst: 2, Reserve: 1}
+	a.cfg.Store(&c)
+	a.reconcileSponsorBatch(context.Background(), cfg)
+	if got := strings.Join(reconciled(), ","); got != "u1,u2,u3,u4" {
+		t.Errorf("reconciled = %s", got)
+	}
+}
+
+func TestSponsorWebhooksWithoutSecret(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), map[string]string{"WEBHOOK_SECRET": ""})
+	payload := []byte(`{"events":[{"event":"sponsor.created","timestamp":"2026-10-05T09:00:00Z","data":{"userId":"u1","campaignId":"c1","creatorKey":"neo"}}]}`)
+
+	for name, signature := range map[string]string{
+		"unsigned":  "",
+		"empty key": webhookutil.ConstructSignature(payload, "", nil),
+	} {
+		req := httptest.NewRequest(http.MethodPost, receiverPath, bytes.NewReader(payload))
+		if signature != "" {
+			req.Header.Set("X-Webhook-Signature", signature)
+		}
+		rec := httptest.NewRecorder()
+		a.router.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d", name, rec.Code)
+		}
+	}
+	if n := a.sponsorState.len(); n != 0 {
+		t.Errorf("view has %d users, want none", n)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves the certificate, key and client CA bundle from disk and
+// re-reads them when their modification times change, so certificates can be
+// rotated without a restart.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	log      *slog.Logger
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+// newCertReloader loads the files once; a failure here is fatal at startup.
+func newCertReloader(cfg tlsConfig, log *slog.Logger) (*certReloader, error) {
+	c := &certReloader{
+		certFile: cfg.CertFile,
+		keyFile:  cfg.KeyFile,
+		caFile:   cfg.ClientCAFile,
+		log:      log,
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) files() []string {
+	files := []string{c.certFile, c.keyFile}
+	if c.caFile != "" {
+		files = append(files, c.caFile)
+	}
+	return files
+}
+
+// load reads every file and swaps them in together.
+func (c *certReloader) load() error {
+	modTimes := map[string]time.Time{}
+	for _, f := range c.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		modTimes[f] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls: load key pair: %w", err)
+	}
+	if cert.Leaf == nil && len(cert.Certificate) > 0 {
+		cert.Leaf, _ = x509.ParseCertificate(cert.Certificate[0])
+	}
+
+	var pool *x509.CertPool
+	if c.caFile != "" {
+		pem, err := os.ReadFile(c.caFile)
+		if err != nil {
+			return fmt.Errorf("tls: read client CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return errors.New("tls: client CA bundle contains no PEM certificates")
+		}
+	}
+
+	c.mu.Lock()
+	c.cert = &cert
+	c.clientCAs = pool
+	c.modTimes = modTimes
+	c.mu.Unlock()
+	return nil
+}
+
+// changed reports whether any file's modification time differs from the
+// loaded version.
+func (c *certReloader) changed() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, f := range c.files() {
+		info, err := os.Stat(f)
+		if err != nil {
+			// Mid-rotation the file may briefly be missing; try again later.
+			return false
+		}
+		if !info.ModTime().Equal(c.modTimes[f]) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch polls the files until ctx is done. A failed reload keeps serving the
+// previous certificate.
+func (c *certReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !c.changed() {
+				continue
+			}
+			if err := c.load(); err != nil {
+				c.log.Error("tls reload failed, keeping previous certificate", "error", err.Error())
+				continue
+			}
+			c.log.Info("tls certificate reloaded", "notAfter", c.notAfter().Format(time.RFC3339))
+		}
+	}
+}
+
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+func (c *certReloader) notAfter() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.cert == nil || c.cert.Leaf == nil {
+		return time.Time{}
+	}
+	return c.cert.Leaf.NotAfter
+}
+
+// readiness fails once the served certificate has expired.
+func (c *certReloader) readiness(context.Context) error {
+	if na := c.notAfter(); !na.IsZero() && time.Now().After(na) {
+		return fmt.Errorf("tls certificate expired at %s", na.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// serverConfig builds the listener's TLS config. With a client CA bundle,
+// client certificates are requested and verified when presented; whether one
+// is required is decided per route by requireClientCert, so the webhook
+// receiver keeps working for PlayCamp, which does not send one.
+func (c *certReloader) serverConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: c.getCertificate,
+	}
+	if c.caFile == "" {
+		return base
+	}
+
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		cfg := base.Clone()
+		cfg.GetConfigForClient = nil
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		c.mu.RLock()
+		cfg.ClientCAs = c.clientCAs
+		c.mu.RUnlock()
+		return cfg, nil
+	}
+	return base
+}
+
+// requireClientCert rejects requests that did not present a client
+// certificate verified against tls.clientCAFile. It is a no-op unless mTLS is
+// configured.
+func (a *app) requireClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.config().TLS.ClientCAFile == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			writeError(w, r, http.StatusForbidden, "a verified client certificate is required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
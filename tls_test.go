@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes cert and key as PEM files in dir.
+func writeKeyPair(t *testing.T, dir, name string, cert *x509.Certificate, key *ecdsa.PrivateKey) (certFile, keyFile string) {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	first, firstKey := newTestCert(t, "first", nil, nil, nil)
+	certFile, keyFile := writeKeyPair(t, dir, "server", first, firstKey)
+
+	c, err := newCertReloader(tlsConfig{CertFile: certFile, KeyFile: keyFile}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.changed() {
+		t.Error("changed right after load")
+	}
+
+	// bump moves the files' modification times forward so the change is seen
+	// even on filesystems with coarse timestamps.
+	bump := func(d time.Duration) {
+		for _, f := range []string{certFile, keyFile} {
+			at := time.Now().Add(d)
+			if err := os.Chtimes(f, at, at); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		name    string
+		write   func()
+		wantErr bool
+		wantCN  string
+	}{
+		{"rotated", func() {
+			second, secondKey := newTestCert(t, "second", nil, nil, nil)
+			writeKeyPair(t, dir, "server", second, secondKey)
+		}, false, "second"},
+		{"broken key keeps previous", func() {
+			os.WriteFile(keyFile, []byte("not a key"), 0o600)
+		}, true, "second"},
+	}
+	for i, tc := range tests {
+		tc.write()
+		bump(time.Duration(i+1) * time.Minute)
+		if !c.changed() {
+			t.Fatalf("%s: change not detected", tc.name)
+		}
+		if err := c.load(); (err != nil) != tc.wantErr {
+			t.Errorf("%s: load = %v", tc.name, err)
+		}
+		cert, _ := c.getCertificate(nil)
+		if cn := cert.Leaf.Subject.CommonName; cn != tc.wantCN {
+			t.Errorf("%s: serving %q, want %q", tc.name, cn, tc.wantCN)
+		}
+	}
+	if err := c.readiness(context.Background()); err != nil {
+		t.Errorf("readiness = %v", err)
+	}
+}
+
+func TestRequireClientCert(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newTestCert(t, "Test Client CA", nil, nil, nil)
+	caFile, _ := writeKeyPair(t, dir, "ca", ca, caKey)
+	server, serverKey := newTestCert(t, "localhost", nil, nil, nil)
+	certFile, keyFile := writeKeyPair(t, dir, "server", server, serverKey)
+	client, clientKey := newTestCert(t, "game-server", nil, ca, caKey)
+	stranger, strangerKey := newTestCert(t, "stranger", nil, nil, nil)
+
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodGet, "/v1/server/campaigns", http.StatusOK, `{"data":[],"pagination":{"page":1,"limit":20,"total":0,"totalPages":0}}`)
+	a := newTestApp(t, fake, map[string]string{
+		"TLS_CERT_FILE":      certFile,
+		"TLS_KEY_FILE":       keyFile,
+		"TLS_CLIENT_CA_FILE": caFile,
+	})
+	certs, err := newCertReloader(a.config().TLS, a.lifecycle.log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(a.router)
+	srv.TLS = certs.serverConfig()
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	do := func(method, path string, cert *x509.Certificate, key *ecdsa.PrivateKey) int {
+		t.Helper()
+		cfg := &tls.Config{InsecureSkipVerify: true}
+		if cert != nil {
+			cfg.Certificates = []tls.Certificate{{Certificate: [][]byte{cert.Raw}, PrivateKey: key}}
+		}
+		hc := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg}}
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(`{}`))
+		resp, err := hc.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		cert   *x509.Certificate
+		key    *ecdsa.PrivateKey
+		want   int
+	}{
+		{"api with client cert", http.MethodGet, "/api/campaigns", client, clientKey, http.StatusOK},
+		{"api without client cert", http.MethodGet, "/api/campaigns", nil, nil, http.StatusForbidden},
+		// The client only offers certificates issued by a CA the server asks for.
+		{"api with untrusted cert", http.MethodGet, "/api/campaigns", stranger, strangerKey, http.StatusForbidden},
+		{"health without client cert", http.MethodGet, "/healthz", nil, nil, http.StatusOK},
+		// PlayCamp sends no client certificate, so the receiver stays open.
+		{"webhook without client cert", http.MethodPost, receiverPath, nil, nil, http.StatusOK},
+	}
+	for _, tc := range tests {
+		if got := do(tc.method, tc.path, tc.cert, tc.key); got != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
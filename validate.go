@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// Request DTOs declare their constraints in a `validate` struct tag, a
+// comma-separated list of rules:
+//
+//	required        present and non-empty
+//	max=N, min=N    string length in characters, or slice length
+//	positive        number greater than zero
+//	gte=N           number at least N
+//	currency        active ISO 4217 code
+//	enum=NAME       one of the values registered in enums
+//	rfc3339         RFC 3339 timestamp
+//	date            calendar date such as 2026-01-31
+//	url             absolute http or https URL
+//	duration        non-negative Go duration such as 500ms or 2s
+//	dive            validate each element of a slice of structs
+//
+// Optional (pointer or empty) fields are only checked when present. Rules
+// that span fields go in a check method; see checker.
+
+// enums lists the accepted values for each enum=NAME rule.
+var enums = map[string][]string{
+	"paymentPlatform": {
+		string(playcamp.PaymentPlatformIOS),
+		string(playcamp.PaymentPlatformAndroid),
+		string(playcamp.PaymentPlatformWeb),
+		string(playcamp.PaymentPlatformRoblox),
+		string(playcamp.PaymentPlatformOther),
+	},
+	"distributionType": {
+		string(playcamp.DistributionMobileStore),
+		string(playcamp.DistributionMobileSelfStore),
+		string(playcamp.DistributionPCStore),
+		string(playcamp.DistributionPCSelfStore),
+	},
+	"webhookEventType": {
+		string(playcamp.WebhookEventCouponRedeemed),
+		string(playcamp.WebhookEventPaymentCreated),
+		string(playcamp.WebhookEventPaymentRefunded),
+		string(playcamp.WebhookEventSponsorCreated),
+		string(playcamp.WebhookEventSponsorChanged),
+		string(playcamp.WebhookEventPaymentBulkCreated),
+		string(playcamp.WebhookEventSponsorEnded),
+	},
+	"environment": {
+		string(playcamp.EnvironmentSandbox),
+		string(playcamp.EnvironmentLive),
+	},
+}
+
+// bind decodes the JSON request body into dst and validates it. On failure it
+// writes a problem response listing every violation and returns false. An
+// empty body decodes as {} so that missing required fields are reported
+// individually.
+func (a *app) bind(w http.ResponseWriter, r *http.Request, dst any) bool {
+	dec := json.NewDecoder(r.Body)
+	if a.config().Validation.Strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(dst); err != nil && !errors.Is(err, io.EOF) {
+		writeDecodeError(w, r, err)
+		return false
+	}
+
+	if violations := validateStruct(dst); len(violations) > 0 {
+		p := newProblem(r, problemInvalidInput, http.StatusBadRequest, "request body failed validation")
+		p.Errors = violations
+		writeProblem(w, p)
+		return false
+	}
+	return true
+}
+
+// writeDecodeError reports a body that is not valid JSON for the DTO.
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	p := newProblem(r, problemBadRequest, http.StatusBadRequest, "invalid JSON body")
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		p.Errors = []problemField{{Field: indexPath(typeErr.Field), Message: "must be " + jsonTypeName(typeErr.Type)}}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		p.Errors = []problemField{{Field: field, Message: "unknown field"}}
+	}
+	writeProblem(w, p)
+}
+
+// indexPath rewrites encoding/json's "payments.1.amount" as
+// "payments[1].amount" to match validation paths.
+func indexPath(field string) string {
+	parts := strings.Split(field, ".")
+	var b strings.Builder
+	for i, part := range parts {
+		if _, err := strconv.Atoi(part); err == nil && i > 0 {
+			b.WriteString("[" + part + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// validateStruct checks v (a struct or pointer to one) against its validate
+// tags and returns every violation.
+func validateStruct(v any) []problemField {
+	var out []problemField
+	validateValue("", reflect.ValueOf(v), &out)
+	return out
+}
+
+// checker is implemented by DTOs with rules that span fields. check returns
+// violations with paths relative to the struct.
+type checker interface {
+	check() []problemField
+}
+
+func validateValue(prefix string, v reflect.Value, out *[]problemField) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	before := len(*out)
+	validateFields(prefix, v, out)
+
+	// Only when the fields are valid, so check can rely on them.
+	if c, ok := v.Interface().(checker); ok && len(*out) == before {
+		for _, p := range c.check() {
+			if prefix != "" {
+				p.Field = prefix + "." + p.Field
+			}
+			*out = append(*out, p)
+		}
+	}
+}
+
+func validateFields(prefix string, v reflect.Value, out *[]problemField) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			// Embedded structs are flattened in JSON, so validate in place.
+			// A promoted check runs once, for the outer struct.
+			validateFields(prefix, v.Field(i), out)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name := jsonFieldName(f)
+		if name == "-" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		validateField(path, v.Field(i), f.Tag.Get("validate"), out)
+	}
+}
+
+func validateField(path string, fv reflect.Value, tag string, out *[]problemField) {
+	rules := splitRules(tag)
+	fail := func(msg string) {
+		*out = append(*out, problemField{Field: path, Message: msg})
+	}
+
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			if _, ok := rules["required"]; ok {
+				fail("is required")
+			}
+			return
+		}
+		fv = fv.Elem()
+	}
+
+	if isEmptyValue(fv) {
+		if _, ok := rules["required"]; ok {
+			fail("is required")
+		}
+		return
+	}
+
+	for _, rule := range orderedRules(tag) {
+		name, arg, _ := strings.Cut(rule, "=")
+		if msg := checkRule(name, arg, fv); msg != "" {
+			fail(msg)
+			// One message per field is enough; later rules usually repeat it.
+			break
+		}
+	}
+
+	if _, ok := rules["dive"]; ok && fv.Kind() == reflect.Slice {
+		for i := 0; i < fv.Len(); i++ {
+			validateValue(fmt.Sprintf("%s[%d]", path, i), fv.Index(i), out)
+		}
+	} else if fv.Kind() == reflect.Struct {
+		validateValue(path, fv, out)
+	}
+}
+
+// checkRule returns a violation message, or "" if fv satisfies the rule.
+func checkRule(name, arg string, fv reflect.Value) string {
+	switch name {
+	case "required", "dive":
+		return ""
+	case "max", "min":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			panic("validate: bad " + name + " argument " + strconv.Quote(arg))
+		}
+		length, unit := 0, "items"
+		switch fv.Kind() {
+		case reflect.String:
+			length, unit = utf8.RuneCountInString(fv.String()), "characters"
+		case reflect.Slice, reflect.Map:
+			length = fv.Len()
+		}
+		if name == "max" && length > n {
+			return fmt.Sprintf("must be at most %d %s", n, unit)
+		}
+		if name == "min" && length < n {
+			return fmt.Sprintf("must be at least %d %s", n, unit)
+		}
+	case "positive":
+		if numberValue(fv) <= 0 {
+			return "must be greater than zero"
+		}
+	case "gte":
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic("validate: bad gte argument " + strconv.Quote(arg))
+		}
+		if numberValue(fv) < n {
+			return "must be at least " + arg
+		}
+	case "currency":
+		if !isCurrencyCode(fv.String()) {
+			return "must be an ISO 4217 currency code"
+		}
+	case "enum":
+		values, ok := enums[arg]
+		if !ok {
+			panic("validate: unknown enum " + strconv.Quote(arg))
+		}
+		for _, v := range values {
+			if fv.String() == v {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(values, ", ")
+	case "rfc3339":
+		if _, err := time.Parse(time.RFC3339, fv.String()); err != nil {
+			return "must be an RFC 3339 timestamp"
+		}
+	case "date":
+		if _, err := time.Parse(dayLayout, fv.String()); err != nil {
+			return "must be a date such as 2026-01-31"
+		}
+	case "duration":
+		if d, err := time.ParseDuration(fv.String()); err != nil || d < 0 {
+			return "must be a duration such as 500ms or 2s"
+		}
+	case "url":
+		u, err := url.Parse(fv.String())
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an absolute http or https URL"
+		}
+	default:
+		panic("validate: unknown rule " + strconv.Quote(name))
+	}
+	return ""
+}
+
+func splitRules(tag string) map[string]string {
+	rules := map[string]string{}
+	for _, rule := range orderedRules(tag) {
+		name, arg, _ := strings.Cut(rule, "=")
+		rules[name] = arg
+	}
+	return rules
+}
+
+func orderedRules(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	return strings.Split(tag, ",")
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return false
+}
+
+func numberValue(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	}
+	panic("validate: numeric rule on " + v.Kind().String())
+}
+
+// jsonFieldName returns the name a struct field has in JSON.
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// violations renders validateStruct's result as "field: message" lines.
+func violations(v any) []string {
+	var out []string
+	for _, p := range validateStruct(v) {
+		out = append(out, p.Field+": "+p.Message)
+	}
+	return out
+}
+
+func TestValidateRules(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name string
+		v    any
+		want string // "" when valid
+	}{
+		{"required missing", struct {
+			X string `json:"x" validate:"required"`
+		}{}, "x: is required"},
+		{"required nil pointer", struct {
+			X *string `json:"x" validate:"required"`
+		}{}, "x: is required"},
+		{"required empty slice", struct {
+			X []string `json:"x" validate:"required"`
+		}{X: []string{}}, "x: is required"},
+		{"optional pointer skipped", struct {
+			X *string `json:"x" validate:"rfc3339"`
+		}{}, ""},
+		{"optional empty string skipped", struct {
+			X string `json:"x" validate:"currency"`
+		}{}, ""},
+		{"max counts characters", struct {
+			X string `json:"x" validate:"max=5"`
+		}{"héllo"}, ""},
+		{"max exceeded", struct {
+			X string `json:"x" validate:"max=4"`
+		}{"héllo"}, "x: must be at most 4 characters"},
+		{"min items", struct {
+			X []string `json:"x" validate:"min=2"`
+		}{[]string{"a"}}, "x: must be at least 2 items"},
+		{"positive zero", struct {
+			X int `json:"x" validate:"positive"`
+		}{}, "x: must be greater than zero"},
+		{"positive negative float", struct {
+			X float64 `json:"x" validate:"positive"`
+		}{-1.5}, "x: must be greater than zero"},
+		{"positive", struct {
+			X float64 `json:"x" validate:"positive"`
+		}{0.01}, ""},
+		{"gte below", struct {
+			X int `json:"x" validate:"gte=1"`
+		}{0}, "x: must be at least 1"},
+		{"currency", struct {
+			X string `json:"x" validate:"currency"`
+		}{"KRW"}, ""},
+		{"currency lower case", struct {
+			X string `json:"x" validate:"currency"`
+		}{"usd"}, "x: must be an ISO 4217 currency code"},
+		{"currency unknown", struct {
+			X string `json:"x" validate:"currency"`
+		}{"XYZ"}, "x: must be an ISO 4217 currency code"},
+		{"enum", struct {
+			X playcamp.PaymentPlatform `json:"x" validate:"enum=paymentPlatform"`
+		}{playcamp.PaymentPlatformIOS}, ""},
+		{"enum unknown value", struct {
+			X playcamp.PaymentPlatform `json:"x" validate:"enum=paymentPlatform"`
+		}{"Windows"}, "x: must be one of: " + strings.Join(enums["paymentPlatform"], ", ")},
+		{"rfc3339", struct {
+			X *string `json:"x" validate:"rfc3339"`
+		}{str("2026-10-18T09:00:00+09:00")}, ""},
+		{"rfc3339 date only", struct {
+			X *string `json:"x" validate:"rfc3339"`
+		}{str("2026-10-18")}, "x: must be an RFC 3339 timestamp"},
+		{"date", struct {
+			X string `json:"x" validate:"date"`
+		}{"2026-02-30"}, "x: must be a date such as 2026-01-31"},
+		{"duration", struct {
+			X string `json:"x" validate:"duration"`
+		}{"1m30s"}, ""},
+		{"duration without unit", struct {
+			X string `json:"x" validate:"duration"`
+		}{"5"}, "x: must be a duration such as 500ms or 2s"},
+		{"duration negative", struct {
+			X string `json:"x" validate:"duration"`
+		}{"-1s"}, "x: must be a duration such as 500ms or 2s"},
+		{"url", struct {
+			X string `json:"x" validate:"url"`
+		}{"https://example.com/hooks"}, ""},
+		{"url relative", struct {
+			X string `json:"x" validate:"url"`
+		}{"/hooks"}, "x: must be an absolute http or https URL"},
+		{"url scheme", struct {
+			X string `json:"x" validate:"url"`
+		}{"ftp://example.com"}, "x: must be an absolute http or https URL"},
+		{"first failing rule only", struct {
+			X string `json:"x" validate:"required,max=2,currency"`
+		}{"abcd"}, "x: must be at most 2 characters"},
+		{"name without json tag", struct {
+			Code string `validate:"required"`
+		}{}, "Code: is required"},
+		{"json name omitted", struct {
+			X string `json:"-" validate:"required"`
+		}{}, ""},
+	}
+	for _, tc := range tests {
+		if got := strings.Join(violations(tc.v), "; "); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestValidateFieldPaths(t *testing.T) {
+	item := func(currency string, amount float64) paymentItem {
+		return paymentItem{UserID: "u1", TransactionID: "t1", ProductID: "p1", Amount: amount, Currency: currency, Platform: playcamp.PaymentPlatformWeb}
+	}
+	type inner struct {
+		Code string `json:"code" validate:"required"`
+	}
+
+	tests := []struct {
+		name string
+		v    any
+		want []string
+	}{
+		{"dive index", createBulkPaymentRequest{Payments: []paymentItem{item("USD", 1), item("KRW", 1000), item("usd", 1)}},
+			[]string{"payments[2].currency: must be an ISO 4217 currency code"}},
+		{"dive check", createBulkPaymentRequest{Payments: []paymentItem{item("USD", 1), item("KRW", 1200.5)}},
+			[]string{"payments[1].amount: must have at most 0 decimal places for KRW"}},
+		{"dive pointer elements", struct {
+			Items []*inner `json:"items" validate:"dive"`
+		}{Items: []*inner{{Code: "a"}, nil, {}}}, []string{"items[2].code: is required"}},
+		{"nested struct without dive", struct {
+			Outer inner `json:"outer"`
+		}{}, []string{"outer.code: is required"}},
+		{"embedded struct is flattened", createPaymentRequest{paymentItem: item("usd", 1)},
+			[]string{"currency: must be an ISO 4217 currency code"}},
+		{"slice without dive is not entered", struct {
+			Items []inner `json:"items"`
+		}{Items: []inner{{}}}, nil},
+	}
+	for _, tc := range tests {
+		if got := violations(tc.v); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestValidateReportsAllViolations(t *testing.T) {
+	req := createBulkPaymentRequest{
+		Payments: []paymentItem{
+			{TransactionID: "t1", ProductID: "p1", Amount: 1, Currency: "USD", Platform: "Switch"},
+			{UserID: "u2", TransactionID: "t2", ProductID: "p2", Amount: -1, Currency: "usd", Platform: playcamp.PaymentPlatformWeb},
+		},
+		CallbackID: strings.Repeat("x", 129),
+	}
+	want := []string{
+		"payments[0].userId: is required",
+		"payments[0].platform: must be one of: " + strings.Join(enums["paymentPlatform"], ", "),
+		"payments[1].amount: must be greater than zero",
+		"payments[1].currency: must be an ISO 4217 currency code",
+		"callbackId: must be at most 128 characters",
+	}
+	if got := violations(req); !reflect.DeepEqual(got, want) {
+		t.Errorf("violations =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// bind reports the same list in one problem response.
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	body, _ := json.Marshal(req)
+	rec := serve(a, http.MethodPost, "/api/payments/bulk", string(body))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+	p := decodeProblem(t, rec)
+	var got []string
+	for _, f := range p.Errors {
+		got = append(got, f.Field+": "+f.Message)
+	}
+	if p.Type != problemInvalidInput || !reflect.DeepEqual(got, want) {
+		t.Errorf("problem %q with errors %q", p.Type, got)
+	}
+}
+
+func TestValidateUnknownTag(t *testing.T) {
+	tests := []struct {
+		name string
+		v    any
+		want string
+	}{
+		{"unknown rule", struct {
+			X string `validate:"email"`
+		}{"a@example.com"}, `validate: unknown rule "email"`},
+		{"unknown enum", struct {
+			X string `validate:"enum=colour"`
+		}{"red"}, `validate: unknown enum "colour"`},
+		{"bad max argument", struct {
+			X string `validate:"max=ten"`
+		}{"abc"}, `validate: bad max argument "ten"`},
+		{"numeric rule on a string", struct {
+			X string `validate:"positive"`
+		}{"1"}, "validate: numeric rule on string"},
+	}
+	for _, tc := range tests {
+		got := func() (msg string) {
+			defer func() { msg = fmt.Sprint(recover()) }()
+			validateStruct(tc.v)
+			return
+		}()
+		if got != tc.want {
+			t.Errorf("%s: panic = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestRequestValidateTags checks every rule on the request DTOs, so a typo in
+// a tag fails here rather than as a panic on the first request that sets the
+// field.
+func TestRequestValidateTags(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	docs, err := listRoutes(a.newRouter(), a.routes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := []reflect.Type{reflect.TypeOf(webhookScenario{})}
+	for _, d := range docs {
+		if d.Request != nil {
+			types = append(types, reflect.TypeOf(d.Request))
+		}
+	}
+
+	seen := map[reflect.Type]bool{}
+	var walk func(t reflect.Type, path string)
+	walk = func(typ reflect.Type, path string) {
+		for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct || seen[typ] {
+			return
+		}
+		seen[typ] = true
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			field := path + "." + f.Name
+			for _, rule := range orderedRules(f.Tag.Get("validate")) {
+				name, arg, _ := strings.Cut(rule, "=")
+				ft := f.Type
+				for ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				func() {
+					defer func() {
+						if p := recover(); p != nil {
+							t.Errorf("%s: rule %q: %v", field, rule, p)
+						}
+					}()
+					checkRule(name, arg, reflect.New(ft).Elem())
+				}()
+			}
+			walk(f.Type, field)
+		}
+	}
+	for _, typ := range types {
+		walk(typ, typ.Name())
+	}
+}
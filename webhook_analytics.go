@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// Delivery analytics pull the delivery logs of every subscription and
+// aggregate them per event type and time bucket. Successful deliveries to
+// this server's receiver are matched against the received-webhook store, so
+// deliveries PlayCamp reports as successful but that never arrived stand out.
+
+// deliveryStats summarizes a set of delivery attempts.
+type deliveryStats struct {
+	Attempts  int `json:"attempts"`
+	Succeeded int `json:"succeeded"`
+	// Failed counts attempts that failed, including those to be retried.
+	Failed int `json:"failed"`
+	// Pending counts attempts that are queued or in progress.
+	Pending int `json:"pending"`
+	// SuccessRate is Succeeded / (Succeeded + Failed), or 0 with neither.
+	SuccessRate float64 `json:"successRate"`
+	// LatencyMs holds percentiles of the time from creation to completion.
+	LatencyMs *latencyPercentiles `json:"latencyMs,omitempty"`
+	// FailureReasons counts failed attempts by "HTTP <status>" or "no response".
+	FailureReasons map[string]int `json:"failureReasons,omitempty"`
+
+	latencies []float64
+}
+
+type latencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// deliveryGroup is the stats of one event type in one time bucket.
+type deliveryGroup struct {
+	EventType   string `json:"eventType"`
+	BucketStart string `json:"bucketStart"`
+	deliveryStats
+}
+
+// missingDelivery is a delivery PlayCamp reports as successful that the
+// receiver has no record of.
+type missingDelivery struct {
+	LogID       string `json:"logId"`
+	WebhookID   int    `json:"webhookId"`
+	EventType   string `json:"eventType"`
+	Attempt     int    `json:"attempt"`
+	CreatedAt   string `json:"createdAt"`
+	CompletedAt string `json:"completedAt,omitempty"`
+}
+
+// webhookLogsError records a subscription whose logs could not be fetched.
+type webhookLogsError struct {
+	WebhookID int    `json:"webhookId"`
+	Error     string `json:"error"`
+}
+
+// webhookAnalytics is the response of GET /api/webhooks/analytics.
+type webhookAnalytics struct {
+	Since         string          `json:"since"`
+	Bucket        string          `json:"bucket"`
+	Subscriptions int             `json:"subscriptions"`
+	Totals        deliveryStats   `json:"totals"`
+	Groups        []deliveryGroup `json:"groups"`
+
+	// ReceiverWindowStart is the oldest time the received-webhook store
+	// still covers. Successful deliveries before it cannot be checked and
+	// are counted in Unverified instead.
+	ReceiverWindowStart string            `json:"receiverWindowStart"`
+	Missing             []missingDelivery `json:"missing"`
+	Unverified          int               `json:"unverified"`
+
+	Unavailable []webhookLogsError `json:"unavailable,omitempty"`
+}
+
+func (s *deliveryStats) add(entry playcamp.WebhookLog) {
+	s.Attempts++
+	switch entry.Status {
+	case playcamp.WebhookStatusSuccess:
+		s.Succeeded++
+	case playcamp.WebhookStatusFailed, playcamp.WebhookStatusRetrying:
+		s.Failed++
+		if s.FailureReasons == nil {
+			s.FailureReasons = map[string]int{}
+		}
+		s.FailureReasons[failureReason(entry)]++
+	default:
+		s.Pending++
+	}
+	if ms, ok := deliveryLatency(entry); ok {
+		s.latencies = append(s.latencies, ms)
+	}
+}
+
+// finish computes the derived fields once all attempts are added.
+func (s *deliveryStats) finish() {
+	if done := s.Succeeded + s.Failed; done > 0 {
+		s.SuccessRate = math.Round(float64(s.Succeeded)/float64(done)*10000) / 10000
+	}
+	if len(s.latencies) > 0 {
+		sort.Float64s(s.latencies)
+		s.LatencyMs = &latencyPercentiles{
+			P50: percentile(s.latencies, 50),
+			P90: percentile(s.latencies, 90),
+			P99: percentile(s.latencies, 99),
+			Max: s.latencies[len(s.latencies)-1],
+		}
+	}
+}
+
+// percentile returns the nearest-rank percentile p of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+func failureReason(entry playcamp.WebhookLog) string {
+	if entry.ResponseStatus == nil || *entry.ResponseStatus == 0 {
+		return "no response"
+	}
+	return fmt.Sprintf("HTTP %d", *entry.ResponseStatus)
+}
+
+// deliveryLatency returns the milliseconds between creation and completion.
+func deliveryLatency(entry playcamp.WebhookLog) (float64, bool) {
+	if entry.CompletedAt == nil {
+		return 0, false
+	}
+	created, err1 := time.Parse(time.RFC3339Nano, entry.CreatedAt)
+	completed, err2 := time.Parse(time.RFC3339Nano, *entry.CompletedAt)
+	if err1 != nil || err2 != nil || completed.Before(created) {
+		return 0, false
+	}
+	return float64(completed.Sub(created).Milliseconds()), true
+}
+
+// canonicalJSON re-encodes a JSON value with sorted keys and no whitespace,
+// or returns "" if it is not valid JSON.
+func canonicalJSON(raw []byte) string {
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return ""
+	}
+	out, _ := json.Marshal(v)
+	return string(out)
+}
+
+// receivedDeliveryKeys indexes the received webhooks by the canonical form of
+// their body, each event, and each event's data, so a logged payload matches
+// whichever of these PlayCamp records.
+func receivedDeliveryKeys(webhooks []receivedWebhook) map[string]bool {
+	keys := map[string]bool{}
+	add := func(raw []byte) {
+		if k := canonicalJSON(raw); k != "" {
+			keys[k] = true
+		}
+	}
+	for _, wh := range webhooks {
+		add(wh.RawBody)
+		for _, e := range wh.Events {
+			if raw, err := json.Marshal(e); err == nil {
+				add(raw)
+			}
+			add(e.Data)
+		}
+	}
+	return keys
+}
+
+// pointsAtReceiver reports whether hook delivers to this server's receiver.
+func (c *config) pointsAtReceiver(hook playcamp.Webhook) bool {
+	if receiver := c.receiverURL(); receiver != "" {
+		return hook.URL == receiver
+	}
+	return strings.HasSuffix(strings.TrimSuffix(hook.URL, "/"), receiverPath)
+}
+
+// receiverWindowStart returns the oldest time the received-webhook store
+// still covers: startup, or the oldest stored webhook once it has dropped some.
+func (a *app) receiverWindowStart(stored []receivedWebhook) time.Time {
+	start := a.startedAt.UTC().Truncate(time.Second)
+	if len(stored) > 0 && len(stored) >= a.config().Webhook.StoreSize {
+		if t, err := time.Parse(time.RFC3339, stored[len(stored)-1].ReceivedAt); err == nil && t.After(start) {
+			start = t
+		}
+	}
+	return start
+}
+
+// webhookAnalytics aggregates the delivery attempts of every subscription
+// logged at or after since.
+func (a *app) webhookAnalytics(ctx context.Context, since time.Time, bucket time.Duration) (*webhookAnalytics, error) {
+	hooks, err := a.server.Webhooks.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cfg := a.config()
+	stored := a.receivedWebhooks.list()
+	received := receivedDeliveryKeys(stored)
+	window := a.receiverWindowStart(stored)
+
+	result := &webhookAnalytics{
+		Since:               since.UTC().Format(time.RFC3339),
+		Bucket:              bucket.String(),
+		Subscriptions:       len(hooks),
+		ReceiverWindowStart: window.Format(time.RFC3339),
+		Missing:             []missingDelivery{},
+	}
+	groups := map[[2]string]*deliveryGroup{}
+	for _, hook := range hooks {
+		logs, err := a.server.Webhooks.GetLogs(ctx, hook.ID)
+		if err != nil {
+			result.Unavailable = append(result.Unavailable, webhookLogsError{WebhookID: hook.ID, Error: err.Error()})
+			continue
+		}
+		correlate := cfg.pointsAtReceiver(hook)
+		for _, entry := range logs {
+			created, err := time.Parse(time.RFC3339Nano, entry.CreatedAt)
+			if err != nil || created.Before(since) {
+				continue
+			}
+			eventType := entry.EventType
+			if eventType == "" {
+				eventType = string(hook.EventType)
+			}
+			start := created.UTC().Truncate(bucket).Format(time.RFC3339)
+			key := [2]string{start, eventType}
+			g := groups[key]
+			if g == nil {
+				g = &deliveryGroup{EventType: eventType, BucketStart: start}
+				groups[key] = g
+			}
+			g.add(entry)
+			result.Totals.add(entry)
+
+			if !correlate || entry.Status != playcamp.WebhookStatusSuccess {
+				continue
+			}
+			if created.Before(window) {
+				result.Unverified++
+				continue
+			}
+			payload, _ := json.Marshal(entry.Payload)
+			if !received[canonicalJSON(payload)] {
+				m := missingDelivery{LogID: entry.ID, WebhookID: hook.ID, EventType: eventType, Attempt: entry.Attempt, CreatedAt: entry.CreatedAt}
+				if entry.CompletedAt != nil {
+					m.CompletedAt = *entry.CompletedAt
+				}
+				result.Missing = append(result.Missing, m)
+			}
+		}
+	}
+
+	result.Totals.finish()
+	result.Groups = make([]deliveryGroup, 0, len(groups))
+	for _, g := range groups {
+		g.finish()
+		result.Groups = append(result.Groups, *g)
+	}
+	sort.Slice(result.Groups, func(i, j int) bool {
+		gi, gj := result.Groups[i], result.Groups[j]
+		if gi.BucketStart != gj.BucketStart {
+			return gi.BucketStart < gj.BucketStart
+		}
+		return gi.EventType < gj.EventType
+	})
+	sort.Slice(result.Missing, func(i, j int) bool { return result.Missing[i].CreatedAt < result.Missing[j].CreatedAt })
+	return result, nil
+}
+
+// handleWebhookAnalytics handles GET /api/webhooks/analytics
+func (a *app) handleWebhookAnalytics(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	since := time.Now().Add(-24 * time.Hour)
+	if v := q.Get("since"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, "since must be an RFC 3339 timestamp")
+			return
+		}
+		since = t
+	}
+	bucket := time.Hour
+	if v := q.Get("bucket"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Minute {
+			writeError(w, r, http.StatusBadRequest, "bucket must be a duration of at least 1m")
+			return
+		}
+		bucket = d
+	}
+
+	result, err := a.webhookAnalytics(r.Context(), since, bucket)
+	if err != nil {
+		handleSDKError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/playcamp/playcamp-go-sdk/webhookutil"
+)
+
+func TestPercentile(t *testing.T) {
+	values := []float64{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+	for p, want := range map[float64]float64{50: 50, 90: 90, 99: 100, 1: 10} {
+		if got := percentile(values, p); got != want {
+			t.Errorf("p%v = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestWebhookAnalytics(t *testing.T) {
+	const delivered = `{"events":[{"event":"coupon.redeemed","timestamp":"2026-10-18T09:00:00Z","data":{"couponCode":"A"}}]}`
+	now := time.Now().UTC().Add(time.Minute)
+	at := func(offset time.Duration) string { return now.Add(offset).Format(time.RFC3339Nano) }
+	logEntry := func(id, status string, responseStatus, payload string) string {
+		return fmt.Sprintf(`{"id":%q,"webhookId":1,"eventType":"coupon.redeemed","status":%q,"responseStatus":%s,"payload":%s,"createdAt":%q,"completedAt":%q,"attempt":1}`,
+			id, status, responseStatus, payload, at(0), at(200*time.Millisecond))
+	}
+
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodGet, "/v1/server/webhooks", http.StatusOK, `{"data":[
+		{"id":1,"eventType":"coupon.redeemed","url":"https://example.com/webhooks/playcamp"},
+		{"id":2,"eventType":"coupon.redeemed","url":"https://other.example.com/hook"},
+		{"id":3,"eventType":"payment.created","url":"https://example.com/webhooks/playcamp"}]}`)
+	fake.on(http.MethodGet, "/v1/server/webhooks/1/logs", http.StatusOK, `{"data":[`+strings.Join([]string{
+		logEntry("l1", "SUCCESS", "200", delivered),
+		logEntry("l2", "SUCCESS", "200", `{"events":[{"event":"coupon.redeemed","data":{"couponCode":"B"}}]}`),
+		logEntry("l3", "FAILED", "502", `{}`),
+		logEntry("l4", "RETRYING", "null", `{}`),
+	}, ",")+`]}`)
+	fake.on(http.MethodGet, "/v1/server/webhooks/2/logs", http.StatusOK, `{"data":[`+logEntry("l5", "SUCCESS", "200", `{"events":[]}`)+`]}`)
+	fake.on(http.MethodGet, "/v1/server/webhooks/3/logs", http.StatusNotFound, `{"code":"NOT_FOUND","message":"gone"}`)
+	a := newTestApp(t, fake, nil)
+
+	req := httptest.NewRequest(http.MethodPost, receiverPath, strings.NewReader(delivered))
+	req.Header.Set("X-Webhook-Signature", webhookutil.ConstructSignature([]byte(delivered), testWebhookSecret, nil))
+	a.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec := serve(a, http.MethodGet, "/api/webhooks/analytics?bucket=15m", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body %s", rec.Code, rec.Body)
+	}
+	var res webhookAnalytics
+	decodeData(t, rec, &res)
+
+	totals := res.Totals
+	if totals.Attempts != 5 || totals.Succeeded != 3 || totals.Failed != 2 || totals.SuccessRate != 0.6 {
+		t.Errorf("totals = %+v", totals)
+	}
+	if totals.FailureReasons["HTTP 502"] != 1 || totals.FailureReasons["no response"] != 1 {
+		t.Errorf("failure reasons = %v", totals.FailureReasons)
+	}
+	if totals.LatencyMs == nil || totals.LatencyMs.P50 != 200 {
+		t.Errorf("latency = %+v", totals.LatencyMs)
+	}
+	if len(res.Groups) != 1 || res.Groups[0].EventType != "coupon.redeemed" {
+		t.Errorf("groups = %+v", res.Groups)
+	}
+	if len(res.Missing) != 1 || res.Missing[0].LogID != "l2" {
+		t.Errorf("missing = %+v", res.Missing)
+	}
+	if len(res.Unavailable) != 1 || res.Unavailable[0].WebhookID != 3 {
+		t.Errorf("unavailable = %+v", res.Unavailable)
+	}
+
+	if rec := serve(a, http.MethodGet, "/api/webhooks/analytics?bucket=5s", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("short bucket: status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// When PUBLIC_BASE_URL is set, the server subscribes its own receiver to
+// PlayCamp on startup, so preview environments get webhooks without manual
+// steps. Only subscriptions on the same host are touched: one with the
+// receiver URL is kept (and re-activated), one with another URL on that host
+// is stale and moved to the receiver URL, and missing ones are created.
+
+// receiverPath is where the webhook receiver is mounted.
+const receiverPath = "/webhooks/playcamp"
+
+// webhookRegistration remembers the subscriptions ensured on startup.
+type webhookRegistration struct {
+	mu  sync.Mutex
+	ids []int
+	// secrets are the signing secrets of subscriptions created on startup.
+	// PlayCamp only returns a secret on create, so they are accepted in
+	// addition to the configured ones.
+	secrets []string
+}
+
+func (r *webhookRegistration) add(id int, secret string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ids = append(r.ids, id)
+	if secret != "" {
+		r.secrets = append(r.secrets, secret)
+	}
+}
+
+func (r *webhookRegistration) snapshot() (ids []int, secrets []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]int(nil), r.ids...), append([]string(nil), r.secrets...)
+}
+
+// receiverURL returns the public URL of the webhook receiver, or "" if no
+// public base URL is configured.
+func (c *config) receiverURL() string {
+	if c.Webhook.PublicBaseURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(c.Webhook.PublicBaseURL, "/") + receiverPath
+}
+
+// receiverEvents returns the event types the receiver is subscribed to.
+func (c *config) receiverEvents() []playcamp.WebhookEventType {
+	var events []playcamp.WebhookEventType
+	for _, e := range c.Webhook.Events {
+		events = append(events, playcamp.WebhookEventType(e))
+	}
+	if len(events) == 0 {
+		for _, e := range webhookEventData {
+			events = append(events, e.Event)
+		}
+	}
+	return events
+}
+
+// acceptedWebhookSecrets returns the configured secrets followed by those of
+// subscriptions created on startup.
+func (a *app) acceptedWebhookSecrets() []string {
+	_, registered := a.registration.snapshot()
+	return append(a.config().webhookSecrets(), registered...)
+}
+
+// webhookSecretConfigured reports whether deliveries are checked against a
+// real secret. Without one anyone can sign a delivery, so received events
+// must not change local state.
+func (a *app) webhookSecretConfigured() bool {
+	return len(a.acceptedWebhookSecrets()) > 0
+}
+
+// planReceiverRegistration plans the subscriptions that point events at
+// receiver. Subscriptions on other hosts belong to other deployments and are
+// left alone, and nothing is ever deleted.
+func planReceiverRegistration(current []playcamp.Webhook, receiver string, events []playcamp.WebhookEventType) []webhookSyncAction {
+	host := urlHost(receiver)
+	var own []playcamp.Webhook
+	for _, hook := range current {
+		if urlHost(hook.URL) == host {
+			own = append(own, hook)
+		}
+	}
+	desired := make([]webhookSpec, len(events))
+	for i, e := range events {
+		desired[i] = webhookSpec{EventType: e, URL: receiver}
+	}
+
+	var plan []webhookSyncAction
+	for _, action := range planWebhookSync(own, desired) {
+		if action.Action != syncDelete {
+			plan = append(plan, action)
+		}
+	}
+	return plan
+}
+
+func urlHost(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// registerReceiver makes sure the receiver is subscribed to every configured
+// event type.
+func (a *app) registerReceiver(ctx context.Context) error {
+	cfg := a.config()
+	receiver := cfg.receiverURL()
+	current, err := a.server.Webhooks.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	plan := planReceiverRegistration(current, receiver, cfg.receiverEvents())
+	failed := a.applyWebhookPlan(ctx, plan)
+	existing := 0
+	for _, action := range plan {
+		if action.Action != syncCreate {
+			existing++
+		}
+		log := a.webhookLog.With("action", action.Action, "id", action.ID, "event", string(action.EventType))
+		if action.Error != "" {
+			log.ErrorContext(ctx, "webhook registration failed", "error", action.Error)
+			continue
+		}
+		a.registration.add(action.ID, action.Secret)
+		if action.Changes != "" {
+			log = log.With("changes", action.Changes)
+		}
+		log.InfoContext(ctx, "webhook registered", "url", receiver)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d webhook registrations failed", failed, len(plan))
+	}
+	if existing > 0 && len(cfg.webhookSecrets()) == 0 {
+		a.webhookLog.WarnContext(ctx, "reusing existing webhook subscriptions but no secret is configured; set WEBHOOK_SECRETS to verify them", "subscriptions", existing)
+	}
+	return nil
+}
+
+// unregisterReceiver deletes the subscriptions ensured on startup when
+// webhook.removeOnShutdown is set.
+func (a *app) unregisterReceiver(ctx context.Context) error {
+	if !a.config().Webhook.RemoveOnShutdown {
+		return nil
+	}
+	ids, _ := a.registration.snapshot()
+	var errs []error
+	for _, id := range ids {
+		if err := a.server.Webhooks.Delete(ctx, id); err != nil {
+			errs = append(errs, fmt.Errorf("delete webhook %d: %w", id, err))
+			continue
+		}
+		a.webhookLog.InfoContext(ctx, "webhook unregistered", "id", id)
+	}
+	return errors.Join(errs...)
+}
+
+// startReceiverRegistration registers the receiver in the background and
+// arranges for it to be unregistered on shutdown.
+func (a *app) startReceiverRegistration() {
+	if a.config().receiverURL() == "" {
+		return
+	}
+	a.lifecycle.onShutdown("webhook-registration", a.unregisterReceiver)
+	a.lifecycle.goBackground("webhook-registration", func(ctx context.Context) {
+		if err := a.registerReceiver(ctx); err != nil {
+			a.webhookLog.Error("webhook registration failed", "error", err.Error())
+		}
+	})
+}
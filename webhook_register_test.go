@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+	"github.com/playcamp/playcamp-go-sdk/webhookutil"
+)
+
+func TestPlanReceiverRegistration(t *testing.T) {
+	const receiver = "https://pr-7.example.com/webhooks/playcamp"
+	current := []playcamp.Webhook{
+		{ID: 1, EventType: "coupon.redeemed", URL: receiver, IsActive: true},
+		{ID: 2, EventType: "payment.created", URL: "http://pr-7.example.com/hooks", IsActive: true},
+		{ID: 3, EventType: "payment.refunded", URL: "https://pr-8.example.com/webhooks/playcamp", IsActive: true},
+		{ID: 4, EventType: "coupon.redeemed", URL: "https://pr-7.example.com/old", IsActive: true},
+	}
+	events := []playcamp.WebhookEventType{"coupon.redeemed", "payment.created", "payment.refunded"}
+
+	var got []string
+	for _, a := range planReceiverRegistration(current, receiver, events) {
+		got = append(got, a.Action+" "+string(a.EventType)+" "+a.Changes)
+	}
+	want := []string{
+		"unchanged coupon.redeemed ",
+		"update payment.created url http://pr-7.example.com/hooks -> " + receiver,
+		"create payment.refunded ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("plan:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRegisterReceiver(t *testing.T) {
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodGet, "/v1/server/webhooks", http.StatusOK,
+		`{"data":[{"id":1,"eventType":"coupon.redeemed","url":"https://pr-7.example.com/webhooks/playcamp","isActive":false}]}`)
+	fake.on(http.MethodPut, "/v1/server/webhooks/1", http.StatusOK, `{"data":{"id":1}}`)
+	fake.on(http.MethodPost, "/v1/server/webhooks", http.StatusCreated, `{"data":{"id":2,"eventType":"payment.created","secret":"whsec_created"}}`)
+	fake.on(http.MethodDelete, "/v1/server/webhooks/1", http.StatusOK, `{"data":null}`)
+	fake.on(http.MethodDelete, "/v1/server/webhooks/2", http.StatusOK, `{"data":null}`)
+	a := newTestApp(t, fake, map[string]string{
+		"PUBLIC_BASE_URL":            "https://pr-7.example.com/",
+		"WEBHOOK_EVENTS":             "coupon.redeemed,payment.created",
+		"WEBHOOK_REMOVE_ON_SHUTDOWN": "true",
+	})
+
+	if err := a.registerReceiver(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	reqs := fake.received()
+	if len(reqs) != 3 || jsonBody(t, reqs[1])["isActive"] != true || jsonBody(t, reqs[2])["url"] != "https://pr-7.example.com/webhooks/playcamp" {
+		t.Fatalf("requests = %+v", reqs)
+	}
+
+	// Webhooks signed with the secret of the created subscription verify.
+	payload := []byte(`{"events":[]}`)
+	req := httptest.NewRequest(http.MethodPost, receiverPath, strings.NewReader(string(payload)))
+	req.Header.Set("X-Webhook-Signature", webhookutil.ConstructSignature(payload, "whsec_created", nil))
+	a.router.ServeHTTP(httptest.NewRecorder(), req)
+	if stored := a.receivedWebhooks.list(); len(stored) != 1 || !stored[0].Valid {
+		t.Errorf("stored = %+v", stored)
+	}
+
+	if err := a.unregisterReceiver(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var deleted []string
+	for _, r := range fake.received()[3:] {
+		deleted = append(deleted, r.Method+" "+r.Path)
+	}
+	if strings.Join(deleted, ", ") != "DELETE /v1/server/webhooks/1, DELETE /v1/server/webhooks/2" {
+		t.Errorf("shutdown requests = %v", deleted)
+	}
+}
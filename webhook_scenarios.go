@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenarios are scripted sequences of simulated webhooks, such as a sponsor
+// lifecycle, delivered to the receiver with delays between steps. Data comes
+// from the webhook templates with one seeded generator for the whole run, so
+// the same seed replays the same users, payments and refunds. Built-in
+// scenarios live in scenarios/*.yaml; others can be sent inline.
+
+//go:embed scenarios/*.yaml
+var scenarioFiles embed.FS
+
+const (
+	// maxScenarioDeliveries bounds the deliveries of one run.
+	maxScenarioDeliveries = 100
+	// maxScenarioDuration bounds the total delay of one run, after speed.
+	maxScenarioDuration = time.Minute
+)
+
+// webhookScenario is a scripted sequence of webhook deliveries.
+type webhookScenario struct {
+	Name        string         `yaml:"name" json:"name" validate:"required,max=64"`
+	Description string         `yaml:"description" json:"description,omitempty" validate:"max=500"`
+	Steps       []scenarioStep `yaml:"steps" json:"steps" validate:"required,max=50,dive"`
+}
+
+// scenarioStep delivers one event, Repeat times.
+type scenarioStep struct {
+	Event string `yaml:"event" json:"event" validate:"required,enum=webhookEventType"`
+	// Repeat is how many times the step is delivered. Defaults to 1.
+	Repeat int `yaml:"repeat" json:"repeat,omitempty" validate:"gte=0"`
+	// Delay is waited before each delivery of the step.
+	Delay      string `yaml:"delay" json:"delay,omitempty" validate:"duration"`
+	CallbackID string `yaml:"callbackId" json:"callbackId,omitempty" validate:"max=100"`
+	// Data overrides top-level fields of the generated data. Values may
+	// use "{{name}}" like the templates.
+	Data            map[string]any `yaml:"data" json:"data,omitempty"`
+	simulateOptions `yaml:",inline"`
+}
+
+func (s scenarioStep) times() int {
+	if s.Repeat < 1 {
+		return 1
+	}
+	return s.Repeat
+}
+
+func (s scenarioStep) delay() time.Duration {
+	d, _ := time.ParseDuration(s.Delay) // validated
+	return d
+}
+
+// runScenarioRequest runs a built-in scenario by name or an inline one.
+type runScenarioRequest struct {
+	Name     string           `json:"name,omitempty" validate:"max=64"`
+	Scenario *webhookScenario `json:"scenario,omitempty"`
+	// Seed makes the generated data repeatable.
+	Seed *int64 `json:"seed,omitempty"`
+	// IsTest marks the events as test events. Defaults to true; false is
+	// refused unless webhook.simulateLive is set.
+	IsTest *bool `json:"isTest,omitempty"`
+	// Speed divides every delay, e.g. 10 runs ten times faster. Defaults to 1.
+	Speed float64 `json:"speed,omitempty" validate:"gte=0"`
+}
+
+// scenarioDelivery is one delivery of a scenario run.
+type scenarioDelivery struct {
+	Step       int             `json:"step"`
+	Event      string          `json:"event"`
+	OffsetMs   int64           `json:"offsetMs"`
+	WebhookIDs []string        `json:"webhookIds"`
+	Valid      bool            `json:"valid"`
+	Error      string          `json:"error,omitempty"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type scenarioRunResult struct {
+	Scenario   string             `json:"scenario"`
+	Seed       int64              `json:"seed"`
+	Deliveries []scenarioDelivery `json:"deliveries"`
+	// Interrupted is set when the client went away or the server began
+	// shutting down before the run finished.
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// builtinScenarios parses scenarios/*.yaml, sorted by name.
+func builtinScenarios() ([]webhookScenario, error) {
+	names, err := fs.Glob(scenarioFiles, "scenarios/*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	var scenarios []webhookScenario
+	for _, name := range names {
+		raw, err := scenarioFiles.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		var s webhookScenario
+		dec := yaml.NewDecoder(bytes.NewReader(raw))
+		dec.KnownFields(true)
+		if err := dec.Decode(&s); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path.Base(name), err)
+		}
+		scenarios = append(scenarios, s)
+	}
+	sort.Slice(scenarios, func(i, j int) bool { return scenarios[i].Name < scenarios[j].Name })
+	return scenarios, nil
+}
+
+// checkScenario validates s beyond its tags: the run must stay within the
+// delivery and duration limits.
+func checkScenario(s *webhookScenario, speed float64) []problemField {
+	errs := validateStruct(s)
+	if len(errs) > 0 {
+		return errs
+	}
+	var deliveries int
+	var total time.Duration
+	for _, step := range s.Steps {
+		deliveries += step.times()
+		total += time.Duration(step.times()) * step.delay()
+	}
+	if deliveries > maxScenarioDeliveries {
+		errs = append(errs, problemField{Field: "steps", Message: fmt.Sprintf("deliver at most %d webhooks, got %d", maxScenarioDeliveries, deliveries)})
+	}
+	if scaled := time.Duration(float64(total) / speed); scaled > maxScenarioDuration {
+		errs = append(errs, problemField{Field: "steps", Message: fmt.Sprintf("delays add up to %s; keep them under %s or raise speed", scaled, maxScenarioDuration)})
+	}
+	return errs
+}
+
+// runScenario delivers the steps of s in order. It stops early if the
+// request is cancelled or the server starts shutting down.
+func (a *app) runScenario(r *http.Request, s *webhookScenario, seed int64, isTest bool, speed float64) (*scenarioRunResult, error) {
+	fake := newFakeData(seed)
+	result := &scenarioRunResult{Scenario: s.Name, Seed: seed, Deliveries: []scenarioDelivery{}}
+	start := time.Now()
+
+	for i, step := range s.Steps {
+		for n := 0; n < step.times(); n++ {
+			if d := time.Duration(float64(step.delay()) / speed); d > 0 {
+				select {
+				case <-r.Context().Done():
+					result.Interrupted = true
+					return result, nil
+				case <-a.lifecycle.drained():
+					result.Interrupted = true
+					return result, nil
+				case <-time.After(d):
+				}
+			}
+
+			data, err := fake.eventData(playcamp.WebhookEventType(step.Event), step.Data)
+			if err != nil {
+				return nil, fmt.Errorf("steps[%d]: %w", i, err)
+			}
+			sim, err := a.simulate(r, []simulatedEvent{{Event: step.Event, CallbackID: step.CallbackID, Data: data}}, isTest, step.simulateOptions)
+			if err != nil {
+				return nil, fmt.Errorf("steps[%d]: %w", i, err)
+			}
+
+			delivery := scenarioDelivery{
+				Step:       i + 1,
+				Event:      step.Event,
+				OffsetMs:   time.Since(start).Milliseconds(),
+				WebhookIDs: []string{},
+				Data:       data,
+			}
+			for _, wh := range sim.Stored {
+				delivery.WebhookIDs = append(delivery.WebhookIDs, wh.ID)
+				delivery.Valid, delivery.Error = wh.Valid, wh.Error
+			}
+			result.Deliveries = append(result.Deliveries, delivery)
+		}
+	}
+	return result, nil
+}
+
+// --- Template and Scenario Endpoints ---
+
+// handleListWebhookTemplates handles GET /api/webhooks/templates
+func (a *app) handleListWebhookTemplates(w http.ResponseWriter, r *http.Request) {
+	seed := randomSeed()
+	if v, err := strconv.ParseInt(r.URL.Query().Get("seed"), 10, 64); err == nil {
+		seed = v
+	}
+	fake := newFakeData(seed)
+
+	var templates []webhookTemplateInfo
+	for _, e := range webhookEventData {
+		example, err := fake.eventData(e.Event, nil)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		templates = append(templates, webhookTemplateInfo{
+			Event:    string(e.Event),
+			Template: json.RawMessage(webhookTemplates[e.Event]),
+			Example:  example,
+		})
+	}
+	writeJSON(w, http.StatusOK, templates)
+}
+
+// handleListScenarios handles GET /api/webhooks/scenarios
+func (a *app) handleListScenarios(w http.ResponseWriter, r *http.Request) {
+	scenarios, err := builtinScenarios()
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, scenarios)
+}
+
+// handleRunScenario handles POST /api/webhooks/scenarios/run
+func (a *app) handleRunScenario(w http.ResponseWriter, r *http.Request) {
+	var body runScenarioRequest
+	if !a.bind(w, r, &body) {
+		return
+	}
+
+	scenario := body.Scenario
+	switch {
+	case scenario != nil && body.Name != "":
+		writeError(w, r, http.StatusBadRequest, "send either name or scenario, not both")
+		return
+	case scenario == nil:
+		builtins, err := builtinScenarios()
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for i := range builtins {
+			if builtins[i].Name == body.Name {
+				scenario = &builtins[i]
+			}
+		}
+		if scenario == nil {
+			writeError(w, r, http.StatusNotFound, fmt.Sprintf("no scenario named %q", body.Name))
+			return
+		}
+	}
+
+	speed := body.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	if errs := checkScenario(scenario, speed); len(errs) > 0 {
+		p := newProblem(r, problemValidation, http.StatusUnprocessableEntity, "invalid scenario")
+		p.Errors = errs
+		writeProblem(w, p)
+		return
+	}
+
+	seed := randomSeed()
+	if body.Seed != nil {
+		seed = *body.Seed
+	}
+	isTest := body.IsTest == nil || *body.IsTest
+	if a.rejectLiveSimulation(w, r, isTest) {
+		return
+	}
+	result, err := a.runScenario(r, scenario, seed, isTest, speed)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestBuiltinScenariosAreValid(t *testing.T) {
+	scenarios, err := builtinScenarios()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatal("no built-in scenarios")
+	}
+	for _, s := range scenarios {
+		for _, err := range checkScenario(&s, 1) {
+			t.Errorf("%s: %s: %s", s.Name, err.Field, err.Message)
+		}
+	}
+}
+
+func TestRunScenario(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	rec := serve(a, http.MethodPost, "/api/webhooks/scenarios/run", `{"name":"sponsor-lifecycle","seed":42,"speed":1000}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; body %s", rec.Code, rec.Body)
+	}
+	var res scenarioRunResult
+	decodeData(t, rec, &res)
+	if res.Seed != 42 || len(res.Deliveries) != 6 {
+		t.Fatalf("result = %+v", res)
+	}
+	for _, d := range res.Deliveries {
+		if !d.Valid || len(d.WebhookIDs) != 1 {
+			t.Errorf("step %d %s: %+v", d.Step, d.Event, d)
+		}
+	}
+	if got := len(a.receivedWebhooks.list()); got != 6 {
+		t.Errorf("received = %d, want 6", got)
+	}
+
+	// The same seed replays the same data.
+	var again scenarioRunResult
+	decodeData(t, serve(a, http.MethodPost, "/api/webhooks/scenarios/run", `{"name":"sponsor-lifecycle","seed":42,"speed":1000}`), &again)
+	for i := range res.Deliveries {
+		if string(res.Deliveries[i].Data) != string(again.Deliveries[i].Data) {
+			t.Errorf("delivery %d: %s != %s", i, res.Deliveries[i].Data, again.Deliveries[i].Data)
+		}
+	}
+}
+
+func TestRunInlineScenario(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantField  string
+	}{
+		{"valid", `{"scenario":{"name":"x","steps":[{"event":"payment.created","data":{"amount":1}},{"event":"sponsor.ended","badSignature":true}]}}`, http.StatusOK, ""},
+		{"unknown event", `{"scenario":{"name":"x","steps":[{"event":"payment.lost"}]}}`, http.StatusBadRequest, "scenario.steps[0].event"},
+		{"bad delay", `{"scenario":{"name":"x","steps":[{"event":"payment.created","delay":"soon"}]}}`, http.StatusBadRequest, "scenario.steps[0].delay"},
+		{"too slow", `{"scenario":{"name":"x","steps":[{"event":"payment.created","repeat":3,"delay":"30s"}]}}`, http.StatusUnprocessableEntity, "steps"},
+		{"too many", `{"scenario":{"name":"x","steps":[{"event":"payment.created","repeat":101}]}}`, http.StatusUnprocessableEntity, "steps"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t, newFakePlayCamp(t), nil)
+			rec := serve(a, http.MethodPost, "/api/webhooks/scenarios/run", tc.body)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d; body %s", rec.Code, rec.Body)
+			}
+			if tc.wantField != "" {
+				if p := decodeProblem(t, rec); len(p.Errors) == 0 || p.Errors[0].Field != tc.wantField {
+					t.Errorf("errors = %+v", p.Errors)
+				}
+				return
+			}
+			var res scenarioRunResult
+			decodeData(t, rec, &res)
+			if len(res.Deliveries) != 2 || !res.Deliveries[0].Valid || res.Deliveries[1].Valid {
+				t.Errorf("deliveries = %+v", res.Deliveries)
+			}
+		})
+	}
+}
+
+func TestRunUnknownScenario(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	if rec := serve(a, http.MethodPost, "/api/webhooks/scenarios/run", `{"name":"nope"}`); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Webhook data is stored as raw JSON, so a payload change on PlayCamp's side
+// would go unnoticed. checkEventSchema holds each event's data to the SDK type
+// documented for it (see webhookEventData): every field without omitempty must
+// be present, values must decode into the field's type, and fields the type
+// does not know are reported too.
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// eventDataType returns the data type for event, or nil if event is unknown.
+func eventDataType(event string) reflect.Type {
+	for _, e := range webhookEventData {
+		if string(e.Event) == event {
+			return reflect.TypeOf(e.Data)
+		}
+	}
+	return nil
+}
+
+// checkEventSchema returns every way data differs from the schema of event.
+// Field paths are prefixed with prefix, e.g. "events[0].data".
+func checkEventSchema(prefix, event string, data json.RawMessage) []problemField {
+	t := eventDataType(event)
+	if t == nil {
+		return []problemField{{Field: strings.TrimSuffix(prefix, ".data") + ".event", Message: fmt.Sprintf("unknown event type %q", event)}}
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return []problemField{{Field: prefix, Message: "must be an object"}}
+	}
+
+	var out []problemField
+	known := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := jsonFieldName(f)
+		known[name] = true
+		path := prefix + "." + name
+
+		raw, ok := fields[name]
+		if !ok {
+			if !strings.Contains(f.Tag.Get("json"), ",omitempty") {
+				out = append(out, problemField{Field: path, Message: "required"})
+			}
+			continue
+		}
+		if string(raw) == "null" {
+			if f.Type.Kind() != reflect.Pointer && f.Type != rawMessageType {
+				out = append(out, problemField{Field: path, Message: "must not be null"})
+			}
+			continue
+		}
+		if err := json.Unmarshal(raw, reflect.New(f.Type).Interface()); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				out = append(out, problemField{Field: path, Message: "must be " + jsonTypeName(f.Type)})
+			} else {
+				out = append(out, problemField{Field: path, Message: err.Error()})
+			}
+		}
+	}
+
+	var unknown []string
+	for name := range fields {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		out = append(out, problemField{Field: prefix + "." + name, Message: "unknown field"})
+	}
+	return out
+}
+
+// checkWebhookSchema checks the data of every event.
+func checkWebhookSchema(events []webhookEvent) []problemField {
+	var out []problemField
+	for i, evt := range events {
+		out = append(out, checkEventSchema(fmt.Sprintf("events[%d].data", i), evt.Event, evt.Data)...)
+	}
+	return out
+}
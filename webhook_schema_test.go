@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/playcamp/playcamp-go-sdk/webhookutil"
+)
+
+// TestWebhookGoldenPayloads sends testdata/webhooks/<event>.json, one per
+// event type, through the receiver and requires it to match its schema. Add a
+// file here when PlayCamp adds an event, and update it when a shape changes.
+func TestWebhookGoldenPayloads(t *testing.T) {
+	for _, e := range webhookEventData {
+		event := string(e.Event)
+		t.Run(event, func(t *testing.T) {
+			payload, err := os.ReadFile(filepath.Join("testdata", "webhooks", event+".json"))
+			if err != nil {
+				t.Fatalf("missing golden payload: %v", err)
+			}
+			a := newTestApp(t, newFakePlayCamp(t), nil)
+
+			req := httptest.NewRequest(http.MethodPost, "/webhooks/playcamp", bytes.NewReader(payload))
+			req.Header.Set("X-Webhook-Signature", webhookutil.ConstructSignature(payload, testWebhookSecret, nil))
+			a.router.ServeHTTP(httptest.NewRecorder(), req)
+
+			stored := a.receivedWebhooks.list()
+			if len(stored) != 1 || !stored[0].Valid {
+				t.Fatalf("stored = %+v", stored)
+			}
+			wh := stored[0]
+			if len(wh.Events) == 0 || wh.Events[0].Event != event {
+				t.Errorf("events = %+v", wh.Events)
+			}
+			for _, e := range wh.SchemaErrors {
+				t.Errorf("%s: %s", e.Field, e.Message)
+			}
+		})
+	}
+}
+
+func TestCheckEventSchema(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		data  string
+		want  []string // "field: message"
+	}{
+		{"valid", "sponsor.created", `{"userId":"u1","campaignId":"c1","creatorKey":"neo"}`, nil},
+		{"optional field absent", "payment.created", `{"transactionId":"tx","userId":"u1","amount":1,"currency":"KRW"}`, nil},
+		{"optional field null", "payment.created", `{"transactionId":"tx","userId":"u1","amount":1,"currency":"KRW","creatorKey":null}`, nil},
+		{"missing field", "payment.refunded", `{"transactionId":"tx"}`, []string{"events[0].data.userId: required"}},
+		{"wrong type", "coupon.redeemed", `{"couponCode":"C","userId":"u1","usageId":"88","reward":null}`, []string{"events[0].data.usageId: must be an integer"}},
+		{"null required", "sponsor.ended", `{"userId":null,"campaignId":"c1","creatorKey":"neo"}`, []string{"events[0].data.userId: must not be null"}},
+		{"unknown field", "payment.refunded", `{"transactionId":"tx","userId":"u1","reason":"chargeback"}`, []string{"events[0].data.reason: unknown field"}},
+		{"not an object", "payment.refunded", `"tx"`, []string{"events[0].data: must be an object"}},
+		{"unknown event", "payment.deleted", `{}`, []string{`events[0].event: unknown event type "payment.deleted"`}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, e := range checkWebhookSchema([]webhookEvent{{Event: tc.event, Data: json.RawMessage(tc.data)}}) {
+				got = append(got, e.Field+": "+e.Message)
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Errorf("errors = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+	"github.com/playcamp/playcamp-go-sdk/webhookutil"
+)
+
+// Simulated webhooks are built as PlayCamp would send them: a payload
+// envelope, signed with the configured secret, handed to the receiver like
+// any other delivery. Options break the delivery in the ways real ones go
+// wrong, so the verification path is exercised end to end. Events without
+// data get it from the templates in webhook_templates.go.
+
+// staleSignatureAge is how old a stale timestamped signature is; the SDK
+// rejects signatures older than five minutes.
+const staleSignatureAge = 10 * time.Minute
+
+type simulateWebhookRequest struct {
+	// Event and Data simulate a single event; Events simulates several in
+	// one delivery.
+	Event  string           `json:"event,omitempty" validate:"enum=webhookEventType"`
+	Data   json.RawMessage  `json:"data,omitempty"`
+	Events []simulatedEvent `json:"events,omitempty" validate:"dive"`
+	// IsTest marks the events as test events. Defaults to true; false is
+	// refused unless webhook.simulateLive is set.
+	IsTest *bool `json:"isTest,omitempty"`
+	// Seed makes the generated data of events without data repeatable.
+	Seed *int64 `json:"seed,omitempty"`
+	simulateOptions
+}
+
+// simulateOptions change how a simulated delivery is signed and sent.
+type simulateOptions struct {
+	// Timestamped signs with the "t=...,v1=..." format instead of a plain
+	// HMAC.
+	Timestamped bool `yaml:"timestamped" json:"timestamped,omitempty"`
+	// BadSignature signs with the wrong secret.
+	BadSignature bool `yaml:"badSignature" json:"badSignature,omitempty"`
+	// StaleTimestamp signs with a timestamp outside the tolerance window.
+	StaleTimestamp bool `yaml:"staleTimestamp" json:"staleTimestamp,omitempty"`
+	// Duplicate delivers the same body and signature twice, as a retry would.
+	Duplicate bool `yaml:"duplicate" json:"duplicate,omitempty"`
+}
+
+type simulatedEvent struct {
+	Event      string          `json:"event" validate:"required,enum=webhookEventType"`
+	CallbackID string          `json:"callbackId,omitempty" validate:"max=100"`
+	Data       json.RawMessage `json:"data,omitempty"`
+}
+
+// simulatedDelivery is the receiver's handling of one simulated delivery.
+type simulatedDelivery struct {
+	Status    int    `json:"status"`
+	WebhookID string `json:"webhookId,omitempty"`
+}
+
+type simulateWebhookResult struct {
+	Seed       int64               `json:"seed,omitempty"`
+	Payload    json.RawMessage     `json:"payload"`
+	Signature  string              `json:"signature"`
+	Deliveries []simulatedDelivery `json:"deliveries"`
+	// Stored are the webhooks as the receiver recorded them.
+	Stored []receivedWebhook `json:"stored"`
+}
+
+// events returns the simulated events, generating data from the templates
+// for those without any.
+func (req simulateWebhookRequest) events(fake *fakeData) ([]simulatedEvent, error) {
+	events := req.Events
+	if req.Event != "" {
+		events = append([]simulatedEvent{{Event: req.Event, Data: req.Data}}, events...)
+	}
+	for i, e := range events {
+		if len(e.Data) > 0 {
+			continue
+		}
+		data, err := fake.eventData(playcamp.WebhookEventType(e.Event), nil)
+		if err != nil {
+			return nil, err
+		}
+		events[i].Data = data
+	}
+	return events, nil
+}
+
+// buildWebhookPayload wraps events in the envelope PlayCamp delivers.
+func buildWebhookPayload(events []simulatedEvent, isTest bool, now time.Time) ([]byte, error) {
+	var payload playcamp.WebhookPayload
+	for _, e := range events {
+		payload.Events = append(payload.Events, playcamp.WebhookEvent{
+			Event:      playcamp.WebhookEventType(e.Event),
+			Timestamp:  now.UTC().Format("2006-01-02T15:04:05.000Z"),
+			CallbackID: e.CallbackID,
+			IsTest:     playcamp.Bool(isTest),
+			Data:       e.Data,
+		})
+	}
+	return json.Marshal(payload)
+}
+
+// signSimulatedWebhook signs payload the way opts ask for.
+func (a *app) signSimulatedWebhook(opts simulateOptions, payload []byte, now time.Time) string {
+	secret := ""
+	if secrets := a.acceptedWebhookSecrets(); len(secrets) > 0 {
+		secret = secrets[0]
+	}
+	if opts.BadSignature {
+		secret += "-wrong"
+	}
+	if !opts.Timestamped && !opts.StaleTimestamp {
+		return webhookutil.ConstructSignature(payload, secret, nil)
+	}
+	ts := now
+	if opts.StaleTimestamp {
+		ts = now.Add(-staleSignatureAge)
+	}
+	return webhookutil.ConstructSignature(payload, secret, &webhookutil.SignatureOptions{Timestamped: true, Timestamp: ts.Unix()})
+}
+
+// simulate builds a payload of events, signs it and delivers it to the
+// receiver, twice if opts.Duplicate is set.
+func (a *app) simulate(r *http.Request, events []simulatedEvent, isTest bool, opts simulateOptions) (*simulateWebhookResult, error) {
+	now := time.Now()
+	payload, err := buildWebhookPayload(events, isTest, now)
+	if err != nil {
+		return nil, err
+	}
+	result := &simulateWebhookResult{
+		Payload:   payload,
+		Signature: a.signSimulatedWebhook(opts, payload, now),
+	}
+
+	deliveries := 1
+	if opts.Duplicate {
+		deliveries = 2
+	}
+	for i := 0; i < deliveries; i++ {
+		delivery := a.deliverSimulatedWebhook(r, payload, result.Signature)
+		result.Deliveries = append(result.Deliveries, delivery)
+		for _, wh := range a.receivedWebhooks.list() {
+			if wh.ID == delivery.WebhookID {
+				result.Stored = append(result.Stored, wh)
+			}
+		}
+	}
+	return result, nil
+}
+
+// handleSimulateWebhook handles POST /api/webhooks/simulate
+func (a *app) handleSimulateWebhook(w http.ResponseWriter, r *http.Request) {
+	var body simulateWebhookRequest
+	if !a.bind(w, r, &body) {
+		return
+	}
+	seed := randomSeed()
+	if body.Seed != nil {
+		seed = *body.Seed
+	}
+	events, err := body.events(newFakeData(seed))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(events) == 0 {
+		p := newProblem(r, problemInvalidInput, http.StatusBadRequest, "request body failed validation")
+		p.Errors = []problemField{{Field: "event", Message: "is required unless events is set"}}
+		writeProblem(w, p)
+		return
+	}
+
+	isTest := body.IsTest == nil || *body.IsTest
+	if a.rejectLiveSimulation(w, r, isTest) {
+		return
+	}
+
+	result, err := a.simulate(r, events, isTest, body.simulateOptions)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "event data must be valid JSON")
+		return
+	}
+	result.Seed = seed
+	writeJSON(w, http.StatusOK, result)
+}
+
+// rejectLiveSimulation writes a 403 and returns true when live events are
+// asked for but webhook.simulateLive is off. Live events reach the live
+// revenue ledger and sponsor state, so any /api caller could forge them.
+func (a *app) rejectLiveSimulation(w http.ResponseWriter, r *http.Request, isTest bool) bool {
+	if isTest || a.config().Webhook.SimulateLive {
+		return false
+	}
+	writeError(w, r, http.StatusForbidden, "simulating live events is disabled; set webhook.simulateLive to allow isTest false")
+	return true
+}
+
+// deliverSimulatedWebhook hands payload to the receiver, so it goes through
+// the same verification and processing as a real delivery. The delivery
+// keeps r's request ID so the logs line up, but not its cancellation.
+func (a *app) deliverSimulatedWebhook(r *http.Request, payload []byte, signature string) simulatedDelivery {
+	wh := a.receiveWebhook(context.WithoutCancel(r.Context()), payload, signature)
+	return simulatedDelivery{Status: http.StatusOK, WebhookID: wh.ID}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+func TestWebhookTemplatesMatchSchema(t *testing.T) {
+	for _, seed := range []int64{1, 2, 3} {
+		fake := newFakeData(seed)
+		for _, e := range webhookEventData {
+			data, err := fake.eventData(e.Event, nil)
+			if err != nil {
+				t.Errorf("%s: %v", e.Event, err)
+				continue
+			}
+			for _, err := range checkEventSchema("data", string(e.Event), data) {
+				t.Errorf("seed %d: %s: %s: %s", seed, e.Event, err.Field, err.Message)
+			}
+		}
+	}
+}
+
+func TestFakeDataIsRepeatable(t *testing.T) {
+	render := func(seed int64) string {
+		fake := newFakeData(seed)
+		var out []string
+		for _, event := range []playcamp.WebhookEventType{playcamp.WebhookEventPaymentCreated, playcamp.WebhookEventSponsorChanged, playcamp.WebhookEventPaymentRefunded} {
+			data, err := fake.eventData(event, map[string]any{"note": "{{creatorKey}}"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, string(data))
+		}
+		return strings.Join(out, "\n")
+	}
+	if a, b := render(7), render(7); a != b {
+		t.Errorf("same seed rendered differently:\n%s\n%s", a, b)
+	}
+
+	fake := newFakeData(7)
+	var payment, changed, refund struct {
+		TransactionID string `json:"transactionId"`
+		CreatorKey    string `json:"creatorKey"`
+		OldCreatorKey string `json:"oldCreatorKey"`
+		NewCreatorKey string `json:"newCreatorKey"`
+		Note          string `json:"note"`
+	}
+	for _, step := range []struct {
+		event playcamp.WebhookEventType
+		dst   any
+	}{
+		{playcamp.WebhookEventPaymentCreated, &payment},
+		{playcamp.WebhookEventSponsorChanged, &changed},
+		{playcamp.WebhookEventPaymentRefunded, &refund},
+	} {
+		data, _ := fake.eventData(step.event, map[string]any{"note": "{{creatorKey}}"})
+		json.Unmarshal(data, step.dst)
+	}
+	if changed.OldCreatorKey != payment.CreatorKey || changed.NewCreatorKey == payment.CreatorKey {
+		t.Errorf("sponsor.changed = %+v after payment by %q", changed, payment.CreatorKey)
+	}
+	if refund.TransactionID != payment.TransactionID || refund.Note != changed.NewCreatorKey {
+		t.Errorf("refund = %+v, want transaction %q by %q", refund, payment.TransactionID, changed.NewCreatorKey)
+	}
+}
+
+func TestSimulateWebhook(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		wantValid bool
+		wantError string
+		wantCount int
+	}{
+		{"template data", `{"event":"coupon.redeemed"}`, true, "", 1},
+		{"several events", `{"events":[{"event":"payment.created"},{"event":"sponsor.created","callbackId":"cb-1"}],"timestamped":true}`, true, "", 1},
+		{"bad signature", `{"event":"payment.refunded","badSignature":true}`, false, "", 1},
+		{"stale timestamp", `{"event":"sponsor.ended","staleTimestamp":true}`, false, "tolerance", 1},
+		{"duplicate", `{"event":"payment.created","duplicate":true}`, true, "", 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t, newFakePlayCamp(t), nil)
+			rec := serve(a, http.MethodPost, "/api/webhooks/simulate", tc.body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d; body %s", rec.Code, rec.Body)
+			}
+			var res simulateWebhookResult
+			decodeData(t, rec, &res)
+			if len(res.Deliveries) != tc.wantCount || len(res.Stored) != tc.wantCount {
+				t.Fatalf("deliveries = %+v, stored = %d", res.Deliveries, len(res.Stored))
+			}
+
+			wh := res.Stored[0]
+			if wh.Valid != tc.wantValid || !strings.Contains(wh.Error, tc.wantError) {
+				t.Errorf("valid = %v, error = %q", wh.Valid, wh.Error)
+			}
+			if !tc.wantValid {
+				return
+			}
+			for _, e := range wh.Events {
+				if e.IsTest == nil || !*e.IsTest {
+					t.Errorf("%s: isTest = %v", e.Event, e.IsTest)
+				}
+			}
+			if len(wh.SchemaErrors) > 0 {
+				t.Errorf("schema errors = %+v", wh.SchemaErrors)
+			}
+			if tc.wantCount == 2 && res.Stored[1].DuplicateOf != wh.ID {
+				t.Errorf("second delivery duplicateOf = %q, want %q", res.Stored[1].DuplicateOf, wh.ID)
+			}
+		})
+	}
+}
+
+func TestSimulateLiveEvents(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		target     string
+		body       string
+		wantStatus int
+	}{
+		{"simulate refused", nil, "/api/webhooks/simulate", `{"event":"payment.created","isTest":false}`, http.StatusForbidden},
+		{"scenario refused", nil, "/api/webhooks/scenarios/run", `{"name":"sponsor-lifecycle","speed":1000,"isTest":false}`, http.StatusForbidden},
+		{"test events allowed", nil, "/api/webhooks/simulate", `{"event":"payment.created","isTest":true}`, http.StatusOK},
+		{"simulate allowed by config", map[string]string{"WEBHOOK_SIMULATE_LIVE": "true"}, "/api/webhooks/simulate", `{"event":"payment.created","isTest":false}`, http.StatusOK},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := newTestApp(t, newFakePlayCamp(t), tc.env)
+			rec := serve(a, http.MethodPost, tc.target, tc.body)
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d; body %s", rec.Code, rec.Body)
+			}
+			stored := a.receivedWebhooks.list()
+			if tc.wantStatus != http.StatusOK {
+				if len(stored) != 0 {
+					t.Errorf("stored %d webhooks", len(stored))
+				}
+				return
+			}
+			live := strings.Contains(tc.body, `"isTest":false`)
+			if e := stored[0].Events[0]; e.IsTest == nil || *e.IsTest == live {
+				t.Errorf("isTest = %v, want %v", e.IsTest, !live)
+			}
+		})
+	}
+}
+
+func TestSimulateWebhookRequiresEvent(t *testing.T) {
+	a := newTestApp(t, newFakePlayCamp(t), nil)
+	rec := serve(a, http.MethodPost, "/api/webhooks/simulate", `{"isTest":false}`)
+	if rec.Code != http.StatusBadRequest || decodeProblem(t, rec).Errors[0].Field != "event" {
+		t.Errorf("status = %d; body %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+	"gopkg.in/yaml.v3"
+)
+
+// Webhook subscriptions can be managed as code: a desired-state file lists
+// them, and a sync diffs it against Webhooks.List and creates, updates and
+// deletes subscriptions until PlayCamp matches. See webhooks.example.yaml.
+
+// webhookSyncRequest is the desired set of webhook subscriptions.
+type webhookSyncRequest struct {
+	// Environment, when set, must match the SDK environment, so a live file
+	// is never applied to sandbox or the other way round.
+	Environment string        `yaml:"environment" json:"environment,omitempty" validate:"enum=environment"`
+	Webhooks    []webhookSpec `yaml:"webhooks" json:"webhooks" validate:"dive"`
+}
+
+// webhookSpec is one desired subscription. Settings left out are not
+// managed: they keep PlayCamp's defaults on create and are never changed.
+type webhookSpec struct {
+	EventType  playcamp.WebhookEventType `yaml:"eventType" json:"eventType" validate:"required,enum=webhookEventType"`
+	URL        string                    `yaml:"url" json:"url" validate:"required,url,max=2048"`
+	RetryCount *int                      `yaml:"retryCount" json:"retryCount,omitempty" validate:"gte=0"`
+	TimeoutMs  *int                      `yaml:"timeoutMs" json:"timeoutMs,omitempty" validate:"positive"`
+	// IsActive defaults to true.
+	IsActive *bool `yaml:"isActive" json:"isActive,omitempty"`
+}
+
+func (s webhookSpec) active() bool {
+	return s.IsActive == nil || *s.IsActive
+}
+
+// Sync actions.
+const (
+	syncCreate    = "create"
+	syncUpdate    = "update"
+	syncDelete    = "delete"
+	syncUnchanged = "unchanged"
+)
+
+// webhookSyncAction is one step of a sync plan.
+type webhookSyncAction struct {
+	Action    string                    `json:"action"`
+	ID        int                       `json:"id,omitempty"`
+	EventType playcamp.WebhookEventType `json:"eventType"`
+	URL       string                    `json:"url"`
+	// Changes describes an update, e.g. "retryCount 3 -> 5".
+	Changes string `json:"changes,omitempty"`
+	// Secret is the signing secret of a created subscription. PlayCamp only
+	// returns it once.
+	Secret string `json:"secret,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	spec   webhookSpec
+	update playcamp.UpdateWebhookParams
+}
+
+// webhookSyncResult is a plan, and after applying it, the outcome.
+type webhookSyncResult struct {
+	Environment string              `json:"environment"`
+	DryRun      bool                `json:"dryRun"`
+	Actions     []webhookSyncAction `json:"actions"`
+	Failed      int                 `json:"failed"`
+}
+
+// loadWebhookSpec reads a desired-state file and validates it.
+func loadWebhookSpec(path string) (*webhookSyncRequest, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var req webhookSyncRequest
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&req); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &req, nil
+}
+
+// checkWebhookSync validates req beyond its tags: no subscription may be
+// listed twice, and the environment must match.
+func checkWebhookSync(req *webhookSyncRequest, environment string) []problemField {
+	errs := validateStruct(req)
+	seen := map[string]int{}
+	for i, spec := range req.Webhooks {
+		key := string(spec.EventType) + " " + spec.URL
+		if j, ok := seen[key]; ok {
+			errs = append(errs, problemField{
+				Field:   fmt.Sprintf("webhooks[%d]", i),
+				Message: fmt.Sprintf("duplicates webhooks[%d]", j),
+			})
+		}
+		seen[key] = i
+	}
+	if req.Environment != "" && req.Environment != environment {
+		errs = append(errs, problemField{
+			Field:   "environment",
+			Message: fmt.Sprintf("file is for %s but the SDK is configured for %s", req.Environment, environment),
+		})
+	}
+	return errs
+}
+
+// planWebhookSync diffs current subscriptions against desired ones. A desired
+// subscription matches an existing one with the same event type and URL, or
+// failing that, any remaining one with the same event type, whose URL is then
+// updated. Unmatched desired subscriptions are created and unmatched
+// existing ones deleted.
+func planWebhookSync(current []playcamp.Webhook, desired []webhookSpec) []webhookSyncAction {
+	sort.Slice(current, func(i, j int) bool { return current[i].ID < current[j].ID })
+	matched := make([]bool, len(current))
+	pairs := make([]int, len(desired))
+	for i := range pairs {
+		pairs[i] = -1
+	}
+	match := func(sameURL bool) {
+		for i, spec := range desired {
+			if pairs[i] >= 0 {
+				continue
+			}
+			for j, hook := range current {
+				if !matched[j] && hook.EventType == spec.EventType && (!sameURL || hook.URL == spec.URL) {
+					matched[j], pairs[i] = true, j
+					break
+				}
+			}
+		}
+	}
+	match(true)
+	match(false)
+
+	var plan []webhookSyncAction
+	for i, spec := range desired {
+		action := webhookSyncAction{EventType: spec.EventType, URL: spec.URL, spec: spec}
+		if pairs[i] < 0 {
+			action.Action = syncCreate
+			plan = append(plan, action)
+			continue
+		}
+		hook := current[pairs[i]]
+		action.ID = hook.ID
+		action.update, action.Changes = webhookDiff(hook, spec)
+		action.Action = syncUnchanged
+		if action.Changes != "" {
+			action.Action = syncUpdate
+		}
+		plan = append(plan, action)
+	}
+	for j, hook := range current {
+		if !matched[j] {
+			plan = append(plan, webhookSyncAction{Action: syncDelete, ID: hook.ID, EventType: hook.EventType, URL: hook.URL})
+		}
+	}
+	return plan
+}
+
+// webhookDiff returns the update that makes hook match spec and a summary of
+// it, or an empty summary if they already match.
+func webhookDiff(hook playcamp.Webhook, spec webhookSpec) (playcamp.UpdateWebhookParams, string) {
+	var params playcamp.UpdateWebhookParams
+	var changes []string
+	if hook.URL != spec.URL {
+		params.URL = playcamp.String(spec.URL)
+		changes = append(changes, fmt.Sprintf("url %s -> %s", hook.URL, spec.URL))
+	}
+	if hook.IsActive != spec.active() {
+		params.IsActive = playcamp.Bool(spec.active())
+		changes = append(changes, fmt.Sprintf("isActive %t -> %t", hook.IsActive, spec.active()))
+	}
+	if spec.RetryCount != nil && hook.RetryCount != *spec.RetryCount {
+		params.RetryCount = spec.RetryCount
+		changes = append(changes, fmt.Sprintf("retryCount %d -> %d", hook.RetryCount, *spec.RetryCount))
+	}
+	if spec.TimeoutMs != nil && hook.TimeoutMs != *spec.TimeoutMs {
+		params.TimeoutMs = spec.TimeoutMs
+		changes = append(changes, fmt.Sprintf("timeoutMs %d -> %d", hook.TimeoutMs, *spec.TimeoutMs))
+	}
+	return params, strings.Join(changes, ", ")
+}
+
+// syncWebhooks plans a sync of req against PlayCamp and, unless dryRun,
+// applies it.
+func (a *app) syncWebhooks(ctx context.Context, req *webhookSyncRequest, dryRun bool) (*webhookSyncResult, error) {
+	current, err := a.server.Webhooks.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &webhookSyncResult{
+		Environment: a.config().effectiveEnvironment(),
+		DryRun:      dryRun,
+		Actions:     planWebhookSync(current, req.Webhooks),
+	}
+	if !dryRun {
+		result.Failed = a.applyWebhookPlan(ctx, result.Actions)
+	}
+	return result, nil
+}
+
+// applyWebhookPlan runs the actions of a plan and returns how many failed.
+// Failed steps are recorded on their action and the rest still run.
+func (a *app) applyWebhookPlan(ctx context.Context, actions []webhookSyncAction) int {
+	sdk := a.server
+	failed := 0
+	for i := range actions {
+		action := &actions[i]
+		var err error
+		switch action.Action {
+		case syncCreate:
+			var hook *playcamp.WebhookWithSecret
+			hook, err = sdk.Webhooks.Create(ctx, playcamp.CreateWebhookParams{
+				EventType:  action.spec.EventType,
+				URL:        action.spec.URL,
+				RetryCount: action.spec.RetryCount,
+				TimeoutMs:  action.spec.TimeoutMs,
+			})
+			if err == nil {
+				action.ID, action.Secret = hook.ID, hook.Secret
+				if !action.spec.active() {
+					_, err = sdk.Webhooks.Update(ctx, hook.ID, playcamp.UpdateWebhookParams{IsActive: playcamp.Bool(false)})
+				}
+			}
+		case syncUpdate:
+			_, err = sdk.Webhooks.Update(ctx, action.ID, action.update)
+		case syncDelete:
+			err = sdk.Webhooks.Delete(ctx, action.ID)
+		}
+		if err != nil {
+			action.Error = err.Error()
+			failed++
+		}
+	}
+	return failed
+}
+
+// handleSyncWebhooks handles POST /api/webhooks/sync
+func (a *app) handleSyncWebhooks(w http.ResponseWriter, r *http.Request) {
+	var body webhookSyncRequest
+	if !a.bind(w, r, &body) {
+		return
+	}
+	if errs := checkWebhookSync(&body, a.config().effectiveEnvironment()); len(errs) > 0 {
+		p := newProblem(r, problemValidation, http.StatusUnprocessableEntity, "invalid webhook sync request")
+		p.Errors = errs
+		writeProblem(w, p)
+		return
+	}
+
+	result, err := a.syncWebhooks(r.Context(), &body, r.URL.Query().Get("dryRun") == "true")
+	if err != nil {
+		handleSDKError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// cliSyncWebhooks implements `webhooks sync --file FILE [--dry-run]`.
+func cliSyncWebhooks(c *cliContext, args []string) (any, error) {
+	fs := c.flags()
+	file := fs.String("file", "webhooks.yaml", "desired-state file")
+	dryRun := fs.Bool("dry-run", false, "print the plan without applying it")
+	if err := c.parse(fs, args); err != nil {
+		return nil, err
+	}
+	req, err := loadWebhookSpec(*file)
+	if err != nil {
+		return nil, cliUsageError{err}
+	}
+	if errs := checkWebhookSync(req, c.app.config().effectiveEnvironment()); len(errs) > 0 {
+		return nil, cliValidationError(errs)
+	}
+
+	result, err := c.app.syncWebhooks(c.ctx, req, *dryRun)
+	if err != nil {
+		return nil, err
+	}
+	if result.Failed > 0 {
+		return result.Actions, fmt.Errorf("%d of %d sync steps failed", result.Failed, len(result.Actions))
+	}
+	return result.Actions, nil
+}
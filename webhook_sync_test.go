@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+func TestPlanWebhookSync(t *testing.T) {
+	current := []playcamp.Webhook{
+		{ID: 4, EventType: "payment.created", URL: "https://old.example.com/hook", IsActive: true, RetryCount: 3},
+		{ID: 1, EventType: "coupon.redeemed", URL: "https://example.com/hook", IsActive: true, RetryCount: 3, TimeoutMs: 5000},
+		{ID: 2, EventType: "coupon.redeemed", URL: "https://example.com/other", IsActive: true},
+		{ID: 3, EventType: "sponsor.ended", URL: "https://example.com/hook", IsActive: true},
+	}
+	desired := []webhookSpec{
+		{EventType: "coupon.redeemed", URL: "https://example.com/hook", RetryCount: playcamp.Int(3)},
+		{EventType: "payment.created", URL: "https://example.com/hook", RetryCount: playcamp.Int(5)},
+		{EventType: "payment.refunded", URL: "https://example.com/hook"},
+		{EventType: "sponsor.ended", URL: "https://example.com/hook", IsActive: playcamp.Bool(false)},
+	}
+
+	var got []string
+	for _, a := range planWebhookSync(current, desired) {
+		line := a.Action + " " + string(a.EventType)
+		if a.ID != 0 {
+			line += fmt.Sprintf(" #%d", a.ID)
+		}
+		if a.Changes != "" {
+			line += ": " + a.Changes
+		}
+		got = append(got, line)
+	}
+	want := []string{
+		"unchanged coupon.redeemed #1",
+		"update payment.created #4: url https://old.example.com/hook -> https://example.com/hook, retryCount 3 -> 5",
+		"create payment.refunded",
+		"update sponsor.ended #3: isActive true -> false",
+		"delete coupon.redeemed #2",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("plan:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSyncWebhooksEndpoint(t *testing.T) {
+	const list = `{"data":[{"id":7,"eventType":"coupon.redeemed","url":"https://example.com/hook","isActive":true},{"id":8,"eventType":"sponsor.ended","url":"https://example.com/hook","isActive":true}]}`
+	body := `{"webhooks":[{"eventType":"coupon.redeemed","url":"https://example.com/hook"},{"eventType":"payment.created","url":"https://example.com/hook","retryCount":2}]}`
+
+	t.Run("dry run", func(t *testing.T) {
+		fake := newFakePlayCamp(t)
+		fake.on(http.MethodGet, "/v1/server/webhooks", http.StatusOK, list)
+		a := newTestApp(t, fake, nil)
+
+		rec := serve(a, http.MethodPost, "/api/webhooks/sync?dryRun=true", body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d; body %s", rec.Code, rec.Body)
+		}
+		var res webhookSyncResult
+		decodeData(t, rec, &res)
+		if !res.DryRun || len(res.Actions) != 3 {
+			t.Errorf("result = %+v", res)
+		}
+		if n := len(fake.received()); n != 1 {
+			t.Errorf("PlayCamp calls = %d, want only the list", n)
+		}
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		fake := newFakePlayCamp(t)
+		fake.on(http.MethodGet, "/v1/server/webhooks", http.StatusOK, list)
+		fake.on(http.MethodPost, "/v1/server/webhooks", http.StatusCreated, `{"data":{"id":9,"eventType":"payment.created","secret":"whsec_9"}}`)
+		fake.on(http.MethodDelete, "/v1/server/webhooks/8", http.StatusInternalServerError, `{"error":"boom"}`)
+		a := newTestApp(t, fake, nil)
+
+		rec := serve(a, http.MethodPost, "/api/webhooks/sync", body)
+		var res webhookSyncResult
+		decodeData(t, rec, &res)
+		if res.DryRun || res.Failed != 1 {
+			t.Errorf("result = %+v", res)
+		}
+		for _, action := range res.Actions {
+			switch action.Action {
+			case syncCreate:
+				if action.ID != 9 || action.Secret != "whsec_9" {
+					t.Errorf("create = %+v", action)
+				}
+			case syncDelete:
+				if action.Error == "" {
+					t.Errorf("failed delete has no error: %+v", action)
+				}
+			}
+		}
+		reqs := fake.received()
+		if len(reqs) != 3 || jsonBody(t, reqs[1])["retryCount"] != 2.0 {
+			t.Errorf("requests = %+v", reqs)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		a := newTestApp(t, newFakePlayCamp(t), map[string]string{"SDK_ENVIRONMENT": "sandbox"})
+		rec := serve(a, http.MethodPost, "/api/webhooks/sync", `{"environment":"live","webhooks":[
+			{"eventType":"coupon.redeemed","url":"https://example.com/hook"},
+			{"eventType":"coupon.redeemed","url":"https://example.com/hook"}]}`)
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("status = %d; body %s", rec.Code, rec.Body)
+		}
+		fields := map[string]bool{}
+		for _, e := range decodeProblem(t, rec).Errors {
+			fields[e.Field] = true
+		}
+		if !fields["webhooks[1]"] || !fields["environment"] {
+			t.Errorf("errors = %v", fields)
+		}
+	})
+}
+
+func TestCLIWebhooksSync(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "webhooks.yaml")
+	os.WriteFile(file, []byte("webhooks:\n  - eventType: payment.created\n    url: https://example.com/hook\n"), 0o644)
+	fake := newFakePlayCamp(t)
+	fake.on(http.MethodGet, "/v1/server/webhooks", http.StatusOK, `{"data":[]}`)
+
+	code, out, errOut := runTestCLI(t, fake, "webhooks", "sync", "--file", file, "--dry-run", "--output", "json")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, errOut)
+	}
+	var actions []webhookSyncAction
+	if err := json.Unmarshal([]byte(out), &actions); err != nil || len(actions) != 1 || actions[0].Action != syncCreate {
+		t.Errorf("output = %s (%v)", out, err)
+	}
+
+	os.WriteFile(file, []byte("webhook:\n  - eventType: payment.created\n"), 0o644)
+	if code, _, errOut := runTestCLI(t, fake, "webhooks", "sync", "--file", file); code != 2 || !strings.Contains(errOut, "webhook") {
+		t.Errorf("misspelled key: exit %d: %s", code, errOut)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+
+	playcamp "github.com/playcamp/playcamp-go-sdk"
+)
+
+// Webhook templates give each event type a data payload whose values are
+// generated. A string value "{{name}}" is replaced by the generator of that
+// name, keeping its type: "{{amount}}" becomes a number. Generators draw from
+// a seeded source and share state across a run, so a payment is made by the
+// sponsoring user and a refund names an earlier payment.
+
+// webhookTemplates holds the data template for each event type.
+var webhookTemplates = map[playcamp.WebhookEventType]string{
+	playcamp.WebhookEventCouponRedeemed:     `{"couponCode":"{{couponCode}}","userId":"{{userId}}","usageId":"{{usageId}}","reward":[{"itemId":"gems","itemName":{"en":"Gems","ko":"젬"},"itemQuantity":"{{quantity}}"}]}`,
+	playcamp.WebhookEventPaymentCreated:     `{"transactionId":"{{newTransactionId}}","userId":"{{userId}}","amount":"{{amount}}","currency":"{{currency}}","creatorKey":"{{creatorKey}}","campaignId":"{{campaignId}}"}`,
+	playcamp.WebhookEventPaymentRefunded:    `{"transactionId":"{{refundTransactionId}}","userId":"{{userId}}"}`,
+	playcamp.WebhookEventPaymentBulkCreated: `{"totalRequested":3,"successful":2,"failed":0,"skipped":1,"transactionIds":["{{newTransactionId}}","{{newTransactionId}}"]}`,
+	playcamp.WebhookEventSponsorCreated:     `{"userId":"{{userId}}","campaignId":"{{campaignId}}","creatorKey":"{{creatorKey}}"}`,
+	playcamp.WebhookEventSponsorChanged:     `{"userId":"{{userId}}","campaignId":"{{campaignId}}","oldCreatorKey":"{{creatorKey}}","newCreatorKey":"{{newCreatorKey}}"}`,
+	playcamp.WebhookEventSponsorEnded:       `{"userId":"{{userId}}","campaignId":"{{campaignId}}","creatorKey":"{{creatorKey}}"}`,
+}
+
+var (
+	fakeCreatorKeys = []string{"neo", "trinity", "morpheus", "oracle", "niobe", "switch"}
+	fakeCampaigns   = []string{"cmp_spring26", "cmp_summer26", "cmp_launch", "cmp_anniversary"}
+	// fakePriceTiers lists store price points in major units per currency.
+	fakePriceTiers = map[string][]float64{
+		"KRW": {1200, 5900, 12000, 33000, 59000},
+		"USD": {0.99, 4.99, 9.99, 24.99, 49.99},
+		"JPY": {160, 610, 1220, 3060, 6100},
+		"EUR": {0.99, 4.99, 10.99, 27.99, 54.99},
+	}
+	fakeCurrencies = []string{"KRW", "USD", "JPY", "EUR"}
+)
+
+// fakeData generates template values. Identity values (user, campaign,
+// creator, currency) are picked once and stay fixed for the run.
+type fakeData struct {
+	rng *rand.Rand
+
+	userID     string
+	campaignID string
+	creatorKey string
+	currency   string
+
+	// payments are transaction IDs that have not been refunded yet.
+	payments []string
+	// nextCreator is applied after the current event is rendered, so
+	// "oldCreatorKey" still sees the previous creator.
+	nextCreator string
+}
+
+func newFakeData(seed int64) *fakeData {
+	f := &fakeData{rng: rand.New(rand.NewSource(seed))}
+	f.userID = fmt.Sprintf("user_%04d", 1000+f.rng.Intn(9000))
+	f.campaignID = fakeCampaigns[f.rng.Intn(len(fakeCampaigns))]
+	f.creatorKey = fakeCreatorKeys[f.rng.Intn(len(fakeCreatorKeys))]
+	f.currency = fakeCurrencies[f.rng.Intn(len(fakeCurrencies))]
+	return f
+}
+
+// value returns the generated value for a template variable.
+func (f *fakeData) value(name string) (any, error) {
+	switch name {
+	case "userId":
+		return f.userID, nil
+	case "campaignId":
+		return f.campaignID, nil
+	case "creatorKey":
+		return f.creatorKey, nil
+	case "newCreatorKey":
+		for f.nextCreator == "" || f.nextCreator == f.creatorKey {
+			f.nextCreator = fakeCreatorKeys[f.rng.Intn(len(fakeCreatorKeys))]
+		}
+		return f.nextCreator, nil
+	case "currency":
+		return f.currency, nil
+	case "amount":
+		tiers := fakePriceTiers[f.currency]
+		return tiers[f.rng.Intn(len(tiers))], nil
+	case "newTransactionId":
+		id := fmt.Sprintf("GPA.%04d-%04d-%04d-%05d", f.rng.Intn(10000), f.rng.Intn(10000), f.rng.Intn(10000), f.rng.Intn(100000))
+		f.payments = append(f.payments, id)
+		return id, nil
+	case "refundTransactionId":
+		if len(f.payments) == 0 {
+			// Refunding without a payment in the run: invent one.
+			return fmt.Sprintf("GPA.%04d-0000-0000-00000", f.rng.Intn(10000)), nil
+		}
+		id := f.payments[len(f.payments)-1]
+		f.payments = f.payments[:len(f.payments)-1]
+		return id, nil
+	case "couponCode":
+		const alphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+		code := make([]byte, 4)
+		for i := range code {
+			code[i] = alphabet[f.rng.Intn(len(alphabet))]
+		}
+		return strings.ToUpper(f.creatorKey) + "-" + string(code), nil
+	case "usageId":
+		return 10000 + f.rng.Intn(90000), nil
+	case "quantity":
+		return []int{10, 50, 100, 500}[f.rng.Intn(4)], nil
+	}
+	return nil, fmt.Errorf("unknown template variable %q", name)
+}
+
+// render fills in template and merges overrides over the top-level fields.
+// Override values may use "{{name}}" too.
+func (f *fakeData) render(template string, overrides map[string]any) (json.RawMessage, error) {
+	var data any
+	if err := json.Unmarshal([]byte(template), &data); err != nil {
+		return nil, err
+	}
+	if obj, ok := data.(map[string]any); ok && len(overrides) > 0 {
+		// Copy the overrides so filling them leaves the caller's untouched
+		// for the next render.
+		raw, err := json.Marshal(overrides)
+		if err != nil {
+			return nil, err
+		}
+		var copied map[string]any
+		if err := json.Unmarshal(raw, &copied); err != nil {
+			return nil, err
+		}
+		for k, v := range copied {
+			obj[k] = v
+		}
+	}
+	data, err := f.fill(data)
+	if err != nil {
+		return nil, err
+	}
+	if f.nextCreator != "" {
+		f.creatorKey, f.nextCreator = f.nextCreator, ""
+	}
+	return json.Marshal(data)
+}
+
+func (f *fakeData) fill(v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		if name, ok := strings.CutPrefix(v, "{{"); ok && strings.HasSuffix(name, "}}") && !strings.Contains(name, "{{") {
+			return f.value(strings.TrimSuffix(name, "}}"))
+		}
+		return v, nil
+	case map[string]any:
+		// Visit keys in order so a seed always produces the same payload.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			filled, err := f.fill(v[k])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			v[k] = filled
+		}
+	case []any:
+		for i := range v {
+			filled, err := f.fill(v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = filled
+		}
+	}
+	return v, nil
+}
+
+// eventData renders the template for event.
+func (f *fakeData) eventData(event playcamp.WebhookEventType, overrides map[string]any) (json.RawMessage, error) {
+	template, ok := webhookTemplates[event]
+	if !ok {
+		return nil, fmt.Errorf("no template for event type %q", event)
+	}
+	return f.render(template, overrides)
+}
+
+// randomSeed returns a seed for runs that do not ask for one. Seeds are
+// reported back so a run can be repeated.
+func randomSeed() int64 {
+	return rand.Int63n(math.MaxInt32)
+}
+
+// webhookTemplateInfo describes a template for GET /api/webhooks/templates.
+type webhookTemplateInfo struct {
+	Event    string          `json:"event"`
+	Template json.RawMessage `json:"template"`
+	Example  json.RawMessage `json:"example"`
+}